@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/carlosrabelo/negev/negev/internal/application/services"
+	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/config"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/transport"
+)
+
+func inventoryCommand(args []string) int {
+	fs := flag.NewFlagSet("inventory", flag.ExitOnError)
+	var targets stringList
+	fs.Var(&targets, "target", "Switch IP address (repeatable or comma-separated)")
+	all := fs.Bool("all", false, "Inventory every switch in the config")
+	selectExpr := fs.String("select", "", "Inventory switches matching tags/group, e.g. site=hq,role=access")
+	workers := fs.Int("workers", services.DefaultWorkers, "Number of switches read concurrently")
+	configPath := fs.String("config", "", "Path to YAML config file")
+	verbose := fs.Int("verbose", 0, "Verbosity level: 0=none, 1=debug, 2=raw, 3=both")
+	format := fs.String("format", services.FormatTable, "Output format: table, json, yaml or csv")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s inventory (--target <ip> | --all | --select <expr>) [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Print every active port with its VLAN, learned MACs, OUI and trunk/excluded\n")
+		fmt.Fprintf(os.Stderr, "flags. Nothing is decided or changed.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	setupLogging(*verbose)
+
+	modes := 0
+	for _, set := range []bool{len(targets) > 0, *all, *selectExpr != ""} {
+		if set {
+			modes++
+		}
+	}
+	if modes != 1 {
+		fmt.Fprintf(os.Stderr, "ERROR: exactly one of --target, --all or --select is required\n\n")
+		fs.Usage()
+		return 1
+	}
+	if err := services.ValidateInventoryFormat(*format); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: --format: %v\n\n", err)
+		fs.Usage()
+		return 1
+	}
+	if *workers < 1 {
+		fmt.Fprintf(os.Stderr, "ERROR: --workers must be at least 1\n\n")
+		fs.Usage()
+		return 1
+	}
+
+	var selector config.Selector
+	if *selectExpr != "" {
+		var err error
+		selector, err = config.ParseSelector(*selectExpr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: --select: %v\n\n", err)
+			fs.Usage()
+			return 1
+		}
+	}
+
+	debugTarget := ""
+	if len(targets) == 1 {
+		debugTarget = targets[0]
+	}
+	cfg, err := loadConfig(*configPath, debugTarget, true, *verbose, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	if *all {
+		targets = cfg.Targets()
+	}
+	if selector != nil {
+		targets = cfg.Select(selector)
+		if len(targets) == 0 {
+			fmt.Fprintf(os.Stderr, "ERROR: no switches match --select %s\n", *selectExpr)
+			return 1
+		}
+	}
+	defer transport.CloseAll()
+
+	opts := services.RunOptions{Sandbox: true, Verbosity: *verbose, Output: services.OutputJSON}
+	results := services.NewVLANApplicationService(cfg, "").InventoryFleet(targets, *workers, opts)
+
+	invs := []*entities.Inventory{}
+	failed := 0
+	for _, r := range results {
+		if r.OK() {
+			invs = append(invs, r.Inventory)
+			continue
+		}
+		failed++
+		fmt.Fprintf(os.Stderr, "ERROR: %s: %v\n", r.Target, r.Err)
+	}
+	if len(invs) > 0 {
+		if err := services.WriteInventory(os.Stdout, *format, invs); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: failed to write inventory: %v\n", err)
+			return 1
+		}
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
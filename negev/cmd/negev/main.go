@@ -1,13 +1,17 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 
 	"github.com/carlosrabelo/negev/negev/internal/application/services"
+	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
 	"github.com/carlosrabelo/negev/negev/internal/infrastructure/config"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/metrics"
 	"github.com/carlosrabelo/negev/negev/internal/infrastructure/transport"
 
 	_ "github.com/carlosrabelo/negev/negev/internal/platform/dmos"
@@ -19,16 +23,61 @@ var (
 	buildTime = "unknown"
 )
 
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
 func main() {
-	target := flag.String("target", "", "Switch IP address (required)")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "plan":
+			os.Exit(planCommand(os.Args[2:]))
+		case "apply":
+			os.Exit(applyCommand(os.Args[2:]))
+		case "serve":
+			os.Exit(serveCommand(os.Args[2:]))
+		case "inventory":
+			os.Exit(inventoryCommand(os.Args[2:]))
+		case "validate":
+			os.Exit(validateCommand(os.Args[2:]))
+		case "rollback":
+			os.Exit(rollbackCommand(os.Args[2:]))
+		}
+	}
+
+	var targets stringList
+	flag.Var(&targets, "target", "Switch IP address (repeatable or comma-separated)")
+	all := flag.Bool("all", false, "Process every switch in the config")
+	selectExpr := flag.String("select", "", "Process switches matching tags/group, e.g. site=hq,role=access")
+	workers := flag.Int("workers", services.DefaultWorkers, "Number of switches processed concurrently with --all or multiple --target")
 	configPath := flag.String("config", "", "Path to YAML config file")
 	write := flag.Bool("write", false, "Apply changes (disables sandbox)")
+	force := flag.Bool("force", false, "With --write, apply even outside the change windows of a switch")
 	verbose := flag.Int("verbose", 0, "Verbosity level: 0=none, 1=debug, 2=raw, 3=both")
-	createVLANs := flag.Bool("create-vlans", false, "Synchronize VLANs (create missing, delete extras)")
+	createVLANs := flag.Bool("create-vlans", false, "Synchronize VLANs (create missing, rename drifted, delete extras)")
+	output := flag.String("output", services.OutputText, "Output format: text, json or yaml")
+	metricsFile := flag.String("metrics-file", "", "Write Prometheus metrics to this file (node_exporter textfile collector)")
 	showVersion := flag.Bool("version", false, "Show version and exit")
 
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s plan --target <ip> --out <file> [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s apply [options] <file>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s serve [--interval <duration>] [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s inventory --target <ip> [--format table|json|yaml|csv] [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s validate [--config <file>]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s rollback --target <ip> [--run <id>] [--write] [options]\n\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "VLAN automation tool for network switches.\n\n")
 		fmt.Fprintf(os.Stderr, "Options:\n")
 		flag.PrintDefaults()
@@ -36,26 +85,49 @@ func main() {
 
 	flag.Parse()
 
-	var level slog.Level
-	switch *verbose {
-	case 1, 3:
-		level = slog.LevelDebug
-	default:
-		level = slog.LevelInfo
-	}
-
-	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
-		Level: level,
-	})
-	slog.SetDefault(slog.New(handler))
+	setupLogging(*verbose)
 
 	if *showVersion {
 		fmt.Printf("Negev %s (built %s)\n", version, buildTime)
 		return
 	}
 
-	if *target == "" {
-		fmt.Fprintf(os.Stderr, "ERROR: --target is required\n\n")
+	modes := 0
+	for _, set := range []bool{len(targets) > 0, *all, *selectExpr != ""} {
+		if set {
+			modes++
+		}
+	}
+	if modes == 0 {
+		fmt.Fprintf(os.Stderr, "ERROR: --target, --all or --select is required\n\n")
+		flag.Usage()
+		os.Exit(1)
+	}
+	if modes > 1 {
+		fmt.Fprintf(os.Stderr, "ERROR: --target, --all and --select are mutually exclusive\n\n")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	var selector config.Selector
+	if *selectExpr != "" {
+		var err error
+		selector, err = config.ParseSelector(*selectExpr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: --select: %v\n\n", err)
+			flag.Usage()
+			os.Exit(1)
+		}
+	}
+
+	if err := services.ValidateOutputFormat(*output); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: --output: %v\n\n", err)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if *workers < 1 {
+		fmt.Fprintf(os.Stderr, "ERROR: --workers must be at least 1\n\n")
 		flag.Usage()
 		os.Exit(1)
 	}
@@ -66,27 +138,123 @@ func main() {
 		os.Exit(1)
 	}
 
-	cfgPath := *configPath
+	debugTarget := ""
+	if len(targets) == 1 {
+		debugTarget = targets[0]
+	}
+	cfg, err := loadConfig(*configPath, debugTarget, !*write, *verbose, *createVLANs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *all {
+		targets = cfg.Targets()
+	}
+	if selector != nil {
+		targets = cfg.Select(selector)
+		if len(targets) == 0 {
+			fmt.Fprintf(os.Stderr, "ERROR: no switches match --select %s\n", *selectExpr)
+			os.Exit(1)
+		}
+	}
+
+	opts := services.RunOptions{
+		Sandbox:     !*write,
+		Verbosity:   *verbose,
+		CreateVLANs: *createVLANs,
+		Output:      *output,
+		Force:       *force,
+	}
+	os.Exit(run(cfg, targets, *workers, opts, *metricsFile))
+}
+
+func setupLogging(verbose int) {
+	var level slog.Level
+	switch verbose {
+	case 1, 3:
+		level = slog.LevelDebug
+	default:
+		level = slog.LevelInfo
+	}
+
+	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: level,
+	})
+	slog.SetDefault(slog.New(handler))
+}
+
+func loadConfig(configPath, target string, sandbox bool, verbose int, createVLANs bool) (*config.Config, error) {
+	cfgPath := configPath
 	if cfgPath == "" {
 		var err error
-		cfgPath, err = config.FindPath("config.yaml", *verbose)
+		cfgPath, err = config.FindPath("config.yaml", verbose)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
-			os.Exit(1)
+			return nil, err
 		}
 	}
-
-	cfg, err := config.Load(cfgPath, *target, !*write, *verbose, *createVLANs)
+	cfg, err := config.Load(cfgPath, target, sandbox, verbose, createVLANs)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: failed to load config: %v\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("failed to load config: %v", err)
 	}
+	return cfg, nil
+}
 
+func run(cfg *config.Config, targets []string, workers int, opts services.RunOptions, metricsFile string) int {
 	defer transport.CloseAll()
 
-	svc := services.NewVLANApplicationService(cfg, *target)
-	if err := svc.Run(!*write, *verbose, *createVLANs); err != nil {
-		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
-		os.Exit(1)
+	var registry *metrics.Registry
+	if metricsFile != "" {
+		registry = metrics.NewRegistry()
+		if err := registry.LoadTextfile(metricsFile); err != nil {
+			slog.Warn("Failed to read previous metrics file", "path", metricsFile, "error", err)
+		}
+		defer func() {
+			if err := registry.WriteTextfile(metricsFile); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: failed to write metrics: %v\n", err)
+			}
+		}()
+	}
+
+	if len(targets) == 1 {
+		svc := services.NewVLANApplicationService(cfg, targets[0])
+		svc.SetMetrics(registry)
+		plan, err := svc.Run(opts)
+		if err != nil {
+			// A plan refused by a change limit is still shown for review.
+			if plan != nil && errors.Is(err, services.ErrChangeLimit) {
+				services.WriteStructured(os.Stdout, opts.Output, plan)
+			}
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			return 1
+		}
+		if err := services.WriteStructured(os.Stdout, opts.Output, plan); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: failed to write plan: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	svc := services.NewVLANApplicationService(cfg, "")
+	svc.SetMetrics(registry)
+	results := svc.RunFleet(targets, workers, opts)
+
+	summary := os.Stdout
+	if opts.Output != services.OutputText {
+		summary = os.Stderr
+		plans := []*entities.Plan{}
+		for _, r := range results {
+			if r.Plan != nil {
+				plans = append(plans, r.Plan)
+			}
+		}
+		if err := services.WriteStructured(os.Stdout, opts.Output, plans); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: failed to write plans: %v\n", err)
+			return 1
+		}
+	}
+	if failed := services.WriteSummary(summary, results); failed > 0 {
+		return 1
 	}
+	return 0
 }
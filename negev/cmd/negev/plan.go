@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/carlosrabelo/negev/negev/internal/application/services"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/storage"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/transport"
+)
+
+func planCommand(args []string) int {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	target := fs.String("target", "", "Switch IP address (required)")
+	out := fs.String("out", "", "Path of the plan file to write (required)")
+	configPath := fs.String("config", "", "Path to YAML config file")
+	verbose := fs.Int("verbose", 0, "Verbosity level: 0=none, 1=debug, 2=raw, 3=both")
+	createVLANs := fs.Bool("create-vlans", false, "Include VLAN synchronization in the plan")
+	output := fs.String("output", services.OutputText, "Output format: text, json or yaml")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s plan --target <ip> --out <file> [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Record the actions a sandbox run would take, plus a fingerprint of the switch state.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	setupLogging(*verbose)
+
+	if *target == "" || *out == "" {
+		fmt.Fprintf(os.Stderr, "ERROR: --target and --out are required\n\n")
+		fs.Usage()
+		return 1
+	}
+	if err := services.ValidateOutputFormat(*output); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: --output: %v\n\n", err)
+		fs.Usage()
+		return 1
+	}
+
+	cfg, err := loadConfig(*configPath, *target, true, *verbose, *createVLANs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	defer transport.CloseAll()
+
+	opts := services.RunOptions{Sandbox: true, Verbosity: *verbose, CreateVLANs: *createVLANs, Output: *output}
+	plan, err := services.NewVLANApplicationService(cfg, *target).Run(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	if err := storage.SavePlan(*out, plan); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	if err := services.WriteStructured(os.Stdout, *output, plan); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: failed to write plan: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Plan with %d action(s) written to %s\n", len(plan.Actions), *out)
+	return 0
+}
+
+func applyCommand(args []string) int {
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	configPath := fs.String("config", "", "Path to YAML config file")
+	verbose := fs.Int("verbose", 0, "Verbosity level: 0=none, 1=debug, 2=raw, 3=both")
+	force := fs.Bool("force", false, "Apply even outside the change windows of the switch")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s apply [options] <plan-file>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Execute a recorded plan if the switch state still matches it.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	planPath := fs.Arg(0)
+	if fs.NArg() > 1 {
+		fs.Parse(fs.Args()[1:])
+	}
+
+	setupLogging(*verbose)
+
+	if planPath == "" {
+		fmt.Fprintf(os.Stderr, "ERROR: plan file is required\n\n")
+		fs.Usage()
+		return 1
+	}
+
+	plan, err := storage.LoadPlan(planPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	cfg, err := loadConfig(*configPath, plan.Target, false, *verbose, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	defer transport.CloseAll()
+
+	opts := services.RunOptions{Verbosity: *verbose, Output: services.OutputText, Force: *force}
+	if err := services.NewVLANApplicationService(cfg, plan.Target).Apply(plan, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/carlosrabelo/negev/negev/internal/application/services"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/transport"
+)
+
+func rollbackCommand(args []string) int {
+	fs := flag.NewFlagSet("rollback", flag.ExitOnError)
+	target := fs.String("target", "", "Switch IP address (required)")
+	runID := fs.String("run", "", "Run ID to undo (default: latest applied run that is not a rollback)")
+	list := fs.Bool("list", false, "List the journaled runs of the switch and exit")
+	configPath := fs.String("config", "", "Path to YAML config file")
+	write := fs.Bool("write", false, "Apply the rollback (sandbox/dry-run by default)")
+	force := fs.Bool("force", false, "Write even outside the change windows of the switch")
+	verbose := fs.Int("verbose", 0, "Verbosity level: 0=none, 1=debug, 2=raw, 3=both")
+	output := fs.String("output", services.OutputText, "Output format: text, json or yaml")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s rollback --target <ip> [--run <id>] [--write] [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Undo an applied run recorded in the rollback journal: ports go back to\n")
+		fmt.Fprintf(os.Stderr, "their previous VLAN and created/deleted VLANs are deleted/created again.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	setupLogging(*verbose)
+
+	if *target == "" {
+		fmt.Fprintf(os.Stderr, "ERROR: --target is required\n\n")
+		fs.Usage()
+		return 1
+	}
+	if err := services.ValidateOutputFormat(*output); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: --output: %v\n\n", err)
+		fs.Usage()
+		return 1
+	}
+
+	cfg, err := loadConfig(*configPath, *target, !*write, *verbose, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	svc := services.NewVLANApplicationService(cfg, *target)
+
+	if *list {
+		journal, err := svc.Journal()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			return 1
+		}
+		runs, err := journal.Runs(*target)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			return 1
+		}
+		for _, id := range runs {
+			entry, err := journal.Load(*target, id)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				continue
+			}
+			if entry.RollbackOf != "" {
+				fmt.Printf("%s  %d action(s)  rollback of %s\n", id, len(entry.Actions), entry.RollbackOf)
+				continue
+			}
+			fmt.Printf("%s  %d action(s)\n", id, len(entry.Actions))
+		}
+		return 0
+	}
+
+	defer transport.CloseAll()
+	opts := services.RunOptions{Sandbox: !*write, Verbosity: *verbose, Output: *output, Force: *force}
+	plan, err := svc.Rollback(*target, *runID, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		return 1
+	}
+	if err := services.WriteStructured(os.Stdout, *output, plan); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: failed to write plan: %v\n", err)
+		return 1
+	}
+	return 0
+}
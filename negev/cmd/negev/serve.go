@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/carlosrabelo/negev/negev/internal/application/services"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/config"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/httpapi"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/metrics"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/transport"
+)
+
+func serveCommand(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	var targets stringList
+	fs.Var(&targets, "target", "Switch IP address (repeatable or comma-separated, default: all switches)")
+	selectExpr := fs.String("select", "", "Reconcile switches matching tags/group, e.g. site=hq,role=access")
+	interval := fs.Duration("interval", services.DefaultInterval, "Time between reconciliation cycles")
+	workers := fs.Int("workers", services.DefaultWorkers, "Number of switches processed concurrently")
+	configPath := fs.String("config", "", "Path to YAML config file")
+	write := fs.Bool("write", false, "Apply changes (disables sandbox)")
+	force := fs.Bool("force", false, "With --write, apply even outside the change windows of a switch")
+	verbose := fs.Int("verbose", 0, "Verbosity level: 0=none, 1=debug, 2=raw, 3=both")
+	createVLANs := fs.Bool("create-vlans", false, "Synchronize VLANs (create missing, rename drifted, delete extras)")
+	listen := fs.String("listen", "", "Serve the HTTP API and /metrics on this address, e.g. :8080 (localhost only) or 0.0.0.0:8080")
+	apiToken := fs.String("api-token", "", "Bearer token required by the HTTP API (default $NEGEV_API_TOKEN); needed to apply changes")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s serve [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Reconcile switches periodically. SIGTERM/SIGINT finish the switches in\n")
+		fmt.Fprintf(os.Stderr, "progress and exit; SIGHUP finishes them, reloads the config and starts a new cycle.\n")
+		fmt.Fprintf(os.Stderr, "With --listen an HTTP API is served as well; --interval 0 then disables the\n")
+		fmt.Fprintf(os.Stderr, "periodic cycles so switches are only processed on request.\n")
+		fmt.Fprintf(os.Stderr, "An address without a host listens on localhost only.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	setupLogging(*verbose)
+
+	if len(targets) > 0 && *selectExpr != "" {
+		fmt.Fprintf(os.Stderr, "ERROR: --target and --select are mutually exclusive\n\n")
+		fs.Usage()
+		return 1
+	}
+	if *interval < 0 || (*interval == 0 && *listen == "") {
+		fmt.Fprintf(os.Stderr, "ERROR: --interval must be positive (0 is only allowed with --listen)\n\n")
+		fs.Usage()
+		return 1
+	}
+	if *workers < 1 {
+		fmt.Fprintf(os.Stderr, "ERROR: --workers must be at least 1\n\n")
+		fs.Usage()
+		return 1
+	}
+
+	var selector config.Selector
+	if *selectExpr != "" {
+		var err error
+		selector, err = config.ParseSelector(*selectExpr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: --select: %v\n\n", err)
+			fs.Usage()
+			return 1
+		}
+	}
+
+	load := func() (*config.Config, error) {
+		return loadConfig(*configPath, "", !*write, *verbose, *createVLANs)
+	}
+	selectTargets := func(cfg *config.Config) []string {
+		switch {
+		case selector != nil:
+			return cfg.Select(selector)
+		case len(targets) > 0:
+			return targets
+		default:
+			return cfg.Targets()
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	reload := make(chan struct{})
+	go func() {
+		for range hup {
+			select {
+			case reload <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	defer transport.CloseAll()
+
+	opts := services.RunOptions{
+		Sandbox:     !*write,
+		Verbosity:   *verbose,
+		CreateVLANs: *createVLANs,
+		Output:      services.OutputText,
+		Force:       *force,
+	}
+	slog.Info("Starting reconciliation daemon", "interval", *interval, "workers", *workers, "sandbox", opts.Sandbox)
+	tracker := services.NewRunTracker()
+	daemon := services.NewDaemon(load, selectTargets, *interval, *workers, opts)
+	daemon.SetTracker(tracker)
+	registry := metrics.NewRegistry()
+	daemon.SetMetrics(registry)
+
+	var server *http.Server
+	serverErr := make(chan error, 1)
+	if *listen != "" {
+		token := *apiToken
+		if token == "" {
+			token = os.Getenv("NEGEV_API_TOKEN")
+		}
+		if *write && token == "" {
+			slog.Warn("No API token set — the HTTP API will refuse to apply changes", "hint", "set --api-token or NEGEV_API_TOKEN")
+		}
+		address := listenAddress(*listen)
+		api := httpapi.NewServer(daemon.Config, tracker, opts, *write, token)
+		api.SetMetrics(registry)
+		mux := http.NewServeMux()
+		mux.Handle("/", api)
+		mux.Handle("GET /metrics", registry)
+		server = &http.Server{
+			Addr:              address,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			slog.Info("HTTP API listening", "address", address, "apply", *write && token != "", "auth", token != "")
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serverErr <- err
+				stop()
+			}
+		}()
+	}
+
+	runErr := daemon.Run(ctx, reload)
+	if server != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("HTTP API shutdown failed", "error", err)
+		}
+	}
+	if runErr != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", runErr)
+		return 1
+	}
+	select {
+	case err := <-serverErr:
+		fmt.Fprintf(os.Stderr, "ERROR: HTTP API: %v\n", err)
+		return 1
+	default:
+	}
+	slog.Info("Daemon stopped")
+	return 0
+}
+
+// listenAddress binds an address without a host, such as ":8080", to
+// localhost, so the API is only reachable from elsewhere when asked for.
+func listenAddress(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host != "" {
+		return addr
+	}
+	return net.JoinHostPort("127.0.0.1", port)
+}
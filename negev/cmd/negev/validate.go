@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/config"
+	"github.com/carlosrabelo/negev/negev/internal/platform"
+)
+
+func validateCommand(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	configPath := fs.String("config", "", "Path to YAML config file")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s validate [--config <file>]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Check the configuration without connecting to any switch and report every\n")
+		fmt.Fprintf(os.Stderr, "problem found. Exits with status 1 if there is any.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	path := *configPath
+	if path == "" {
+		var err error
+		path, err = config.FindPath("config.yaml", 0)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			return 1
+		}
+	}
+
+	problems := config.Validate(path, platform.ValidInterface)
+	if len(problems) == 0 {
+		fmt.Printf("%s: OK\n", path)
+		return 0
+	}
+	fmt.Printf("%s: %d problem(s)\n", path, len(problems))
+	for _, p := range problems {
+		fmt.Printf("  - %v\n", p)
+	}
+	return 1
+}
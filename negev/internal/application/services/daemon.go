@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/config"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/metrics"
+)
+
+const DefaultInterval = 15 * time.Minute
+
+type Daemon struct {
+	load       func() (*config.Config, error)
+	targets    func(*config.Config) []string
+	interval   time.Duration
+	workers    int
+	opts       RunOptions
+	newAdapter AdapterFactory
+	tracker    *RunTracker
+	metrics    *metrics.Registry
+
+	mu  sync.RWMutex
+	cfg *config.Config
+}
+
+func NewDaemon(load func() (*config.Config, error), targets func(*config.Config) []string, interval time.Duration, workers int, opts RunOptions) *Daemon {
+	return &Daemon{
+		load:       load,
+		targets:    targets,
+		interval:   interval,
+		workers:    workers,
+		opts:       opts,
+		newAdapter: newPersistentSwitchAdapter,
+	}
+}
+
+func (d *Daemon) SetTracker(t *RunTracker) {
+	d.tracker = t
+}
+
+func (d *Daemon) SetMetrics(m *metrics.Registry) {
+	d.metrics = m
+}
+
+// Config returns the configuration currently in use, or nil before Run has
+// loaded it.
+func (d *Daemon) Config() *config.Config {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.cfg
+}
+
+func (d *Daemon) setConfig(cfg *config.Config) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.cfg = cfg
+}
+
+// Run reconciles the selected switches every interval until ctx is done.
+// Cancelling ctx lets the switches in flight finish before returning. A value
+// on reload does the same to the current cycle, loads the configuration again
+// and starts a new cycle right away; if loading fails the previous
+// configuration is kept. With a zero interval no cycle is scheduled and the
+// daemon only keeps the configuration current for other consumers.
+func (d *Daemon) Run(ctx context.Context, reload <-chan struct{}) error {
+	cfg, err := d.load()
+	if err != nil {
+		return err
+	}
+	d.setConfig(cfg)
+
+	for {
+		reloading := false
+		if d.interval > 0 {
+			reloading = d.cycle(ctx, cfg, reload)
+			if ctx.Err() != nil {
+				return nil
+			}
+		}
+
+		if !reloading {
+			var stop bool
+			reloading, stop = d.wait(ctx, reload)
+			if stop {
+				return nil
+			}
+		}
+
+		if reloading {
+			newCfg, err := d.load()
+			if err != nil {
+				slog.Error("Config reload failed — keeping previous configuration", "error", err)
+				continue
+			}
+			cfg = newCfg
+			d.setConfig(cfg)
+			slog.Info("Configuration reloaded", "switches", len(cfg.Switches))
+		}
+	}
+}
+
+func (d *Daemon) wait(ctx context.Context, reload <-chan struct{}) (reloading, stop bool) {
+	var tick <-chan time.Time
+	if d.interval > 0 {
+		timer := time.NewTimer(d.interval)
+		defer timer.Stop()
+		tick = timer.C
+	}
+	select {
+	case <-ctx.Done():
+		return false, true
+	case <-reload:
+		return true, false
+	case <-tick:
+		return false, false
+	}
+}
+
+func (d *Daemon) cycle(ctx context.Context, cfg *config.Config, reload <-chan struct{}) bool {
+	cycleCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	svc := NewVLANApplicationService(cfg, "")
+	svc.SetAdapterFactory(d.newAdapter)
+	svc.SetTracker(d.tracker)
+	svc.SetMetrics(d.metrics)
+
+	start := time.Now()
+	done := make(chan []TargetResult, 1)
+	go func() {
+		done <- svc.RunFleetContext(cycleCtx, d.targets(cfg), d.workers, d.opts)
+	}()
+
+	reloading := false
+	for {
+		select {
+		case results := <-done:
+			failed := 0
+			for _, r := range results {
+				if !r.OK() {
+					failed++
+				}
+			}
+			slog.Info("Reconciliation cycle finished", "switches", len(results), "failed", failed, "duration", time.Since(start).Round(time.Millisecond))
+			return reloading
+		case <-reload:
+			if !reloading {
+				slog.Info("Reload requested — finishing switches in progress")
+				reloading = true
+				cancel()
+			}
+		}
+	}
+}
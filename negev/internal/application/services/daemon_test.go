@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
+	"github.com/carlosrabelo/negev/negev/internal/domain/ports"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/config"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/transport"
+)
+
+type daemonProbe struct {
+	mu     sync.Mutex
+	loads  int
+	cycles int
+	fail   bool
+}
+
+func (p *daemonProbe) load() (*config.Config, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.loads++
+	if p.fail {
+		return nil, errors.New("broken yaml")
+	}
+	return &config.Config{Switches: []entities.SwitchConfig{
+		{Target: "10.0.0.1", Platform: "ios", DefaultVlan: "10"},
+	}}, nil
+}
+
+func (p *daemonProbe) targets(cfg *config.Config) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cycles++
+	return cfg.Targets()
+}
+
+func (p *daemonProbe) counts() (int, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.loads, p.cycles
+}
+
+func newTestDaemon(p *daemonProbe, interval time.Duration) *Daemon {
+	d := NewDaemon(p.load, p.targets, interval, 1, RunOptions{Sandbox: true, Output: OutputJSON})
+	d.newAdapter = func(sc entities.SwitchConfig) ports.SwitchRepository {
+		return transport.NewSwitchAdapterWithClient(sc, iosScriptedClient())
+	}
+	return d
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for daemon")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDaemonRunsCyclesAndStops(t *testing.T) {
+	p := &daemonProbe{}
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() { errCh <- newTestDaemon(p, 10*time.Millisecond).Run(ctx, nil) }()
+
+	waitFor(t, func() bool { _, cycles := p.counts(); return cycles >= 3 })
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if loads, _ := p.counts(); loads != 1 {
+		t.Errorf("expected config loaded once without reload, got %d", loads)
+	}
+}
+
+func TestDaemonReloadsConfig(t *testing.T) {
+	p := &daemonProbe{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reload := make(chan struct{})
+	errCh := make(chan error, 1)
+	go func() { errCh <- newTestDaemon(p, time.Hour).Run(ctx, reload) }()
+
+	waitFor(t, func() bool { _, cycles := p.counts(); return cycles >= 1 })
+	reload <- struct{}{}
+	waitFor(t, func() bool { loads, cycles := p.counts(); return loads == 2 && cycles >= 2 })
+
+	p.mu.Lock()
+	p.fail = true
+	p.mu.Unlock()
+	reload <- struct{}{}
+	waitFor(t, func() bool { loads, cycles := p.counts(); return loads == 3 && cycles >= 3 })
+
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+}
+
+func TestDaemonWithoutIntervalOnlyReloads(t *testing.T) {
+	p := &daemonProbe{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reload := make(chan struct{})
+	d := newTestDaemon(p, 0)
+	errCh := make(chan error, 1)
+	go func() { errCh <- d.Run(ctx, reload) }()
+
+	waitFor(t, func() bool { return d.Config() != nil })
+	first := d.Config()
+	reload <- struct{}{}
+	waitFor(t, func() bool { return d.Config() != first })
+
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if loads, cycles := p.counts(); loads != 2 || cycles != 0 {
+		t.Errorf("expected 2 loads and no cycles, got %d loads and %d cycles", loads, cycles)
+	}
+}
+
+func TestDaemonSharesTracker(t *testing.T) {
+	p := &daemonProbe{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tracker := NewRunTracker()
+	d := newTestDaemon(p, time.Hour)
+	d.SetTracker(tracker)
+	go d.Run(ctx, nil)
+
+	waitFor(t, func() bool { _, ok := tracker.Last("10.0.0.1"); return ok })
+	record, _ := tracker.Last("10.0.0.1")
+	if record.Error != "" || !record.Sandbox || record.Plan == nil {
+		t.Fatalf("unexpected record: %+v", record)
+	}
+}
+
+func TestDaemonInitialLoadFailure(t *testing.T) {
+	p := &daemonProbe{fail: true}
+	if err := newTestDaemon(p, time.Hour).Run(context.Background(), nil); err == nil {
+		t.Fatal("expected initial load error")
+	}
+}
+
+func TestRunFleetContextSkipsAfterCancel(t *testing.T) {
+	cfg := &config.Config{Switches: []entities.SwitchConfig{{Target: "10.0.0.1", Platform: "ios"}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results := NewVLANApplicationService(cfg, "").RunFleetContext(ctx, []string{"10.0.0.1", "10.0.0.2"}, 1, RunOptions{Sandbox: true})
+	for _, r := range results {
+		if r.OK() {
+			t.Errorf("expected %s to be skipped after cancellation", r.Target)
+		}
+	}
+}
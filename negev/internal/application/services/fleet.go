@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
+	domainServices "github.com/carlosrabelo/negev/negev/internal/domain/services"
+)
+
+const DefaultWorkers = 4
+
+type TargetResult struct {
+	Target    string
+	Plan      *entities.Plan
+	Inventory *entities.Inventory
+	Err       error
+	Duration  time.Duration
+}
+
+func (r TargetResult) OK() bool {
+	return r.Err == nil
+}
+
+// RunFleet processes every target concurrently with at most workers switches
+// in flight. A failure (or panic) on one switch never aborts the others; the
+// returned results keep the order of targets.
+func (s *VLANApplicationService) RunFleet(targets []string, workers int, opts RunOptions) []TargetResult {
+	return s.RunFleetContext(context.Background(), targets, workers, opts)
+}
+
+// RunFleetContext is RunFleet with cancellation: once ctx is done no new
+// switch is started, switches already in flight finish, and the remaining
+// ones are reported as skipped.
+func (s *VLANApplicationService) RunFleetContext(ctx context.Context, targets []string, workers int, opts RunOptions) []TargetResult {
+	var budget *domainServices.ChangeBudget
+	if s.cfg.MaxFleetChanges > 0 {
+		budget = domainServices.NewChangeBudget(s.cfg.MaxFleetChanges)
+	}
+	return forEachTarget(ctx, targets, workers, func(target string, result *TargetResult) {
+		result.Plan, result.Err = s.runTarget(target, opts, budget)
+	})
+}
+
+// InventoryFleet collects the inventory of every target with the same
+// isolation guarantees as RunFleet.
+func (s *VLANApplicationService) InventoryFleet(targets []string, workers int, opts RunOptions) []TargetResult {
+	return forEachTarget(context.Background(), targets, workers, func(target string, result *TargetResult) {
+		result.Inventory, result.Err = s.inventory(target, opts)
+	})
+}
+
+func forEachTarget(ctx context.Context, targets []string, workers int, fn func(string, *TargetResult)) []TargetResult {
+	targets = dedupTargets(targets)
+	results := make([]TargetResult, len(targets))
+	if len(targets) == 0 {
+		return results
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(targets) {
+		workers = len(targets)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = runIsolated(targets[i], fn)
+			}
+		}()
+	}
+	for i := range targets {
+		if ctx.Err() == nil {
+			select {
+			case jobs <- i:
+				continue
+			case <-ctx.Done():
+			}
+		}
+		for j := i; j < len(targets); j++ {
+			results[j] = TargetResult{Target: targets[j], Err: fmt.Errorf("skipped: %v", ctx.Err())}
+		}
+		break
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func runIsolated(target string, fn func(string, *TargetResult)) (result TargetResult) {
+	start := time.Now()
+	result.Target = target
+	defer func() {
+		if r := recover(); r != nil {
+			result.Err = fmt.Errorf("panic: %v", r)
+		}
+		result.Duration = time.Since(start)
+		if result.Err != nil {
+			slog.Error("Switch run failed", "target", target, "error", result.Err)
+		}
+	}()
+	fn(target, &result)
+	return result
+}
+
+func dedupTargets(targets []string) []string {
+	seen := make(map[string]bool, len(targets))
+	var result []string
+	for _, t := range targets {
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		result = append(result, t)
+	}
+	return result
+}
+
+// WriteSummary prints one line per switch and returns the number of failures.
+func WriteSummary(w io.Writer, results []TargetResult) int {
+	failed := 0
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TARGET\tSTATUS\tDURATION\tDETAIL")
+	for _, r := range results {
+		status, detail := "ok", ""
+		if !r.OK() {
+			status, detail = "failed", r.Err.Error()
+			failed++
+		} else if r.Plan != nil && r.Plan.RandomMacPorts > 0 {
+			detail = fmt.Sprintf("%d port(s) with a randomized MAC", r.Plan.RandomMacPorts)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Target, status, r.Duration.Round(time.Millisecond), detail)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "%d switch(es) processed, %d ok, %d failed\n", len(results), len(results)-failed, failed)
+	return failed
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
+	"github.com/carlosrabelo/negev/negev/internal/domain/ports"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/config"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/transport"
+)
+
+func TestRunFleetIsolatesFailures(t *testing.T) {
+	clients := map[string]*scriptedClient{
+		"10.0.0.1": iosScriptedClient(),
+		"10.0.0.2": {failConnect: errors.New("unreachable")},
+		"10.0.0.3": iosScriptedClient(),
+	}
+	cfg := &config.Config{Switches: []entities.SwitchConfig{
+		{Target: "10.0.0.1", Platform: "ios", DefaultVlan: "10"},
+		{Target: "10.0.0.2", Platform: "ios", DefaultVlan: "10"},
+		{Target: "10.0.0.3", Platform: "ios", DefaultVlan: "10"},
+	}}
+	svc := NewVLANApplicationService(cfg, "")
+	svc.newAdapter = func(sc entities.SwitchConfig) ports.SwitchRepository {
+		return transport.NewSwitchAdapterWithClient(sc, clients[sc.Target])
+	}
+
+	targets := append(cfg.Targets(), "10.0.0.1", "10.0.0.9")
+	results := svc.RunFleet(targets, 2, RunOptions{Sandbox: true})
+	if len(results) != 4 {
+		t.Fatalf("expected 4 results (deduplicated), got %d", len(results))
+	}
+	want := []struct {
+		target string
+		ok     bool
+	}{
+		{"10.0.0.1", true},
+		{"10.0.0.2", false},
+		{"10.0.0.3", true},
+		{"10.0.0.9", false},
+	}
+	for i, w := range want {
+		if results[i].Target != w.target || results[i].OK() != w.ok {
+			t.Errorf("result %d = %+v; expected target %s ok=%v", i, results[i], w.target, w.ok)
+		}
+	}
+}
+
+func TestRunFleetRecoversPanic(t *testing.T) {
+	cfg := &config.Config{Switches: []entities.SwitchConfig{{Target: "10.0.0.1", Platform: "ios"}}}
+	svc := NewVLANApplicationService(cfg, "")
+	svc.newAdapter = func(sc entities.SwitchConfig) ports.SwitchRepository {
+		panic("adapter exploded")
+	}
+	results := svc.RunFleet([]string{"10.0.0.1"}, 0, RunOptions{Sandbox: true})
+	if len(results) != 1 || results[0].OK() || !strings.Contains(results[0].Err.Error(), "panic") {
+		t.Fatalf("expected recovered panic, got %+v", results)
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	var buf bytes.Buffer
+	failed := WriteSummary(&buf, []TargetResult{
+		{Target: "10.0.0.1", Plan: &entities.Plan{RandomMacPorts: 3}},
+		{Target: "10.0.0.2", Err: errors.New("unreachable")},
+	})
+	if failed != 1 {
+		t.Fatalf("WriteSummary() = %d; expected 1", failed)
+	}
+	out := buf.String()
+	for _, want := range []string{"10.0.0.1", "ok", "10.0.0.2", "failed", "unreachable", "3 port(s) with a randomized MAC", "2 switch(es) processed, 1 ok, 1 failed"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("summary missing %q:\n%s", want, out)
+		}
+	}
+}
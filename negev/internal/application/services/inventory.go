@@ -0,0 +1,114 @@
+package services
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
+)
+
+const (
+	FormatTable = "table"
+	FormatCSV   = "csv"
+)
+
+func ValidateInventoryFormat(format string) error {
+	switch format {
+	case FormatTable, FormatCSV, OutputJSON, OutputYAML:
+		return nil
+	default:
+		return fmt.Errorf("format %s is invalid, must be 'table', 'json', 'yaml' or 'csv'", format)
+	}
+}
+
+// WriteInventory prints inventories as a table per switch, as CSV with one row
+// per learned MAC, or as a JSON/YAML array, even for a single switch.
+func WriteInventory(w io.Writer, format string, invs []*entities.Inventory) error {
+	switch format {
+	case FormatTable:
+		return writeInventoryTable(w, invs)
+	case FormatCSV:
+		return writeInventoryCSV(w, invs)
+	default:
+		return WriteStructured(w, format, invs)
+	}
+}
+
+func writeInventoryTable(w io.Writer, invs []*entities.Inventory) error {
+	for i, inv := range invs {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s (%s) VLANs: %s\n", inv.Target, inv.Platform, strings.Join(inv.Vlans, ", "))
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "PORT\tVLAN\tMAC\tOUI\tVENDOR\tFLAGS")
+		for _, p := range inv.Ports {
+			var macs, ouis, vendors []string
+			for _, m := range p.Macs {
+				macs = append(macs, m.Mac)
+				ouis = append(ouis, m.OUI)
+				vendor := m.Vendor
+				if vendor == "" {
+					vendor = "-"
+				}
+				vendors = append(vendors, vendor)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", p.Interface, p.Vlan, strings.Join(macs, ","), strings.Join(ouis, ","), strings.Join(vendors, "; "), portFlags(p))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func portFlags(p entities.InventoryPort) string {
+	var flags []string
+	if p.Trunk {
+		flags = append(flags, "trunk")
+	}
+	if p.Excluded {
+		flags = append(flags, "excluded")
+	}
+	for _, m := range p.Macs {
+		if m.Excluded {
+			flags = append(flags, "excluded-mac")
+			break
+		}
+	}
+	for _, m := range p.Macs {
+		if m.Blocked {
+			flags = append(flags, "blocked-mac")
+			break
+		}
+	}
+	if len(p.Macs) > 1 {
+		flags = append(flags, "multi-mac")
+	}
+	return strings.Join(flags, ",")
+}
+
+func writeInventoryCSV(w io.Writer, invs []*entities.Inventory) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"target", "platform", "port", "vlan", "mac", "oui", "vendor", "trunk", "excluded_port", "excluded_mac", "blocked_mac"})
+	for _, inv := range invs {
+		for _, p := range inv.Ports {
+			macs := p.Macs
+			if len(macs) == 0 {
+				macs = []entities.InventoryMac{{}}
+			}
+			for _, m := range macs {
+				cw.Write([]string{
+					inv.Target, inv.Platform, p.Interface, p.Vlan, m.Mac, m.OUI, m.Vendor,
+					strconv.FormatBool(p.Trunk), strconv.FormatBool(p.Excluded), strconv.FormatBool(m.Excluded), strconv.FormatBool(m.Blocked),
+				})
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
+	"github.com/carlosrabelo/negev/negev/internal/domain/ports"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/config"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/transport"
+)
+
+func sampleInventory() *entities.Inventory {
+	return &entities.Inventory{
+		Target:   "10.0.0.1",
+		Platform: "ios",
+		Vlans:    []string{"1", "10"},
+		Ports: []entities.InventoryPort{
+			{Interface: "Gi1/0/1", Vlan: "1", Macs: []entities.InventoryMac{
+				{Mac: "aabb.ccdd.eeff", OUI: "aabbcc", Vendor: "Acme, Inc.", Blocked: true},
+				{Mac: "1122.3344.5566", OUI: "112233", Excluded: true},
+			}},
+			{Interface: "Gi1/0/24", Vlan: "1", Trunk: true},
+		},
+	}
+}
+
+func TestWriteInventoryTable(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteInventory(&buf, FormatTable, []*entities.Inventory{sampleInventory()}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"10.0.0.1 (ios) VLANs: 1, 10",
+		"PORT",
+		"aabb.ccdd.eeff,1122.3344.5566",
+		"aabbcc,112233",
+		"Acme, Inc.; -",
+		"excluded-mac,blocked-mac,multi-mac",
+		"trunk",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("table missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteInventoryCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteInventory(&buf, FormatCSV, []*entities.Inventory{sampleInventory()}); err != nil {
+		t.Fatal(err)
+	}
+	want := `target,platform,port,vlan,mac,oui,vendor,trunk,excluded_port,excluded_mac,blocked_mac
+10.0.0.1,ios,Gi1/0/1,1,aabb.ccdd.eeff,aabbcc,"Acme, Inc.",false,false,false,true
+10.0.0.1,ios,Gi1/0/1,1,1122.3344.5566,112233,,false,false,true,false
+10.0.0.1,ios,Gi1/0/24,1,,,,true,false,false,false
+`
+	if buf.String() != want {
+		t.Fatalf("CSV = \n%s\nexpected\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteInventoryJSONShape(t *testing.T) {
+	var single, multi bytes.Buffer
+	WriteInventory(&single, OutputJSON, []*entities.Inventory{sampleInventory()})
+	WriteInventory(&multi, OutputJSON, []*entities.Inventory{sampleInventory(), sampleInventory()})
+	if !strings.HasPrefix(single.String(), "[") || !strings.HasPrefix(multi.String(), "[") {
+		t.Fatalf("unexpected JSON shapes:\n%s\n%s", single.String(), multi.String())
+	}
+	if err := ValidateInventoryFormat("xml"); err == nil {
+		t.Fatal("expected invalid format error")
+	}
+}
+
+func TestInventoryFleet(t *testing.T) {
+	cfg := &config.Config{Switches: []entities.SwitchConfig{
+		{Target: "10.0.0.1", Platform: "ios"},
+		{Target: "10.0.0.2", Platform: "ios"},
+	}}
+	clients := map[string]*scriptedClient{
+		"10.0.0.1": iosScriptedClient(),
+		"10.0.0.2": {failConnect: errors.New("unreachable")},
+	}
+	svc := NewVLANApplicationService(cfg, "")
+	svc.SetAdapterFactory(func(sc entities.SwitchConfig) ports.SwitchRepository {
+		return transport.NewSwitchAdapterWithClient(sc, clients[sc.Target])
+	})
+	results := svc.InventoryFleet(cfg.Targets(), 2, RunOptions{Sandbox: true})
+	if !results[0].OK() || results[0].Inventory == nil || len(results[0].Inventory.Ports) != 1 {
+		t.Fatalf("unexpected result for reachable switch: %+v", results[0])
+	}
+	if results[0].Inventory.Ports[0].Macs[0].OUI != "aabbcc" {
+		t.Errorf("unexpected port: %+v", results[0].Inventory.Ports[0])
+	}
+	if results[1].OK() {
+		t.Error("expected unreachable switch to fail")
+	}
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
+)
+
+func TestValidateOutputFormat(t *testing.T) {
+	for _, f := range []string{"text", "json", "yaml"} {
+		if err := ValidateOutputFormat(f); err != nil {
+			t.Errorf("ValidateOutputFormat(%q) returned error: %v", f, err)
+		}
+	}
+	if err := ValidateOutputFormat("xml"); err == nil {
+		t.Error("expected error for xml")
+	}
+}
+
+func TestWriteStructured(t *testing.T) {
+	plan := &entities.Plan{
+		Target:   "10.0.0.1",
+		Platform: "ios",
+		Sandbox:  true,
+		Actions: []entities.PlanAction{{
+			Kind:        entities.ActionConfigureAccess,
+			Interface:   "Gi1/0/1",
+			CurrentVlan: "1",
+			TargetVlan:  "10",
+			Mac:         "aa:bb:cc:dd:ee:ff",
+			Rule:        "mac_to_vlan aabbcc",
+			Commands:    []string{"switchport access vlan 10"},
+		}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteStructured(&buf, OutputJSON, plan); err != nil {
+		t.Fatalf("WriteStructured(json) returned error: %v", err)
+	}
+	var decoded entities.Plan
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	if decoded.Actions[0].TargetVlan != "10" || decoded.Actions[0].Rule != "mac_to_vlan aabbcc" {
+		t.Errorf("decoded plan = %+v", decoded)
+	}
+
+	buf.Reset()
+	if err := WriteStructured(&buf, OutputYAML, plan); err != nil {
+		t.Fatalf("WriteStructured(yaml) returned error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "target_vlan: \"10\"") {
+		t.Errorf("unexpected YAML:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteStructured(&buf, OutputText, plan); err != nil || buf.Len() != 0 {
+		t.Errorf("text output should write nothing, got %q, %v", buf.String(), err)
+	}
+}
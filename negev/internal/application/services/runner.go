@@ -1,64 +1,293 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
+	"github.com/carlosrabelo/negev/negev/internal/domain/ports"
 	domainServices "github.com/carlosrabelo/negev/negev/internal/domain/services"
 	"github.com/carlosrabelo/negev/negev/internal/infrastructure/config"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/metrics"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/oui"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/storage"
 	"github.com/carlosrabelo/negev/negev/internal/infrastructure/transport"
 	"github.com/carlosrabelo/negev/negev/internal/platform"
 )
 
+// ErrChangeLimit is returned, along with the refused plan, when a plan exceeds
+// max_changes, max_vlan_deletions or max_fleet_changes.
+var ErrChangeLimit = domainServices.ErrChangeLimit
+
+type RunOptions struct {
+	Sandbox     bool
+	Verbosity   int
+	CreateVLANs bool
+	Output      string
+	// Force writes even outside the change windows of a switch.
+	Force bool
+}
+
 type VLANApplicationService struct {
 	cfg        *config.Config
 	target     string
-	newAdapter func(entities.SwitchConfig) *transport.SwitchAdapter
+	newAdapter AdapterFactory
+	tracker    *RunTracker
+	metrics    *metrics.Registry
+	now        func() time.Time
+
+	vendorsOnce sync.Once
+	vendors     *oui.Registry
+	vendorsErr  error
+}
+
+// AdapterFactory builds the connection used to talk to a switch.
+type AdapterFactory func(entities.SwitchConfig) ports.SwitchRepository
+
+func newSwitchAdapter(sc entities.SwitchConfig) ports.SwitchRepository {
+	return transport.NewSwitchAdapter(sc)
+}
+
+func newPersistentSwitchAdapter(sc entities.SwitchConfig) ports.SwitchRepository {
+	return transport.NewPersistentSwitchAdapter(sc)
 }
 
 func NewVLANApplicationService(cfg *config.Config, target string) *VLANApplicationService {
 	return &VLANApplicationService{
 		cfg:        cfg,
 		target:     target,
-		newAdapter: transport.NewSwitchAdapter,
+		newAdapter: newSwitchAdapter,
+		now:        time.Now,
+	}
+}
+
+// SetAdapterFactory replaces how switch adapters are built, e.g. to keep
+// sessions open in long-running modes or to inject fake clients in tests.
+func (s *VLANApplicationService) SetAdapterFactory(f AdapterFactory) {
+	s.newAdapter = f
+}
+
+// SetTracker makes every run register with t, so a switch is never processed
+// twice at the same time and its last result can be queried.
+func (s *VLANApplicationService) SetTracker(t *RunTracker) {
+	s.tracker = t
+}
+
+// SetMetrics makes every run report to m.
+func (s *VLANApplicationService) SetMetrics(m *metrics.Registry) {
+	s.metrics = m
+}
+
+func (s *VLANApplicationService) Run(opts RunOptions) (*entities.Plan, error) {
+	return s.runTarget(s.target, opts, nil)
+}
+
+// Apply executes a plan recorded by a previous sandbox run. The switch is
+// re-read first and nothing is executed if its state drifted.
+func (s *VLANApplicationService) Apply(plan *entities.Plan, opts RunOptions) error {
+	opts.Sandbox = false
+	opts.CreateVLANs = false
+	if _, reason := s.changeWindow(plan.Target, opts); reason != "" {
+		return fmt.Errorf("%s is %s, use --force to apply anyway", plan.Target, reason)
+	}
+	_, err := s.track(plan.Target, opts, func() (*entities.Plan, error) {
+		svc, err := s.newService(plan.Target, opts)
+		if err != nil {
+			return nil, err
+		}
+		return plan, svc.ApplySavedPlan(plan)
+	})
+	return err
+}
+
+// Inventory reads the ports, MACs and VLANs of the switch without deciding
+// anything.
+func (s *VLANApplicationService) Inventory(opts RunOptions) (*entities.Inventory, error) {
+	return s.inventory(s.target, opts)
+}
+
+func (s *VLANApplicationService) inventory(target string, opts RunOptions) (*entities.Inventory, error) {
+	svc, err := s.newService(target, opts)
+	if err != nil {
+		return nil, err
 	}
+	return svc.Inventory()
 }
 
-func (s *VLANApplicationService) Run(sandbox bool, verbosity int, createVLANs bool) error {
-	var switchCfg *entities.SwitchConfig
-	for i, sc := range s.cfg.Switches {
-		if sc.Target == s.target {
-			switchCfg = &s.cfg.Switches[i]
-			break
+// Rollback undoes the journaled run runID on target, or its latest applied
+// run when runID is empty.
+func (s *VLANApplicationService) Rollback(target, runID string, opts RunOptions) (*entities.Plan, error) {
+	opts.CreateVLANs = false
+	opts, reason := s.changeWindow(target, opts)
+	return s.track(target, opts, func() (*entities.Plan, error) {
+		journal, err := s.Journal()
+		if err != nil {
+			return nil, err
+		}
+		entry, err := journal.Load(target, runID)
+		if err != nil {
+			return nil, err
+		}
+		svc, err := s.newService(target, opts)
+		if err != nil {
+			return nil, err
 		}
+		plan, err := svc.RollbackRun(entry)
+		if plan != nil {
+			plan.SandboxReason = reason
+		}
+		return plan, err
+	})
+}
+
+// Journal returns the rollback journal kept under the configured state_dir.
+func (s *VLANApplicationService) Journal() (*storage.FileJournal, error) {
+	if s.cfg.StateDir == "" {
+		return nil, fmt.Errorf("no state_dir configured, rollback journal unavailable")
 	}
+	return storage.NewFileJournal(filepath.Join(s.cfg.StateDir, "journal")), nil
+}
+
+// runTarget reconciles one switch. A non-nil budget is shared with the other
+// switches of the same fleet run.
+func (s *VLANApplicationService) runTarget(target string, opts RunOptions, budget *domainServices.ChangeBudget) (*entities.Plan, error) {
+	opts, reason := s.changeWindow(target, opts)
+	return s.track(target, opts, func() (*entities.Plan, error) {
+		svc, err := s.newService(target, opts)
+		if err != nil {
+			return nil, err
+		}
+		if budget != nil {
+			svc.SetChangeBudget(budget)
+		}
+		plan, err := svc.ProcessPorts()
+		if plan != nil {
+			plan.SandboxReason = reason
+		}
+		return plan, err
+	})
+}
+
+// changeWindow turns a write outside the change windows of target into a
+// sandbox run, unless opts.Force is set, and returns why.
+func (s *VLANApplicationService) changeWindow(target string, opts RunOptions) (RunOptions, string) {
+	if opts.Sandbox || opts.Force {
+		return opts, ""
+	}
+	sc := s.switchConfig(target)
+	if sc == nil || entities.InChangeWindow(sc.ChangeWindows, s.now()) {
+		return opts, ""
+	}
+	windows := make([]string, len(sc.ChangeWindows))
+	for i, w := range sc.ChangeWindows {
+		windows[i] = w.String()
+	}
+	reason := "outside its change windows (" + strings.Join(windows, "; ") + ")"
+	slog.Warn("Outside change windows — running in sandbox mode, use --force to write anyway", "target", target, "windows", strings.Join(windows, "; "))
+	opts.Sandbox = true
+	return opts, reason
+}
+
+// switchConfig returns a copy of the configuration of target, or nil.
+func (s *VLANApplicationService) switchConfig(target string) *entities.SwitchConfig {
+	for i := range s.cfg.Switches {
+		if s.cfg.Switches[i].Target == target {
+			sc := s.cfg.Switches[i]
+			return &sc
+		}
+	}
+	return nil
+}
+
+func (s *VLANApplicationService) track(target string, opts RunOptions, run func() (*entities.Plan, error)) (*entities.Plan, error) {
+	if s.tracker != nil {
+		if !s.tracker.begin(target) {
+			return nil, ErrTargetBusy
+		}
+	}
+	record := RunRecord{Target: target, Sandbox: opts.Sandbox, StartedAt: time.Now()}
+	defer func() {
+		record.FinishedAt = time.Now()
+		if s.tracker != nil {
+			s.tracker.end(record)
+		}
+	}()
+	plan, err := run()
+	record.Plan = plan
+	if err != nil {
+		record.Error = err.Error()
+	}
+	s.observe(target, opts, plan, err)
+	return plan, err
+}
+
+func (s *VLANApplicationService) observe(target string, opts RunOptions, plan *entities.Plan, err error) {
+	if s.metrics == nil {
+		return
+	}
+	s.metrics.ObserveRun(target, err == nil, time.Now())
+	if plan != nil {
+		for _, skipped := range plan.Skipped {
+			s.metrics.AddPortSkipped(target, string(skipped.Reason))
+		}
+		if err == nil && !opts.Sandbox {
+			s.metrics.AddPortsChanged(target, plan.ChangedPorts())
+		}
+	}
+	var cmdErr *domainServices.CommandError
+	if errors.As(err, &cmdErr) {
+		s.metrics.IncCommandErrors(target)
+	}
+}
+
+func (s *VLANApplicationService) newService(target string, opts RunOptions) (*domainServices.VLANServiceImpl, error) {
+	// The configuration may be shared by concurrent runs (fleet workers, the
+	// daemon and the HTTP API), so runtime flags are set on a private copy.
+	switchCfg := s.switchConfig(target)
 	if switchCfg == nil {
-		return fmt.Errorf("target %s not found in configuration", s.target)
+		return nil, fmt.Errorf("target %s not found in configuration", target)
 	}
 
-	switchCfg.Sandbox = sandbox
-	switchCfg.VerbosityLevel = verbosity
-	switchCfg.CreateVLANs = createVLANs
+	switchCfg.Sandbox = opts.Sandbox
+	switchCfg.VerbosityLevel = opts.Verbosity
+	switchCfg.CreateVLANs = opts.CreateVLANs
+	switchCfg.OutputFormat = opts.Output
+
+	var vendors *oui.Registry
+	if s.cfg.OuiFile != "" {
+		var err error
+		if vendors, err = s.vendorRegistry(); err != nil {
+			return nil, err
+		}
+	}
 
 	adapter := s.newAdapter(*switchCfg)
+	repo := adapter
+	if s.metrics != nil {
+		repo = &timedRepository{SwitchRepository: adapter, target: target, metrics: s.metrics}
+	}
 
 	var driver platform.SwitchDriver
 	platformID := switchCfg.PlatformID()
 	if platformID == "auto" {
-		if err := adapter.Connect(); err != nil {
-			return fmt.Errorf("failed to connect for auto-detection: %v", err)
+		if err := repo.Connect(); err != nil {
+			return nil, fmt.Errorf("failed to connect for auto-detection: %v", err)
 		}
 		var err error
-		driver, err = platform.Detect(adapter)
+		driver, err = platform.Detect(repo)
 		if err != nil {
-			return fmt.Errorf("platform detection failed: %v", err)
+			return nil, fmt.Errorf("platform detection failed: %v", err)
 		}
 		slog.Info("Detected platform", "platform", driver.Name(), "target", switchCfg.Target)
 	} else {
 		driver = platform.Get(platformID)
 		if driver == nil {
-			return fmt.Errorf("unknown platform %q (available: %v)", platformID, platform.Available())
+			return nil, fmt.Errorf("unknown platform %q (available: %v)", platformID, platform.Available())
 		}
 	}
 
@@ -66,7 +295,52 @@ func (s *VLANApplicationService) Run(sandbox bool, verbosity int, createVLANs bo
 		authCfg.SetAuthSequence(driver.GetAuthenticationSequence())
 	}
 
-	driver.ClearCache()
-	svc := domainServices.NewVLANService(adapter, *switchCfg, driver)
-	return svc.ProcessPorts()
+	driver.ClearCache(target)
+	svc := domainServices.NewVLANService(repo, *switchCfg, driver)
+	if s.cfg.StateDir != "" {
+		svc.SetJournal(storage.NewFileJournal(filepath.Join(s.cfg.StateDir, "journal")))
+		svc.SetPortHistory(storage.NewFilePortHistory(filepath.Join(s.cfg.StateDir, "ports")))
+	}
+	if vendors != nil {
+		svc.SetVendorLookup(vendors)
+	}
+	return svc, nil
+}
+
+// vendorRegistry loads oui_file the first time a run needs it; later runs,
+// including concurrent ones, share it.
+func (s *VLANApplicationService) vendorRegistry() (*oui.Registry, error) {
+	s.vendorsOnce.Do(func() {
+		s.vendors, s.vendorsErr = oui.Load(s.cfg.OuiFile)
+		if s.vendorsErr == nil {
+			slog.Info("Loaded OUI registry", "file", s.cfg.OuiFile, "assignments", s.vendors.Len())
+		}
+	})
+	return s.vendors, s.vendorsErr
+}
+
+// timedRepository reports how long it takes to open a session; calls on an
+// already open session (persistent adapters) are not measured.
+type timedRepository struct {
+	ports.SwitchRepository
+	target  string
+	metrics *metrics.Registry
+}
+
+// GetTarget keeps the switch identifiable through the wrapper, which the
+// DmOS driver needs to key its per-switch cache.
+func (r *timedRepository) GetTarget() string {
+	return r.target
+}
+
+func (r *timedRepository) Connect() error {
+	if r.IsConnected() {
+		return r.SwitchRepository.Connect()
+	}
+	start := time.Now()
+	err := r.SwitchRepository.Connect()
+	if err == nil {
+		r.metrics.ObserveConnect(r.target, time.Since(start))
+	}
+	return err
 }
@@ -4,9 +4,12 @@ import (
 	"errors"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
+	"github.com/carlosrabelo/negev/negev/internal/domain/ports"
 	"github.com/carlosrabelo/negev/negev/internal/infrastructure/config"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/metrics"
 	"github.com/carlosrabelo/negev/negev/internal/infrastructure/transport"
 
 	_ "github.com/carlosrabelo/negev/negev/internal/platform/dmos"
@@ -18,6 +21,7 @@ type scriptedClient struct {
 	prompts     []entities.AuthPrompt
 	responses   map[string]string
 	failConnect error
+	executed    []string
 }
 
 func (c *scriptedClient) Connect() error {
@@ -31,6 +35,7 @@ func (c *scriptedClient) Connect() error {
 func (c *scriptedClient) Disconnect() { c.connected = false }
 
 func (c *scriptedClient) ExecuteCommand(cmd string) (string, error) {
+	c.executed = append(c.executed, cmd)
 	if out, ok := c.responses[cmd]; ok {
 		return out, nil
 	}
@@ -78,7 +83,7 @@ Vlan    Mac Address       Type        Ports
 func TestRunTargetNotFound(t *testing.T) {
 	cfg := &config.Config{Switches: []entities.SwitchConfig{{Target: "10.0.0.1"}}}
 	svc := NewVLANApplicationService(cfg, "10.0.0.2")
-	if err := svc.Run(true, 0, false); err == nil {
+	if _, err := svc.Run(RunOptions{Sandbox: true}); err == nil {
 		t.Fatal("expected target not found error")
 	}
 }
@@ -89,10 +94,10 @@ func TestRunUnknownPlatform(t *testing.T) {
 		Platform: "unknown-os",
 	}}}
 	svc := NewVLANApplicationService(cfg, "10.0.0.1")
-	svc.newAdapter = func(sc entities.SwitchConfig) *transport.SwitchAdapter {
+	svc.newAdapter = func(sc entities.SwitchConfig) ports.SwitchRepository {
 		return transport.NewSwitchAdapterWithClient(sc, iosScriptedClient())
 	}
-	if err := svc.Run(true, 0, false); err == nil {
+	if _, err := svc.Run(RunOptions{Sandbox: true}); err == nil {
 		t.Fatal("expected unknown platform error")
 	}
 }
@@ -106,17 +111,22 @@ func TestRunIOSSandboxSuccess(t *testing.T) {
 		MacToVlan:   map[string]string{"aabbcc": "10"},
 	}}}
 	svc := NewVLANApplicationService(cfg, "10.0.0.1")
-	svc.newAdapter = func(sc entities.SwitchConfig) *transport.SwitchAdapter {
+	var used entities.SwitchConfig
+	svc.newAdapter = func(sc entities.SwitchConfig) ports.SwitchRepository {
+		used = sc
 		return transport.NewSwitchAdapterWithClient(sc, cli)
 	}
-	if err := svc.Run(true, 1, false); err != nil {
+	if _, err := svc.Run(RunOptions{Sandbox: true, Verbosity: 1}); err != nil {
 		t.Fatalf("Run failed: %v", err)
 	}
 	if len(cli.prompts) == 0 {
 		t.Fatal("expected auth sequence to be applied")
 	}
-	if cfg.Switches[0].Sandbox != true || cfg.Switches[0].VerbosityLevel != 1 {
-		t.Fatalf("expected sandbox/verbosity flags applied, got %+v", cfg.Switches[0])
+	if used.Sandbox != true || used.VerbosityLevel != 1 {
+		t.Fatalf("expected sandbox/verbosity flags applied, got %+v", used)
+	}
+	if cfg.Switches[0].Sandbox {
+		t.Fatal("expected shared configuration to be left untouched")
 	}
 }
 
@@ -129,10 +139,10 @@ func TestRunAutoDetectSuccess(t *testing.T) {
 		MacToVlan:   map[string]string{"aabbcc": "10"},
 	}}}
 	svc := NewVLANApplicationService(cfg, "10.0.0.1")
-	svc.newAdapter = func(sc entities.SwitchConfig) *transport.SwitchAdapter {
+	svc.newAdapter = func(sc entities.SwitchConfig) ports.SwitchRepository {
 		return transport.NewSwitchAdapterWithClient(sc, cli)
 	}
-	if err := svc.Run(true, 0, false); err != nil {
+	if _, err := svc.Run(RunOptions{Sandbox: true}); err != nil {
 		t.Fatalf("Run auto failed: %v", err)
 	}
 }
@@ -144,10 +154,10 @@ func TestRunAutoDetectConnectFailure(t *testing.T) {
 		Platform: "auto",
 	}}}
 	svc := NewVLANApplicationService(cfg, "10.0.0.1")
-	svc.newAdapter = func(sc entities.SwitchConfig) *transport.SwitchAdapter {
+	svc.newAdapter = func(sc entities.SwitchConfig) ports.SwitchRepository {
 		return transport.NewSwitchAdapterWithClient(sc, cli)
 	}
-	if err := svc.Run(true, 0, false); err == nil {
+	if _, err := svc.Run(RunOptions{Sandbox: true}); err == nil {
 		t.Fatal("expected auto-detect connect failure")
 	}
 }
@@ -163,10 +173,293 @@ func TestRunAutoDetectNoMatch(t *testing.T) {
 		Platform: "auto",
 	}}}
 	svc := NewVLANApplicationService(cfg, "10.0.0.1")
-	svc.newAdapter = func(sc entities.SwitchConfig) *transport.SwitchAdapter {
+	svc.newAdapter = func(sc entities.SwitchConfig) ports.SwitchRepository {
 		return transport.NewSwitchAdapterWithClient(sc, cli)
 	}
-	if err := svc.Run(true, 0, false); err == nil {
+	if _, err := svc.Run(RunOptions{Sandbox: true}); err == nil {
 		t.Fatal("expected platform detection failure")
 	}
 }
+
+func TestApplyRecordedPlan(t *testing.T) {
+	cli := iosScriptedClient()
+	cfg := &config.Config{Switches: []entities.SwitchConfig{{
+		Target:      "10.0.0.1",
+		Platform:    "ios",
+		DefaultVlan: "10",
+		MacToVlan:   map[string]string{"aabbcc": "10"},
+	}}}
+	svc := NewVLANApplicationService(cfg, "10.0.0.1")
+	svc.newAdapter = func(sc entities.SwitchConfig) ports.SwitchRepository {
+		return transport.NewSwitchAdapterWithClient(sc, cli)
+	}
+	plan, err := svc.Run(RunOptions{Sandbox: true, Output: OutputJSON})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if err := svc.Apply(plan, RunOptions{Output: OutputJSON}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if cfg.Switches[0].Sandbox {
+		t.Error("apply must disable sandbox")
+	}
+
+	cli.responses["show interfaces status"] = `
+Port      Name               Status       Vlan       Duplex  Speed Type
+Gi1/0/1                      connected    10         a-full  a-1000 10/100/1000BaseTX
+`
+	if err := svc.Apply(plan, RunOptions{Output: OutputJSON}); err == nil {
+		t.Fatal("expected drift error after port state changed")
+	}
+}
+
+func TestRunReportsMetrics(t *testing.T) {
+	cfg := &config.Config{Switches: []entities.SwitchConfig{
+		{Target: "10.0.0.1", Platform: "ios", DefaultVlan: "10"},
+		{Target: "10.0.0.2", Platform: "ios", DefaultVlan: "10"},
+	}}
+	rejecting := iosScriptedClient()
+	rejecting.responses["switchport access vlan"] = "% Invalid input detected at '^' marker."
+	clients := map[string]*scriptedClient{"10.0.0.1": iosScriptedClient(), "10.0.0.2": rejecting}
+
+	registry := metrics.NewRegistry()
+	svc := NewVLANApplicationService(cfg, "")
+	svc.SetMetrics(registry)
+	svc.SetAdapterFactory(func(sc entities.SwitchConfig) ports.SwitchRepository {
+		return transport.NewSwitchAdapterWithClient(sc, clients[sc.Target])
+	})
+	svc.RunFleet(cfg.Targets(), 1, RunOptions{Output: OutputJSON})
+
+	var buf strings.Builder
+	registry.WriteTo(&buf)
+	out := buf.String()
+	for _, want := range []string{
+		`negev_runs_total{result="success",target="10.0.0.1"} 1`,
+		`negev_runs_total{result="failure",target="10.0.0.2"} 1`,
+		`negev_ports_changed_total{target="10.0.0.1"} 1`,
+		`negev_command_errors_total{target="10.0.0.2"} 1`,
+		`negev_connect_duration_seconds_count{target="10.0.0.1"} 1`,
+		`negev_last_success_timestamp_seconds{target="10.0.0.1"}`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `negev_ports_changed_total{target="10.0.0.2"}`) {
+		t.Error("failed run must not count changed ports")
+	}
+}
+
+func dmosScriptedClient(switchport string) *scriptedClient {
+	return &scriptedClient{
+		responses: map[string]string{
+			"show vlan table": "VLAN 1 [DefaultVlan]:\nVLAN 10 [VLAN_10]:\n",
+			"show interfaces status": `
+Information of Eth 1/1:
+  Link status: Up
+Information of Eth 1/2:
+  Link status: Up
+`,
+			"show interfaces switchport": switchport,
+			"show mac-address-table": `
+      1   Dynamic   Eth 1/1            AA:BB:CC:00:00:01       1     Learned
+      2   Dynamic   Eth 1/2            AA:BB:CC:00:00:02       1     Learned
+`,
+		},
+	}
+}
+
+func TestRunFleetKeepsDmOSSwitchesApartWithMetrics(t *testing.T) {
+	cfg := &config.Config{Switches: []entities.SwitchConfig{
+		{Target: "10.0.0.1", Platform: "dmos", DefaultVlan: "10"},
+		{Target: "10.0.0.2", Platform: "dmos", DefaultVlan: "10"},
+	}}
+	clients := map[string]*scriptedClient{
+		"10.0.0.1": dmosScriptedClient(`
+interface ethernet 1/1
+  Native VLAN: 1
+  Allowed VLANs: 1 (u), 10 (t)
+interface ethernet 1/2
+  Native VLAN: 1
+`),
+		"10.0.0.2": dmosScriptedClient(`
+interface ethernet 1/1
+  Native VLAN: 1
+interface ethernet 1/2
+  Native VLAN: 1
+`),
+	}
+	svc := NewVLANApplicationService(cfg, "")
+	svc.SetMetrics(metrics.NewRegistry())
+	svc.SetAdapterFactory(func(sc entities.SwitchConfig) ports.SwitchRepository {
+		return transport.NewSwitchAdapterWithClient(sc, clients[sc.Target])
+	})
+	for _, r := range svc.RunFleet(cfg.Targets(), 2, RunOptions{Output: OutputJSON}) {
+		if !r.OK() {
+			t.Fatalf("%s failed: %v", r.Target, r.Err)
+		}
+	}
+
+	configured := func(target, iface string) bool {
+		for _, cmd := range clients[target].executed {
+			if cmd == "set-member untagged "+iface {
+				return true
+			}
+		}
+		return false
+	}
+	if configured("10.0.0.1", "ethernet1/1") {
+		t.Error("the uplink of 10.0.0.1 must not become an access port")
+	}
+	if !configured("10.0.0.1", "ethernet1/2") || !configured("10.0.0.2", "ethernet1/1") || !configured("10.0.0.2", "ethernet1/2") {
+		t.Errorf("expected the access ports moved, executed %v and %v", clients["10.0.0.1"].executed, clients["10.0.0.2"].executed)
+	}
+	for target, cli := range clients {
+		reads := 0
+		for _, cmd := range cli.executed {
+			if cmd == "show interfaces switchport" {
+				reads++
+			}
+		}
+		if reads != 1 {
+			t.Errorf("%s: expected switchport read once per run through the metrics wrapper, got %d", target, reads)
+		}
+	}
+}
+
+func TestRollbackUndoesJournaledRun(t *testing.T) {
+	cli := iosScriptedClient()
+	var executed []string
+	cfg := &config.Config{
+		StateDir: t.TempDir(),
+		Switches: []entities.SwitchConfig{{Target: "10.0.0.1", Platform: "ios", DefaultVlan: "10"}},
+	}
+	svc := NewVLANApplicationService(cfg, "10.0.0.1")
+	svc.SetAdapterFactory(func(sc entities.SwitchConfig) ports.SwitchRepository {
+		return &recordingRepository{SwitchRepository: transport.NewSwitchAdapterWithClient(sc, cli), executed: &executed}
+	})
+
+	if _, err := svc.Rollback("10.0.0.1", "", RunOptions{Output: OutputJSON}); err == nil {
+		t.Fatal("expected error with an empty journal")
+	}
+	if _, err := svc.Run(RunOptions{Output: OutputJSON}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	journal, err := svc.Journal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs, err := journal.Runs("10.0.0.1")
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("expected one journaled run, got %v (%v)", runs, err)
+	}
+
+	cli.responses["show interfaces status"] = `
+Port      Name               Status       Vlan       Duplex  Speed Type
+Gi1/0/1                      connected    10         a-full  a-1000 10/100/1000BaseTX
+`
+	executed = nil
+	plan, err := svc.Rollback("10.0.0.1", runs[0], RunOptions{Output: OutputJSON})
+	if err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if len(plan.Actions) != 1 || plan.Actions[0].TargetVlan != "1" {
+		t.Fatalf("unexpected rollback plan: %+v", plan.Actions)
+	}
+	found := false
+	for _, cmd := range executed {
+		if cmd == "switchport access vlan 1" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected port moved back to VLAN 1, executed %v", executed)
+	}
+	if runs, _ := journal.Runs("10.0.0.1"); len(runs) != 2 {
+		t.Fatalf("expected the rollback to be journaled, got %v", runs)
+	}
+
+	executed = nil
+	if _, err := svc.Rollback("10.0.0.1", "", RunOptions{Output: OutputJSON}); err == nil {
+		t.Fatal("expected a second rollback of the same run to fail")
+	}
+	if len(executed) != 0 {
+		t.Fatalf("a refused rollback must not change the switch, executed %v", executed)
+	}
+}
+
+type recordingRepository struct {
+	ports.SwitchRepository
+	executed *[]string
+}
+
+func (r *recordingRepository) ExecuteCommand(cmd string) (string, error) {
+	*r.executed = append(*r.executed, cmd)
+	return r.SwitchRepository.ExecuteCommand(cmd)
+}
+
+func TestRunOutsideChangeWindowDegradesToSandbox(t *testing.T) {
+	cfg := &config.Config{Switches: []entities.SwitchConfig{{
+		Target:        "10.0.0.1",
+		Platform:      "ios",
+		DefaultVlan:   "10",
+		ChangeWindows: []entities.ChangeWindow{{Days: []string{"sat", "sun"}, Timezone: "UTC"}},
+	}}}
+	var executed []string
+	svc := NewVLANApplicationService(cfg, "10.0.0.1")
+	svc.SetAdapterFactory(func(sc entities.SwitchConfig) ports.SwitchRepository {
+		return &recordingRepository{SwitchRepository: transport.NewSwitchAdapterWithClient(sc, iosScriptedClient()), executed: &executed}
+	})
+	svc.now = func() time.Time { return time.Date(2026, time.October, 16, 10, 0, 0, 0, time.UTC) }
+
+	plan, err := svc.Run(RunOptions{Output: OutputJSON})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !plan.Sandbox || !strings.Contains(plan.SandboxReason, "outside its change windows (sat,sun all day UTC)") {
+		t.Fatalf("expected a sandbox run with a reason, got sandbox=%v reason=%q", plan.Sandbox, plan.SandboxReason)
+	}
+	for _, cmd := range executed {
+		if strings.HasPrefix(cmd, "switchport") {
+			t.Fatalf("nothing must be written outside the change windows, executed %v", executed)
+		}
+	}
+	if err := svc.Apply(plan, RunOptions{Output: OutputJSON}); err == nil || !strings.Contains(err.Error(), "use --force") {
+		t.Fatalf("expected apply to be refused, got %v", err)
+	}
+
+	plan, err = svc.Run(RunOptions{Output: OutputJSON, Force: true})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if plan.Sandbox || plan.SandboxReason != "" {
+		t.Fatalf("--force must write, got sandbox=%v reason=%q", plan.Sandbox, plan.SandboxReason)
+	}
+
+	svc.now = func() time.Time { return time.Date(2026, time.October, 17, 10, 0, 0, 0, time.UTC) }
+	executed = nil
+	if plan, err = svc.Run(RunOptions{Output: OutputJSON}); err != nil || plan.Sandbox {
+		t.Fatalf("expected a write inside the window, got %+v, %v", plan, err)
+	}
+}
+
+func TestRunFleetSharesMaxFleetChanges(t *testing.T) {
+	cfg := &config.Config{
+		MaxFleetChanges: 1,
+		Switches: []entities.SwitchConfig{
+			{Target: "10.0.0.1", Platform: "ios", DefaultVlan: "10"},
+			{Target: "10.0.0.2", Platform: "ios", DefaultVlan: "10"},
+		},
+	}
+	svc := NewVLANApplicationService(cfg, "")
+	svc.SetAdapterFactory(func(sc entities.SwitchConfig) ports.SwitchRepository {
+		return transport.NewSwitchAdapterWithClient(sc, iosScriptedClient())
+	})
+	results := svc.RunFleet(cfg.Targets(), 1, RunOptions{Output: OutputJSON})
+	if !results[0].OK() {
+		t.Fatalf("first switch must fit in the budget, got %v", results[0].Err)
+	}
+	if !errors.Is(results[1].Err, ErrChangeLimit) || !results[1].Plan.HasChanges() {
+		t.Fatalf("expected the second switch to be refused with its plan, got %+v", results[1])
+	}
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
+)
+
+var ErrTargetBusy = errors.New("a run is already in progress for this switch")
+
+type RunRecord struct {
+	Target     string         `json:"target"`
+	Sandbox    bool           `json:"sandbox"`
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt time.Time      `json:"finished_at"`
+	Plan       *entities.Plan `json:"plan,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// RunTracker serializes runs per switch across the daemon and the HTTP API
+// and keeps the last result of each one.
+type RunTracker struct {
+	mu   sync.Mutex
+	busy map[string]bool
+	last map[string]RunRecord
+}
+
+func NewRunTracker() *RunTracker {
+	return &RunTracker{busy: make(map[string]bool), last: make(map[string]RunRecord)}
+}
+
+func (t *RunTracker) begin(target string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.busy[target] {
+		return false
+	}
+	t.busy[target] = true
+	return true
+}
+
+func (t *RunTracker) end(record RunRecord) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.busy, record.Target)
+	t.last[record.Target] = record
+}
+
+func (t *RunTracker) Last(target string) (RunRecord, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r, ok := t.last[target]
+	return r, ok
+}
+
+func (t *RunTracker) Busy(target string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.busy[target]
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
+	"github.com/carlosrabelo/negev/negev/internal/domain/ports"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/config"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/transport"
+)
+
+func TestRunTrackerSerializesTargets(t *testing.T) {
+	tracker := NewRunTracker()
+	if !tracker.begin("10.0.0.1") {
+		t.Fatal("expected first begin to succeed")
+	}
+	if tracker.begin("10.0.0.1") {
+		t.Fatal("expected second begin on the same target to fail")
+	}
+	if !tracker.begin("10.0.0.2") {
+		t.Fatal("expected other targets to be independent")
+	}
+	tracker.end(RunRecord{Target: "10.0.0.1", Error: "boom"})
+	if tracker.Busy("10.0.0.1") {
+		t.Fatal("expected target to be released")
+	}
+	if r, ok := tracker.Last("10.0.0.1"); !ok || r.Error != "boom" {
+		t.Fatalf("Last() = %+v, %v", r, ok)
+	}
+	if _, ok := tracker.Last("10.0.0.3"); ok {
+		t.Fatal("expected no record for a switch that never ran")
+	}
+}
+
+func TestTrackedRunRejectsBusyTarget(t *testing.T) {
+	cfg := &config.Config{Switches: []entities.SwitchConfig{{Target: "10.0.0.1", Platform: "ios", DefaultVlan: "10"}}}
+	tracker := NewRunTracker()
+	svc := NewVLANApplicationService(cfg, "10.0.0.1")
+	svc.SetTracker(tracker)
+	svc.SetAdapterFactory(func(sc entities.SwitchConfig) ports.SwitchRepository {
+		return transport.NewSwitchAdapterWithClient(sc, iosScriptedClient())
+	})
+
+	tracker.begin("10.0.0.1")
+	if _, err := svc.Run(RunOptions{Sandbox: true, Output: OutputJSON}); !errors.Is(err, ErrTargetBusy) {
+		t.Fatalf("expected ErrTargetBusy, got %v", err)
+	}
+	tracker.end(RunRecord{Target: "10.0.0.1"})
+
+	plan, err := svc.Run(RunOptions{Sandbox: true, Output: OutputJSON})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	record, ok := tracker.Last("10.0.0.1")
+	if !ok || record.Plan != plan || !record.Sandbox || record.FinishedAt.Before(record.StartedAt) {
+		t.Fatalf("unexpected record: %+v", record)
+	}
+}
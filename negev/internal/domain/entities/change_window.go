@@ -0,0 +1,152 @@
+package entities
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ChangeWindow is a weekly period in which switches may be changed. Days are
+// weekday names ("mon") or ranges of them ("mon-fri"), every day when empty.
+// Hours are "HH:MM-HH:MM" ranges, the whole day when empty; a range ending
+// before it starts runs past midnight into the next day. Times are read in
+// Timezone, an IANA name, or the local time zone when empty.
+type ChangeWindow struct {
+	Days     []string `yaml:"days"`
+	Hours    []string `yaml:"hours"`
+	Timezone string   `yaml:"timezone"`
+}
+
+type minuteRange struct {
+	start, end int
+}
+
+func (w ChangeWindow) Validate() error {
+	_, _, _, err := w.parse()
+	return err
+}
+
+// Contains reports whether t falls inside the window. An invalid window
+// contains nothing.
+func (w ChangeWindow) Contains(t time.Time) bool {
+	days, hours, loc, err := w.parse()
+	if err != nil {
+		return false
+	}
+	t = t.In(loc)
+	minute := t.Hour()*60 + t.Minute()
+	today := int(t.Weekday())
+	yesterday := (today + 6) % 7
+	for _, r := range hours {
+		if r.start < r.end {
+			if days[today] && minute >= r.start && minute < r.end {
+				return true
+			}
+			continue
+		}
+		if (days[today] && minute >= r.start) || (days[yesterday] && minute < r.end) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w ChangeWindow) String() string {
+	days := "every day"
+	if len(w.Days) > 0 {
+		days = strings.Join(w.Days, ",")
+	}
+	hours := "all day"
+	if len(w.Hours) > 0 {
+		hours = strings.Join(w.Hours, ",")
+	}
+	s := days + " " + hours
+	if w.Timezone != "" {
+		s += " " + w.Timezone
+	}
+	return s
+}
+
+func (w ChangeWindow) parse() (days [7]bool, hours []minuteRange, loc *time.Location, err error) {
+	loc = time.Local
+	if w.Timezone != "" {
+		if loc, err = time.LoadLocation(w.Timezone); err != nil {
+			return days, nil, nil, fmt.Errorf("unknown timezone %s", w.Timezone)
+		}
+	}
+
+	if len(w.Days) == 0 {
+		days = [7]bool{true, true, true, true, true, true, true}
+	}
+	for _, d := range w.Days {
+		from, to, isRange := strings.Cut(strings.ToLower(strings.TrimSpace(d)), "-")
+		if !isRange {
+			to = from
+		}
+		first := slices.Index(weekdayNames, strings.TrimSpace(from))
+		last := slices.Index(weekdayNames, strings.TrimSpace(to))
+		if first < 0 || last < 0 {
+			return days, nil, nil, fmt.Errorf("invalid day %s, must be sun, mon, tue, wed, thu, fri, sat or a range such as mon-fri", d)
+		}
+		for i := first; ; i = (i + 1) % 7 {
+			days[i] = true
+			if i == last {
+				break
+			}
+		}
+	}
+
+	if len(w.Hours) == 0 {
+		hours = []minuteRange{{0, 24 * 60}}
+	}
+	for _, h := range w.Hours {
+		from, to, ok := strings.Cut(strings.TrimSpace(h), "-")
+		if !ok {
+			return days, nil, nil, fmt.Errorf("invalid hours %s, must be HH:MM-HH:MM", h)
+		}
+		start, errStart := parseClock(from)
+		end, errEnd := parseClock(to)
+		if errStart != nil || errEnd != nil || start == 24*60 {
+			return days, nil, nil, fmt.Errorf("invalid hours %s, must be HH:MM-HH:MM", h)
+		}
+		if start == end {
+			return days, nil, nil, fmt.Errorf("invalid hours %s, the range is empty", h)
+		}
+		hours = append(hours, minuteRange{start, end})
+	}
+	return days, hours, loc, nil
+}
+
+// parseClock reads "HH:MM" as minutes since midnight; "24:00" is accepted as
+// the end of the day.
+func parseClock(s string) (int, error) {
+	var h, m int
+	s = strings.TrimSpace(s)
+	if n, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil || n != 2 || len(s) != 5 {
+		return 0, fmt.Errorf("invalid time %s", s)
+	}
+	if h == 24 && m == 0 {
+		return 24 * 60, nil
+	}
+	if h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, fmt.Errorf("invalid time %s", s)
+	}
+	return h*60 + m, nil
+}
+
+// InChangeWindow reports whether t falls inside any of windows; without
+// windows changes are always allowed.
+func InChangeWindow(windows []ChangeWindow, t time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+	for _, w := range windows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
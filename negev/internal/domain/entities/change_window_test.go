@@ -0,0 +1,63 @@
+package entities
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChangeWindowContains(t *testing.T) {
+	// 2026-10-16 is a Friday.
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2026, time.October, day, hour, min, 0, 0, time.UTC)
+	}
+	cases := []struct {
+		window ChangeWindow
+		when   time.Time
+		inside bool
+	}{
+		{ChangeWindow{Days: []string{"sat", "sun"}, Timezone: "UTC"}, at(17, 12, 0), true},
+		{ChangeWindow{Days: []string{"sat", "sun"}, Timezone: "UTC"}, at(16, 12, 0), false},
+		{ChangeWindow{Days: []string{"mon-fri"}, Hours: []string{"20:00-23:00"}, Timezone: "UTC"}, at(16, 21, 30), true},
+		{ChangeWindow{Days: []string{"mon-fri"}, Hours: []string{"20:00-23:00"}, Timezone: "UTC"}, at(16, 23, 0), false},
+		{ChangeWindow{Days: []string{"fri-mon"}, Timezone: "UTC"}, at(19, 8, 0), true},
+		{ChangeWindow{Days: []string{"fri-mon"}, Timezone: "UTC"}, at(20, 8, 0), false},
+		// Overnight ranges belong to the day they start on.
+		{ChangeWindow{Days: []string{"fri"}, Hours: []string{"22:00-06:00"}, Timezone: "UTC"}, at(17, 5, 59), true},
+		{ChangeWindow{Days: []string{"fri"}, Hours: []string{"22:00-06:00"}, Timezone: "UTC"}, at(16, 5, 59), false},
+		{ChangeWindow{Hours: []string{"00:00-24:00"}, Timezone: "UTC"}, at(16, 23, 59), true},
+		{ChangeWindow{Hours: []string{"20:00-23:00"}, Timezone: "America/Sao_Paulo"}, at(16, 23, 30), true},
+		{ChangeWindow{Hours: []string{"20:00-23:00"}, Timezone: "America/Sao_Paulo"}, at(16, 21, 30), false},
+	}
+	for _, tc := range cases {
+		if got := tc.window.Contains(tc.when); got != tc.inside {
+			t.Errorf("%s contains %s = %v; expected %v", tc.window, tc.when, got, tc.inside)
+		}
+	}
+
+	if !InChangeWindow(nil, at(16, 12, 0)) {
+		t.Error("no windows must always allow changes")
+	}
+	windows := []ChangeWindow{{Days: []string{"sat"}, Timezone: "UTC"}, {Days: []string{"fri"}, Hours: []string{"12:00-13:00"}, Timezone: "UTC"}}
+	if !InChangeWindow(windows, at(16, 12, 30)) || InChangeWindow(windows, at(16, 13, 30)) {
+		t.Error("unexpected InChangeWindow result")
+	}
+}
+
+func TestChangeWindowValidate(t *testing.T) {
+	for _, bad := range []ChangeWindow{
+		{Days: []string{"funday"}},
+		{Days: []string{"mon-xyz"}},
+		{Hours: []string{"20:00"}},
+		{Hours: []string{"25:00-26:00"}},
+		{Hours: []string{"8:00-9:00"}},
+		{Hours: []string{"10:00-10:00"}},
+		{Timezone: "Mars/Olympus_Mons"},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%+v: expected error", bad)
+		}
+	}
+	if err := (ChangeWindow{Days: []string{"Mon - Fri"}, Hours: []string{"22:00-06:00"}, Timezone: "UTC"}).Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
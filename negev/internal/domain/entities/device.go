@@ -5,4 +5,7 @@ type Device struct {
 	Mac       string
 	MacFull   string
 	Interface string
+	// Vendor is the organization the MAC was assigned to, when an OUI
+	// registry is loaded and knows it.
+	Vendor string
 }
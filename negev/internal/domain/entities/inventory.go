@@ -0,0 +1,26 @@
+package entities
+
+// InventoryMac is a MAC address learned on a port.
+type InventoryMac struct {
+	Mac      string `json:"mac" yaml:"mac"`
+	OUI      string `json:"oui" yaml:"oui"`
+	Vendor   string `json:"vendor,omitempty" yaml:"vendor,omitempty"`
+	Excluded bool   `json:"excluded" yaml:"excluded"`
+	Blocked  bool   `json:"blocked,omitempty" yaml:"blocked,omitempty"`
+}
+
+// InventoryPort is an active port as observed on the switch.
+type InventoryPort struct {
+	Interface string         `json:"interface" yaml:"interface"`
+	Vlan      string         `json:"vlan" yaml:"vlan"`
+	Trunk     bool           `json:"trunk" yaml:"trunk"`
+	Excluded  bool           `json:"excluded" yaml:"excluded"`
+	Macs      []InventoryMac `json:"macs" yaml:"macs"`
+}
+
+type Inventory struct {
+	Target   string          `json:"target" yaml:"target"`
+	Platform string          `json:"platform" yaml:"platform"`
+	Vlans    []string        `json:"vlans" yaml:"vlans"`
+	Ports    []InventoryPort `json:"ports" yaml:"ports"`
+}
@@ -0,0 +1,15 @@
+package entities
+
+import "time"
+
+// JournalEntry records what an applied run was about to change, so the run
+// can be undone later.
+type JournalEntry struct {
+	RunID     string       `json:"run_id"`
+	Target    string       `json:"target"`
+	Platform  string       `json:"platform"`
+	CreatedAt time.Time    `json:"created_at"`
+	Actions   []PlanAction `json:"actions"`
+	// RollbackOf is the run this entry undid, when it was a rollback.
+	RollbackOf string `json:"rollback_of,omitempty"`
+}
@@ -0,0 +1,98 @@
+package entities
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	MinMacPrefixBits = 24
+	MaxMacPrefixBits = 48
+)
+
+// MacPrefix is a mac_to_vlan key: the first Bits bits of a MAC address, from
+// a plain OUI (24 bits) through MA-M/MA-S blocks (28/36 bits) down to a single
+// device (48 bits).
+type MacPrefix struct {
+	Hex  string
+	Bits int
+}
+
+// ParseMacPrefix reads a normalized key: lowercase hex without separators,
+// optionally followed by /bits. Without a mask every digit counts, so
+// "001122a" is the same block as "001122a/28" and "001122/28".
+func ParseMacPrefix(key string) (MacPrefix, error) {
+	hex, mask, hasMask := strings.Cut(key, "/")
+	if hex == "" || strings.Trim(hex, "0123456789abcdef") != "" {
+		return MacPrefix{}, fmt.Errorf("not hexadecimal")
+	}
+	if len(hex) > MaxMacPrefixBits/4 {
+		return MacPrefix{}, fmt.Errorf("longer than a MAC address")
+	}
+	bits := len(hex) * 4
+	if hasMask {
+		n, err := strconv.Atoi(mask)
+		if err != nil {
+			return MacPrefix{}, fmt.Errorf("invalid mask %q", mask)
+		}
+		bits = n
+	}
+	if bits < MinMacPrefixBits || bits > MaxMacPrefixBits {
+		return MacPrefix{}, fmt.Errorf("must cover between %d and %d bits", MinMacPrefixBits, MaxMacPrefixBits)
+	}
+
+	digits := (bits + 3) / 4
+	hex += strings.Repeat("0", MaxMacPrefixBits/4-len(hex))
+	hex = hex[:digits]
+	if rem := bits % 4; rem != 0 {
+		last, _ := strconv.ParseUint(hex[digits-1:], 16, 8)
+		last &^= 1<<(4-rem) - 1
+		hex = hex[:digits-1] + strconv.FormatUint(last, 16)
+	}
+	return MacPrefix{Hex: hex, Bits: bits}, nil
+}
+
+// String is the canonical key: the hex digits alone when the mask falls on a
+// digit boundary, otherwise hex/bits.
+func (p MacPrefix) String() string {
+	if p.Bits%4 == 0 {
+		return p.Hex
+	}
+	return fmt.Sprintf("%s/%d", p.Hex, p.Bits)
+}
+
+// Matches reports whether mac, 12 lowercase hex digits, is inside the prefix.
+func (p MacPrefix) Matches(mac string) bool {
+	full := p.Bits / 4
+	if len(mac) < len(p.Hex) || mac[:full] != p.Hex[:full] {
+		return false
+	}
+	rem := p.Bits % 4
+	if rem == 0 {
+		return true
+	}
+	got, err := strconv.ParseUint(mac[full:full+1], 16, 8)
+	if err != nil {
+		return false
+	}
+	want, _ := strconv.ParseUint(p.Hex[full:], 16, 8)
+	mask := uint64(0xf) &^ (1<<(4-rem) - 1)
+	return got&mask == want
+}
+
+// Contains reports whether every MAC of q is also inside p.
+func (p MacPrefix) Contains(q MacPrefix) bool {
+	return q.Bits >= p.Bits && p.Matches(q.Hex+strings.Repeat("0", MaxMacPrefixBits/4-len(q.Hex)))
+}
+
+// IsRandomizedMac reports whether the normalized mac has the locally
+// administered bit set, as the private addresses of phones and laptops do.
+// Such a MAC belongs to no vendor and changes over time.
+func IsRandomizedMac(mac string) bool {
+	if len(mac) < 2 {
+		return false
+	}
+	b, err := strconv.ParseUint(mac[:2], 16, 8)
+	return err == nil && b&0x02 != 0
+}
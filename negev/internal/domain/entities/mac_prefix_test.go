@@ -0,0 +1,75 @@
+package entities
+
+import "testing"
+
+func TestParseMacPrefix(t *testing.T) {
+	cases := []struct {
+		key       string
+		canonical string
+		bits      int
+	}{
+		{"001122", "001122", 24},
+		{"001122a", "001122a", 28},
+		{"001122/28", "0011220", 28},
+		{"001122ab/28", "001122a", 28},
+		{"001122abc", "001122abc", 36},
+		{"001122334455", "001122334455", 48},
+		{"001122f/26", "001122c/26", 26},
+	}
+	for _, tc := range cases {
+		p, err := ParseMacPrefix(tc.key)
+		if err != nil {
+			t.Fatalf("ParseMacPrefix(%q) returned error: %v", tc.key, err)
+		}
+		if p.String() != tc.canonical || p.Bits != tc.bits {
+			t.Errorf("ParseMacPrefix(%q) = %s (%d bits); expected %s (%d bits)", tc.key, p, p.Bits, tc.canonical, tc.bits)
+		}
+	}
+
+	for _, key := range []string{"", "xyz123", "00112", "0011223344556", "001122/20", "001122/49", "001122/x"} {
+		if _, err := ParseMacPrefix(key); err == nil {
+			t.Errorf("ParseMacPrefix(%q) expected error", key)
+		}
+	}
+}
+
+func TestMacPrefixMatches(t *testing.T) {
+	cases := []struct {
+		key   string
+		mac   string
+		match bool
+	}{
+		{"001122", "001122334455", true},
+		{"001122", "001123334455", false},
+		{"0011223", "001122334455", true},
+		{"0011224", "001122334455", false},
+		{"001122c/26", "001122dfffff", true},
+		{"001122c/26", "001122bfffff", false},
+		{"001122334455", "001122334455", true},
+		{"001122334455", "001122334456", false},
+	}
+	for _, tc := range cases {
+		p, err := ParseMacPrefix(tc.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.Matches(tc.mac); got != tc.match {
+			t.Errorf("%s.Matches(%s) = %v; expected %v", tc.key, tc.mac, got, tc.match)
+		}
+	}
+}
+
+func TestIsRandomizedMac(t *testing.T) {
+	for mac, expected := range map[string]bool{
+		"001122334455": false,
+		"021122334455": true,
+		"da1122334455": true,
+		"fc1122334455": false,
+		"":             false,
+		"zz1122334455": false,
+	} {
+		if got := IsRandomizedMac(mac); got != expected {
+			t.Errorf("IsRandomizedMac(%q) = %v; expected %v", mac, got, expected)
+		}
+	}
+}
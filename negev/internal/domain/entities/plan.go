@@ -0,0 +1,127 @@
+package entities
+
+type ActionKind string
+
+const (
+	ActionConfigureAccess ActionKind = "configure_access"
+	ActionConfigureVoice  ActionKind = "configure_voice"
+	ActionCreateVLAN      ActionKind = "create_vlan"
+	ActionDeleteVLAN      ActionKind = "delete_vlan"
+	ActionRenameVLAN      ActionKind = "rename_vlan"
+	ActionDescribePort    ActionKind = "describe_port"
+	ActionShutdownPort    ActionKind = "shutdown_port"
+	ActionEnablePort      ActionKind = "enable_port"
+	ActionTrunkAddVLAN    ActionKind = "trunk_add_vlan"
+	ActionTrunkRemoveVLAN ActionKind = "trunk_remove_vlan"
+)
+
+type PlanAction struct {
+	Kind        ActionKind `json:"kind" yaml:"kind"`
+	Interface   string     `json:"interface,omitempty" yaml:"interface,omitempty"`
+	Vlan        string     `json:"vlan,omitempty" yaml:"vlan,omitempty"`
+	CurrentVlan string     `json:"current_vlan,omitempty" yaml:"current_vlan,omitempty"`
+	TargetVlan  string     `json:"target_vlan,omitempty" yaml:"target_vlan,omitempty"`
+	// Name is the VLAN name created, deleted or renamed to; CurrentName is
+	// the name a renamed VLAN had.
+	Name        string `json:"name,omitempty" yaml:"name,omitempty"`
+	CurrentName string `json:"current_name,omitempty" yaml:"current_name,omitempty"`
+	// Description is the port description written; CurrentDescription is
+	// the one it replaced.
+	Description        string   `json:"description,omitempty" yaml:"description,omitempty"`
+	CurrentDescription string   `json:"current_description,omitempty" yaml:"current_description,omitempty"`
+	Mac                string   `json:"mac,omitempty" yaml:"mac,omitempty"`
+	Vendor             string   `json:"vendor,omitempty" yaml:"vendor,omitempty"`
+	Rule               string   `json:"rule,omitempty" yaml:"rule,omitempty"`
+	Commands           []string `json:"commands" yaml:"commands"`
+}
+
+func (a PlanAction) Describe() string {
+	switch a.Kind {
+	case ActionCreateVLAN:
+		return "create VLAN " + a.Vlan
+	case ActionDeleteVLAN:
+		return "delete VLAN " + a.Vlan
+	case ActionRenameVLAN:
+		return "rename VLAN " + a.Vlan + " to " + a.Name
+	case ActionConfigureAccess:
+		return "configure VLAN on port " + a.Interface
+	case ActionConfigureVoice:
+		return "configure voice VLAN on port " + a.Interface
+	case ActionDescribePort:
+		return "describe port " + a.Interface
+	case ActionShutdownPort:
+		return "shut down port " + a.Interface
+	case ActionEnablePort:
+		return "enable port " + a.Interface
+	case ActionTrunkAddVLAN:
+		return "add VLAN " + a.Vlan + " to trunk " + a.Interface
+	case ActionTrunkRemoveVLAN:
+		return "remove VLAN " + a.Vlan + " from trunk " + a.Interface
+	default:
+		return string(a.Kind)
+	}
+}
+
+type SkipReason string
+
+const (
+	SkipTrunk        SkipReason = "trunk"
+	SkipExcluded     SkipReason = "excluded"
+	SkipMultipleMacs SkipReason = "multiple_macs"
+	SkipMissingVLAN  SkipReason = "missing_vlan"
+	SkipRandomMac    SkipReason = "random_mac"
+	// SkipPending marks a port that would move because of a MAC not yet
+	// seen for min_observations runs or min_age.
+	SkipPending SkipReason = "pending"
+)
+
+// SkippedPort records an active port left untouched and why.
+type SkippedPort struct {
+	Interface string     `json:"interface" yaml:"interface"`
+	Reason    SkipReason `json:"reason" yaml:"reason"`
+}
+
+// PortAlert flags a port with more MACs than max_port_macs, which usually
+// means an unauthorized hub or switch behind it.
+type PortAlert struct {
+	Interface string `json:"interface" yaml:"interface"`
+	Macs      int    `json:"macs" yaml:"macs"`
+	Limit     int    `json:"limit" yaml:"limit"`
+}
+
+type Plan struct {
+	Target   string `json:"target" yaml:"target"`
+	Platform string `json:"platform" yaml:"platform"`
+	Sandbox  bool   `json:"sandbox" yaml:"sandbox"`
+	// SandboxReason says why a run asked to write was simulated instead.
+	SandboxReason string        `json:"sandbox_reason,omitempty" yaml:"sandbox_reason,omitempty"`
+	Fingerprint   string        `json:"fingerprint" yaml:"fingerprint"`
+	Actions       []PlanAction  `json:"actions" yaml:"actions"`
+	Skipped       []SkippedPort `json:"skipped,omitempty" yaml:"skipped,omitempty"`
+	Alerts        []PortAlert   `json:"alerts,omitempty" yaml:"alerts,omitempty"`
+	// RandomMacPorts counts the ports with a randomized MAC left to
+	// random_mac_policy, whatever the policy did with them.
+	RandomMacPorts int `json:"random_mac_ports,omitempty" yaml:"random_mac_ports,omitempty"`
+	// RollbackOf is the journaled run the plan undoes.
+	RollbackOf string `json:"rollback_of,omitempty" yaml:"rollback_of,omitempty"`
+}
+
+func (p *Plan) HasChanges() bool {
+	return p != nil && len(p.Actions) > 0
+}
+
+// ChangedPorts counts the ports the plan moves, shuts down or enables, once
+// per port even when both its data and voice VLAN change.
+func (p *Plan) ChangedPorts() int {
+	if p == nil {
+		return 0
+	}
+	seen := make(map[string]bool)
+	for _, a := range p.Actions {
+		switch a.Kind {
+		case ActionConfigureAccess, ActionConfigureVoice, ActionShutdownPort, ActionEnablePort:
+			seen[a.Interface] = true
+		}
+	}
+	return len(seen)
+}
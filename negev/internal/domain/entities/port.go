@@ -3,4 +3,8 @@ package entities
 type Port struct {
 	Interface string
 	Vlan      string
+	// VoiceVlan is only read when voice_vlan is configured; empty means none.
+	VoiceVlan string
+	// Description is only read when port_description is configured.
+	Description string
 }
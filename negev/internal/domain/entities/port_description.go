@@ -0,0 +1,55 @@
+package entities
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// PortDescriptionMarker starts every description negev writes; descriptions
+// without it were written by someone else and are left alone.
+const PortDescriptionMarker = "negev:"
+
+// MaxPortDescriptionLength keeps descriptions short enough for every
+// supported platform.
+const MaxPortDescriptionLength = 64
+
+var descriptionFieldRegex = regexp.MustCompile(`\{[^{}]*\}`)
+
+// PortDescriptionFields are the placeholders a port_description template may
+// use.
+var PortDescriptionFields = []string{"vendor", "mac", "rule", "vlan"}
+
+// ValidatePortDescription checks a port_description template.
+func ValidatePortDescription(template string) error {
+	if !strings.HasPrefix(template, PortDescriptionMarker) {
+		return fmt.Errorf("must start with %s so negev can recognize its own descriptions", PortDescriptionMarker)
+	}
+	for _, field := range descriptionFieldRegex.FindAllString(template, -1) {
+		name := strings.Trim(field, "{}")
+		if !slices.Contains(PortDescriptionFields, name) {
+			return fmt.Errorf("unknown placeholder %s, must be one of {%s}", field, strings.Join(PortDescriptionFields, "}, {"))
+		}
+	}
+	return nil
+}
+
+// RenderPortDescription fills template with fields. Whitespace becomes "_"
+// and the result is cut to MaxPortDescriptionLength.
+func RenderPortDescription(template string, fields map[string]string) string {
+	s := descriptionFieldRegex.ReplaceAllStringFunc(template, func(field string) string {
+		return fields[strings.Trim(field, "{}")]
+	})
+	s = strings.Join(strings.Fields(s), "_")
+	if len(s) > MaxPortDescriptionLength {
+		s = s[:MaxPortDescriptionLength]
+	}
+	return s
+}
+
+// ManagedDescription reports whether negev may overwrite description: it is
+// empty or was written by negev.
+func ManagedDescription(description string) bool {
+	return description == "" || strings.HasPrefix(description, PortDescriptionMarker)
+}
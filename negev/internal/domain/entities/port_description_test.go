@@ -0,0 +1,41 @@
+package entities
+
+import "testing"
+
+func TestRenderPortDescription(t *testing.T) {
+	fields := map[string]string{"vendor": "aabbcc", "mac": "aa:bb:cc:dd:ee:ff", "rule": "mac_to_vlan aabbcc", "vlan": "30"}
+	if got := RenderPortDescription("negev:{vendor}:{mac}:{rule}", fields); got != "negev:aabbcc:aa:bb:cc:dd:ee:ff:mac_to_vlan_aabbcc" {
+		t.Errorf("RenderPortDescription() = %q", got)
+	}
+	if got := RenderPortDescription("negev: vlan {vlan}", fields); got != "negev:_vlan_30" {
+		t.Errorf("RenderPortDescription() = %q", got)
+	}
+	long := RenderPortDescription("negev:{rule}{rule}{rule}{rule}", fields)
+	if len(long) != MaxPortDescriptionLength {
+		t.Errorf("expected description cut to %d characters, got %q", MaxPortDescriptionLength, long)
+	}
+}
+
+func TestValidatePortDescription(t *testing.T) {
+	if err := ValidatePortDescription("negev:{vendor}:{mac}:{rule}:{vlan}"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, bad := range []string{"{mac}", "port:{mac}", "negev:{owner}"} {
+		if err := ValidatePortDescription(bad); err == nil {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
+
+func TestManagedDescription(t *testing.T) {
+	for description, managed := range map[string]bool{
+		"":                   true,
+		"negev:aabbcc":       true,
+		"Printer 2nd floor":  false,
+		"uplink negev:trunk": false,
+	} {
+		if got := ManagedDescription(description); got != managed {
+			t.Errorf("ManagedDescription(%q) = %v; expected %v", description, got, managed)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package entities
+
+import "time"
+
+// MacHistoryRetention is how long a MAC address no longer seen on a port is
+// remembered.
+const MacHistoryRetention = 30 * 24 * time.Hour
+
+// PortHistory is what negev remembers about the ports of a switch between
+// runs, keyed by lowercase interface name.
+type PortHistory struct {
+	Target string `json:"target"`
+	// LastRun is when the history was last updated.
+	LastRun time.Time             `json:"last_run,omitzero"`
+	Ports   map[string]PortRecord `json:"ports"`
+}
+
+type PortRecord struct {
+	// NoMacSince is when the port was first seen up without any learned MAC
+	// address; it is cleared as soon as a device shows up.
+	NoMacSince time.Time `json:"no_mac_since,omitzero"`
+	// Macs holds the MAC addresses seen on the port, normalized.
+	Macs map[string]MacRecord `json:"macs,omitempty"`
+}
+
+// MacRecord tracks one MAC address on one port. Since and Observations
+// describe the current streak: when the MAC came back after missing a run,
+// and in how many consecutive runs it has been seen since.
+type MacRecord struct {
+	FirstSeen    time.Time `json:"first_seen"`
+	LastSeen     time.Time `json:"last_seen"`
+	Since        time.Time `json:"since"`
+	Observations int       `json:"observations"`
+}
+
+// Observe records that mac was seen on the port in the run at now; last is
+// when the previous run happened.
+func (r *MacRecord) Observe(now, last time.Time) {
+	if r.FirstSeen.IsZero() {
+		r.FirstSeen = now
+	}
+	if !r.LastSeen.IsZero() && r.LastSeen.Equal(last) {
+		r.Observations++
+	} else {
+		r.Since = now
+		r.Observations = 1
+	}
+	r.LastSeen = now
+}
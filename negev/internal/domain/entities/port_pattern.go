@@ -0,0 +1,108 @@
+package entities
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const portRegexPrefix = "re:"
+
+var portRangeRegex = regexp.MustCompile(`^(.*\D)(\d+)-(\d+)$`)
+
+// PortPattern selects interfaces by name, ignoring case and spaces. It is
+// written as an exact name ("Gi1/0/1"), an IOS-style range on the last number
+// ("Gi1/0/40-48"), a glob with * and ? ("Te*") or a regular expression after
+// "re:" ("re:^Gi1/0/(1|2)$").
+type PortPattern struct {
+	raw    string
+	exact  string
+	prefix string
+	lo, hi int
+	re     *regexp.Regexp
+}
+
+func ParsePortPattern(s string) (PortPattern, error) {
+	p := PortPattern{raw: s}
+	if expr, ok := strings.CutPrefix(s, portRegexPrefix); ok {
+		re, err := regexp.Compile("(?i)" + expr)
+		if err != nil {
+			return PortPattern{}, fmt.Errorf("invalid regular expression: %v", err)
+		}
+		p.re = re
+		return p, nil
+	}
+
+	name := normalizePortName(s)
+	if name == "" {
+		return PortPattern{}, fmt.Errorf("empty port pattern")
+	}
+	if strings.ContainsAny(name, "*?") {
+		var expr strings.Builder
+		expr.WriteString("^")
+		for _, r := range name {
+			switch r {
+			case '*':
+				expr.WriteString(".*")
+			case '?':
+				expr.WriteString(".")
+			default:
+				expr.WriteString(regexp.QuoteMeta(string(r)))
+			}
+		}
+		expr.WriteString("$")
+		p.re = regexp.MustCompile(expr.String())
+		return p, nil
+	}
+	if m := portRangeRegex.FindStringSubmatch(name); m != nil {
+		lo, _ := strconv.Atoi(m[2])
+		hi, _ := strconv.Atoi(m[3])
+		if lo > hi {
+			return PortPattern{}, fmt.Errorf("range %d-%d is reversed", lo, hi)
+		}
+		p.prefix, p.lo, p.hi = m[1], lo, hi
+		return p, nil
+	}
+	p.exact = name
+	return p, nil
+}
+
+func (p PortPattern) String() string {
+	return p.raw
+}
+
+// IsName reports whether the pattern selects a single interface by name, or
+// a range of them, as opposed to a glob or regular expression.
+func (p PortPattern) IsName() bool {
+	return p.re == nil
+}
+
+// FirstName is the exact name, or the first interface of a range.
+func (p PortPattern) FirstName() string {
+	if p.prefix != "" {
+		return p.prefix + strconv.Itoa(p.lo)
+	}
+	return p.exact
+}
+
+func (p PortPattern) Match(iface string) bool {
+	name := normalizePortName(iface)
+	switch {
+	case p.re != nil:
+		return p.re.MatchString(name)
+	case p.prefix != "":
+		rest, ok := strings.CutPrefix(name, p.prefix)
+		if !ok {
+			return false
+		}
+		n, err := strconv.Atoi(rest)
+		return err == nil && n >= p.lo && n <= p.hi && strconv.Itoa(n) == rest
+	default:
+		return name == p.exact
+	}
+}
+
+func normalizePortName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), " ", ""))
+}
@@ -0,0 +1,50 @@
+package entities
+
+import "testing"
+
+func TestPortPatternMatch(t *testing.T) {
+	cases := []struct {
+		pattern string
+		iface   string
+		match   bool
+	}{
+		{"Gi1/0/1", "gi1/0/1", true},
+		{"Gi1/0/1", "Gi1/0/10", false},
+		{"ethernet 1/1", "ethernet1/1", true},
+		{"Gi1/0/40-48", "Gi1/0/40", true},
+		{"Gi1/0/40-48", "Gi1/0/48", true},
+		{"Gi1/0/40-48", "Gi1/0/49", false},
+		{"Gi1/0/40-48", "Gi1/0/4", false},
+		{"Gi1/0/40-48", "Gi1/0/045", false},
+		{"Gi1/0/40-48", "Gi2/0/45", false},
+		{"Te*", "Te1/1/1", true},
+		{"te*", "Gi1/0/1", false},
+		{"Gi1/0/?", "Gi1/0/7", true},
+		{"Gi1/0/?", "Gi1/0/17", false},
+		{"re:^Gi1/0/(1|2)$", "gi1/0/2", true},
+		{"re:^Gi1/0/(1|2)$", "Gi1/0/12", false},
+	}
+	for _, tc := range cases {
+		p, err := ParsePortPattern(tc.pattern)
+		if err != nil {
+			t.Fatalf("ParsePortPattern(%q) returned error: %v", tc.pattern, err)
+		}
+		if got := p.Match(tc.iface); got != tc.match {
+			t.Errorf("%q.Match(%q) = %v; expected %v", tc.pattern, tc.iface, got, tc.match)
+		}
+	}
+
+	for _, bad := range []string{"", "Gi1/0/48-40", "re:(unclosed"} {
+		if _, err := ParsePortPattern(bad); err == nil {
+			t.Errorf("ParsePortPattern(%q) expected error", bad)
+		}
+	}
+
+	p, _ := ParsePortPattern("Gi1/0/40-48")
+	if !p.IsName() || p.FirstName() != "gi1/0/40" {
+		t.Errorf("range pattern: IsName=%v FirstName=%q", p.IsName(), p.FirstName())
+	}
+	if p, _ := ParsePortPattern("Te*"); p.IsName() {
+		t.Error("glob must not be a name")
+	}
+}
@@ -1,23 +1,88 @@
 package entities
 
+import "time"
+
+// Values of multi_mac_policy, deciding what to do with a port that has more
+// than one MAC.
+const (
+	MultiMacSkip         = "skip"
+	MultiMacSameVlanOnly = "same-vlan-only"
+	MultiMacMajority     = "majority"
+	MultiMacFirstMatch   = "first-match"
+)
+
+var MultiMacPolicies = []string{MultiMacSkip, MultiMacSameVlanOnly, MultiMacMajority, MultiMacFirstMatch}
+
+// Values of random_mac_policy, deciding where a port goes because of a
+// randomized MAC that no mac_to_vlan key matches.
+const (
+	RandomMacDefaultVlan = "default-vlan"
+	RandomMacSkip        = "skip"
+	RandomMacQuarantine  = "quarantine"
+)
+
+var RandomMacPolicies = []string{RandomMacDefaultVlan, RandomMacSkip, RandomMacQuarantine}
+
+// Values of block_action, deciding what to do with a port where a block_macs
+// MAC shows up.
+const (
+	BlockQuarantine = "quarantine"
+	BlockShutdown   = "shutdown"
+)
+
+var BlockActions = []string{BlockQuarantine, BlockShutdown}
+
+// DefaultProtectedRanges are the VLANs never deleted when protected_ranges is
+// not set.
+var DefaultProtectedRanges = []string{"1000-4094"}
+
+// PortRule pins the ports matching any of Ports to Vlan, or leaves them alone
+// when Exclude is set, whatever MACs they have. Ports are PortPattern values.
+type PortRule struct {
+	Ports   []string `yaml:"ports"`
+	Vlan    string   `yaml:"vlan"`
+	Exclude bool     `yaml:"exclude"`
+}
+
 type SwitchConfig struct {
-	Platform       string            `yaml:"platform"`
-	LegacyPlatform string            `yaml:"vendor"`
-	Target         string            `yaml:"target"`
-	Transport      string            `yaml:"transport"`
-	Username       string            `yaml:"username"`
-	Password       string            `yaml:"password"`
-	EnablePassword string            `yaml:"enable_password"`
-	MacToVlan      map[string]string `yaml:"mac_to_vlan"`
-	ExcludeMacs    []string          `yaml:"exclude_macs"`
-	ExcludePorts   []string          `yaml:"exclude_ports"`
-	DefaultVlan    string            `yaml:"default_vlan"`
-	NoDataVlan     string            `yaml:"no_data_vlan"`
-	AllowedVlans   []string          `yaml:"allowed_vlans"`
-	ProtectedVlans []string          `yaml:"protected_vlans"`
-	Sandbox        bool
-	VerbosityLevel int
-	CreateVLANs    bool
+	Platform         string            `yaml:"platform"`
+	LegacyPlatform   string            `yaml:"vendor"`
+	Target           string            `yaml:"target"`
+	Group            string            `yaml:"group"`
+	Tags             map[string]string `yaml:"tags"`
+	Transport        string            `yaml:"transport"`
+	Username         string            `yaml:"username"`
+	Password         string            `yaml:"password"`
+	EnablePassword   string            `yaml:"enable_password"`
+	MacToVlan        map[string]string `yaml:"mac_to_vlan"`
+	VendorToVlan     map[string]string `yaml:"vendor_to_vlan"`
+	ExcludeMacs      []string          `yaml:"exclude_macs"`
+	BlockMacs        []string          `yaml:"block_macs"`
+	BlockAction      string            `yaml:"block_action"`
+	ExcludePorts     []string          `yaml:"exclude_ports"`
+	UplinkPorts      []string          `yaml:"uplink_ports"`
+	PortRules        []PortRule        `yaml:"port_rules"`
+	DefaultVlan      string            `yaml:"default_vlan"`
+	NoDataVlan       string            `yaml:"no_data_vlan"`
+	NoDataAfter      string            `yaml:"no_data_after"`
+	VoiceVlan        string            `yaml:"voice_vlan"`
+	VoiceMacs        []string          `yaml:"voice_macs"`
+	MultiMacPolicy   string            `yaml:"multi_mac_policy"`
+	RandomMacPolicy  string            `yaml:"random_mac_policy"`
+	MaxPortMacs      int               `yaml:"max_port_macs"`
+	MinObservations  int               `yaml:"min_observations"`
+	MinAge           string            `yaml:"min_age"`
+	MaxChanges       int               `yaml:"max_changes"`
+	MaxVlanDeletions int               `yaml:"max_vlan_deletions"`
+	PortDescription  string            `yaml:"port_description"`
+	AllowedVlans     []Vlan            `yaml:"allowed_vlans"`
+	ProtectedVlans   []string          `yaml:"protected_vlans"`
+	ProtectedRanges  []string          `yaml:"protected_ranges"`
+	ChangeWindows    []ChangeWindow    `yaml:"change_windows"`
+	Sandbox          bool
+	VerbosityLevel   int
+	CreateVLANs      bool
+	OutputFormat     string
 }
 
 func (sc SwitchConfig) IsDebugEnabled() bool {
@@ -28,6 +93,72 @@ func (sc SwitchConfig) IsRawOutputEnabled() bool {
 	return sc.VerbosityLevel == 2 || sc.VerbosityLevel == 3
 }
 
+// IsTextOutput reports whether human-readable progress may be printed to
+// stdout; structured formats (json, yaml) need stdout for the plan itself.
+func (sc SwitchConfig) IsTextOutput() bool {
+	return sc.OutputFormat == "" || sc.OutputFormat == "text"
+}
+
+// NoDataPeriod reports how long a port must stay up without a learned MAC
+// before it is moved to no_data_vlan; ok is false when no_data_after is not
+// set and such ports are left alone.
+func (sc SwitchConfig) NoDataPeriod() (period time.Duration, ok bool) {
+	if sc.NoDataAfter == "" {
+		return 0, false
+	}
+	d, err := time.ParseDuration(sc.NoDataAfter)
+	if err != nil || d < 0 {
+		return 0, false
+	}
+	return d, true
+}
+
+// MinMacAge is how long a MAC must have been seen on a port before the port
+// is moved because of it; zero when min_age is not set.
+func (sc SwitchConfig) MinMacAge() time.Duration {
+	d, err := time.ParseDuration(sc.MinAge)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+func (sc SwitchConfig) MultiMacPolicyOrDefault() string {
+	if sc.MultiMacPolicy == "" {
+		return MultiMacSkip
+	}
+	return sc.MultiMacPolicy
+}
+
+func (sc SwitchConfig) RandomMacPolicyOrDefault() string {
+	if sc.RandomMacPolicy == "" {
+		return RandomMacDefaultVlan
+	}
+	return sc.RandomMacPolicy
+}
+
+func (sc SwitchConfig) BlockActionOrDefault() string {
+	if sc.BlockAction == "" {
+		return BlockQuarantine
+	}
+	return sc.BlockAction
+}
+
+// ProtectedRangesOrDefault returns protected_ranges, or 1000-4094 when it is
+// not set; an empty list protects no range.
+func (sc SwitchConfig) ProtectedRangesOrDefault() []string {
+	if sc.ProtectedRanges == nil {
+		return DefaultProtectedRanges
+	}
+	return sc.ProtectedRanges
+}
+
+// IsProtectedVlan reports whether protected_vlans or protected_ranges keep
+// vlan from being deleted.
+func (sc SwitchConfig) IsProtectedVlan(vlan string) bool {
+	return VlanInRanges(vlan, sc.ProtectedVlans) || VlanInRanges(vlan, sc.ProtectedRangesOrDefault())
+}
+
 func (sc SwitchConfig) PlatformID() string {
 	p := sc.Platform
 	if p == "" {
@@ -1,6 +1,9 @@
 package entities
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestSwitchConfigHelpers(t *testing.T) {
 	cases := []struct {
@@ -29,4 +32,39 @@ func TestSwitchConfigHelpers(t *testing.T) {
 	if (SwitchConfig{Platform: "auto", LegacyPlatform: "ios"}).PlatformID() != "auto" {
 		t.Fatal("platform should take precedence over legacy")
 	}
+
+	if _, ok := (SwitchConfig{}).NoDataPeriod(); ok {
+		t.Fatal("quarantine should be off without no_data_after")
+	}
+	if d, ok := (SwitchConfig{NoDataAfter: "45m"}).NoDataPeriod(); !ok || d != 45*time.Minute {
+		t.Fatalf("NoDataPeriod() = %v, %v", d, ok)
+	}
+}
+
+func TestSwitchConfigIsProtectedVlan(t *testing.T) {
+	cases := []struct {
+		sc        SwitchConfig
+		vlan      string
+		protected bool
+	}{
+		{SwitchConfig{}, "1000", true},
+		{SwitchConfig{}, "999", false},
+		{SwitchConfig{ProtectedRanges: []string{"1500-1599"}}, "1200", false},
+		{SwitchConfig{ProtectedRanges: []string{"1500-1599"}}, "1500", true},
+		{SwitchConfig{ProtectedRanges: []string{}}, "4094", false},
+		{SwitchConfig{ProtectedRanges: []string{}, ProtectedVlans: []string{"20", "100 - 199"}}, "150", true},
+		{SwitchConfig{ProtectedRanges: []string{}, ProtectedVlans: []string{"20", "100 - 199"}}, "200", false},
+		{SwitchConfig{ProtectedVlans: []string{"oops"}}, "oops", false},
+	}
+	for _, tc := range cases {
+		if got := tc.sc.IsProtectedVlan(tc.vlan); got != tc.protected {
+			t.Errorf("ranges %v vlans %v: IsProtectedVlan(%s) = %v; expected %v", tc.sc.ProtectedRanges, tc.sc.ProtectedVlans, tc.vlan, got, tc.protected)
+		}
+	}
+
+	for _, bad := range []string{"", "abc", "0", "10-5", "1-4095", "1-2-3"} {
+		if _, _, err := ParseVlanRange(bad); err == nil {
+			t.Errorf("ParseVlanRange(%q): expected error", bad)
+		}
+	}
 }
@@ -0,0 +1,54 @@
+package entities
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SwitchState is the data read from a switch before deciding a plan.
+type SwitchState struct {
+	Vlans   []Vlan
+	Trunks  []string
+	Ports   []Port
+	Devices []Device
+	// TrunkVlans maps each trunk to its allowed VLANs; it is only read when
+	// uplinks are synchronized.
+	TrunkVlans map[string][]string
+}
+
+// Fingerprint hashes the VLAN IDs, active ports (with their descriptions,
+// when read) and MAC table in a canonical order, so two reads of an unchanged
+// switch produce the same value. VLAN names and trunk VLANs are left out:
+// setting them again is harmless.
+func (st SwitchState) Fingerprint() string {
+	vlans := VlanIDs(st.Vlans)
+	sort.Strings(vlans)
+
+	ports := make([]string, 0, len(st.Ports))
+	for _, p := range st.Ports {
+		entry := strings.ToLower(p.Interface) + "=" + p.Vlan
+		if p.VoiceVlan != "" {
+			entry += "+" + p.VoiceVlan
+		}
+		if p.Description != "" {
+			entry += " " + strconv.Quote(p.Description)
+		}
+		ports = append(ports, entry)
+	}
+	sort.Strings(ports)
+
+	devices := make([]string, 0, len(st.Devices))
+	for _, d := range st.Devices {
+		devices = append(devices, strings.ToLower(d.Interface)+"="+d.Mac)
+	}
+	sort.Strings(devices)
+
+	h := sha256.New()
+	for _, section := range [][]string{vlans, ports, devices} {
+		fmt.Fprintf(h, "%s\n--\n", strings.Join(section, "\n"))
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
@@ -0,0 +1,41 @@
+package entities
+
+import "testing"
+
+func TestSwitchStateFingerprint(t *testing.T) {
+	a := SwitchState{
+		Vlans:   []Vlan{{ID: "1"}, {ID: "10", Name: "USERS"}},
+		Ports:   []Port{{Interface: "Gi1/0/1", Vlan: "1"}, {Interface: "Gi1/0/2", Vlan: "10"}},
+		Devices: []Device{{Interface: "Gi1/0/1", Mac: "aabbccddeeff"}},
+	}
+	b := SwitchState{
+		Vlans:   []Vlan{{ID: "10", Name: "VLAN0010"}, {ID: "1"}},
+		Trunks:  []string{"Gi1/0/24"},
+		Ports:   []Port{{Interface: "gi1/0/2", Vlan: "10"}, {Interface: "Gi1/0/1", Vlan: "1"}},
+		Devices: []Device{{Interface: "Gi1/0/1", Mac: "aabbccddeeff", MacFull: "aa:bb:cc:dd:ee:ff"}},
+	}
+	if a.Fingerprint() != b.Fingerprint() {
+		t.Error("fingerprint must not depend on ordering, interface case or VLAN names")
+	}
+
+	c := a
+	c.Ports = []Port{{Interface: "Gi1/0/1", Vlan: "10"}, {Interface: "Gi1/0/2", Vlan: "10"}}
+	if a.Fingerprint() == c.Fingerprint() {
+		t.Error("fingerprint must change when a port VLAN changes")
+	}
+	v := a
+	v.Ports = []Port{{Interface: "Gi1/0/1", Vlan: "1", VoiceVlan: "30"}, {Interface: "Gi1/0/2", Vlan: "10"}}
+	if a.Fingerprint() == v.Fingerprint() {
+		t.Error("fingerprint must change when a voice VLAN changes")
+	}
+	desc := a
+	desc.Ports = []Port{{Interface: "Gi1/0/1", Vlan: "1", Description: "Reception"}, {Interface: "Gi1/0/2", Vlan: "10"}}
+	if a.Fingerprint() == desc.Fingerprint() {
+		t.Error("fingerprint must change when a port description changes")
+	}
+	d := a
+	d.Devices = nil
+	if a.Fingerprint() == d.Fingerprint() {
+		t.Error("fingerprint must change when the MAC table changes")
+	}
+}
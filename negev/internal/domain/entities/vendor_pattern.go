@@ -0,0 +1,49 @@
+package entities
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// VendorPattern is a vendor_to_vlan key: a regular expression after "re:",
+// matched ignoring case, or a vendor name. A name matches vendors that start
+// with it as whole words, ignoring case, so "Zebra Technologies" matches
+// "Zebra Technologies Inc." but "HP" does not match "HPE".
+type VendorPattern struct {
+	name string
+	re   *regexp.Regexp
+}
+
+func ParseVendorPattern(s string) (VendorPattern, error) {
+	s = strings.TrimSpace(s)
+	if expr, ok := strings.CutPrefix(s, "re:"); ok {
+		re, err := regexp.Compile("(?i)" + expr)
+		if err != nil {
+			return VendorPattern{}, fmt.Errorf("invalid regular expression: %v", err)
+		}
+		return VendorPattern{re: re}, nil
+	}
+	if s == "" {
+		return VendorPattern{}, fmt.Errorf("empty vendor name")
+	}
+	return VendorPattern{name: strings.ToLower(s)}, nil
+}
+
+func (p VendorPattern) Match(vendor string) bool {
+	if vendor == "" {
+		return false
+	}
+	if p.re != nil {
+		return p.re.MatchString(vendor)
+	}
+	rest, ok := strings.CutPrefix(strings.ToLower(vendor), p.name)
+	if !ok {
+		return false
+	}
+	for _, r := range rest {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}
+	return true
+}
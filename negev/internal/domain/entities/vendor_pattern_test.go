@@ -0,0 +1,33 @@
+package entities
+
+import "testing"
+
+func TestVendorPatternMatch(t *testing.T) {
+	cases := []struct {
+		pattern, vendor string
+		match           bool
+	}{
+		{"Zebra Technologies", "Zebra Technologies Inc.", true},
+		{"zebra technologies", "ZEBRA TECHNOLOGIES", true},
+		{"HP", "HP Inc.", true},
+		{"HP", "HPE", false},
+		{"Zebra", "Acme Zebra", false},
+		{"re:^hewlett", "Hewlett Packard", true},
+		{"re:(axis|hikvision)", "Hangzhou Hikvision Digital Technology Co.,Ltd.", true},
+		{"re:^dell", "", false},
+	}
+	for _, tc := range cases {
+		p, err := ParseVendorPattern(tc.pattern)
+		if err != nil {
+			t.Fatalf("ParseVendorPattern(%q) returned error: %v", tc.pattern, err)
+		}
+		if got := p.Match(tc.vendor); got != tc.match {
+			t.Errorf("%q matches %q = %v; expected %v", tc.pattern, tc.vendor, got, tc.match)
+		}
+	}
+	for _, bad := range []string{"", "  ", "re:(unclosed"} {
+		if _, err := ParseVendorPattern(bad); err == nil {
+			t.Errorf("ParseVendorPattern(%q): expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package entities
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxVlanNameLength is the longest VLAN name every supported platform accepts.
+const MaxVlanNameLength = 32
+
+// Vlan is a VLAN ID with an optional name. In allowed_vlans it is written
+// either as a bare ID or as {id: 10, name: USERS}.
+type Vlan struct {
+	ID   string `yaml:"id" json:"id"`
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+}
+
+func (v *Vlan) UnmarshalYAML(unmarshal func(any) error) error {
+	var id string
+	if err := unmarshal(&id); err == nil {
+		*v = Vlan{ID: id}
+		return nil
+	}
+	type plain Vlan
+	var p plain
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	*v = Vlan(p)
+	return nil
+}
+
+func (v Vlan) String() string {
+	if v.Name == "" {
+		return v.ID
+	}
+	return v.ID + " (" + v.Name + ")"
+}
+
+// ValidateVlanName checks that name can be set on a switch as a single word.
+func ValidateVlanName(name string) error {
+	if len(name) > MaxVlanNameLength {
+		return fmt.Errorf("VLAN name %s is longer than %d characters", name, MaxVlanNameLength)
+	}
+	if strings.ContainsFunc(name, func(r rune) bool { return r <= ' ' || r > '~' }) {
+		return fmt.Errorf("VLAN name %q must be printable ASCII without spaces", name)
+	}
+	return nil
+}
+
+// ParseVlanRange parses a VLAN ID or an inclusive range of them such as
+// 1000-1099.
+func ParseVlanRange(s string) (first, last int, err error) {
+	lo, hi, isRange := strings.Cut(strings.ReplaceAll(s, " ", ""), "-")
+	if !isRange {
+		hi = lo
+	}
+	first, err1 := strconv.Atoi(lo)
+	last, err2 := strconv.Atoi(hi)
+	if err1 != nil || err2 != nil {
+		return 0, 0, fmt.Errorf("invalid VLAN range %s", s)
+	}
+	if first < 1 || last > 4094 || first > last {
+		return 0, 0, fmt.Errorf("VLAN range %s must be between 1 and 4094", s)
+	}
+	return first, last, nil
+}
+
+// VlanInRanges reports whether vlan is one of ranges, each a VLAN ID or a
+// range accepted by ParseVlanRange. Invalid entries never match.
+func VlanInRanges(vlan string, ranges []string) bool {
+	n, err := strconv.Atoi(vlan)
+	if err != nil {
+		return false
+	}
+	for _, r := range ranges {
+		first, last, err := ParseVlanRange(r)
+		if err == nil && n >= first && n <= last {
+			return true
+		}
+	}
+	return false
+}
+
+// VlanIDs returns the IDs of vlans in order.
+func VlanIDs(vlans []Vlan) []string {
+	ids := make([]string, 0, len(vlans))
+	for _, v := range vlans {
+		ids = append(ids, v.ID)
+	}
+	return ids
+}
@@ -0,0 +1,8 @@
+package ports
+
+import "github.com/carlosrabelo/negev/negev/internal/domain/entities"
+
+type PortHistoryStore interface {
+	Load(target string) (*entities.PortHistory, error)
+	Save(history *entities.PortHistory) error
+}
@@ -0,0 +1,7 @@
+package ports
+
+import "github.com/carlosrabelo/negev/negev/internal/domain/entities"
+
+type RunJournal interface {
+	Record(entry *entities.JournalEntry) error
+}
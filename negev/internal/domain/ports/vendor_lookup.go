@@ -0,0 +1,8 @@
+package ports
+
+// VendorLookup names the organization a MAC address was assigned to.
+type VendorLookup interface {
+	// Vendor returns the vendor of a normalized MAC address, or "" when it
+	// is unknown.
+	Vendor(mac string) string
+}
@@ -3,8 +3,15 @@ package ports
 import "github.com/carlosrabelo/negev/negev/internal/domain/entities"
 
 type VLANService interface {
-	ProcessPorts() error
-	GetVlanList() (map[string]bool, error)
+	ProcessPorts() (*entities.Plan, error)
+	BuildPlan() (*entities.Plan, error)
+	ApplyPlan(plan *entities.Plan) error
+	ApplySavedPlan(plan *entities.Plan) error
+	RollbackRun(entry *entities.JournalEntry) (*entities.Plan, error)
+	ObserveState() (*entities.SwitchState, error)
+	Inventory() (*entities.Inventory, error)
+	// GetVlanList maps each VLAN ID on the switch to its name.
+	GetVlanList() (map[string]string, error)
 	GetTrunkInterfaces() (map[string]bool, error)
 	GetActivePorts() ([]entities.Port, error)
 	GetMacTable() ([]entities.Device, error)
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
+)
+
+var ErrChangeLimit = errors.New("change limit exceeded")
+
+// ChangeBudget caps the ports changed by all the runs sharing it, such as the
+// switches of one fleet run. It is safe for concurrent use.
+type ChangeBudget struct {
+	mu    sync.Mutex
+	limit int
+	used  int
+}
+
+func NewChangeBudget(limit int) *ChangeBudget {
+	return &ChangeBudget{limit: limit}
+}
+
+// Reserve takes n changes from the budget, or none if fewer than n are left.
+func (b *ChangeBudget) Reserve(n int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.used+n > b.limit {
+		return fmt.Errorf("%w: plan changes %d port(s) but only %d of max_fleet_changes %d are left", ErrChangeLimit, n, b.limit-b.used, b.limit)
+	}
+	b.used += n
+	return nil
+}
+
+// SetChangeBudget makes applied runs take the ports they change from b and
+// refuse to run once it is spent.
+func (s *VLANServiceImpl) SetChangeBudget(b *ChangeBudget) {
+	s.budget = b
+}
+
+// checkLimits refuses a plan changing more than max_changes ports or
+// deleting more than max_vlan_deletions VLANs. In sandbox mode nothing is
+// executed, so the plan is only flagged with a warning.
+func (s *VLANServiceImpl) checkLimits(plan *entities.Plan) error {
+	err := planLimitError(plan, s.config)
+	if err == nil && !s.config.Sandbox && s.budget != nil {
+		err = s.budget.Reserve(plan.ChangedPorts())
+	}
+	if err == nil {
+		return nil
+	}
+	if s.config.Sandbox {
+		slog.Warn("Plan exceeds a change limit — it would be refused with --write", "target", s.config.Target, "error", err)
+		return nil
+	}
+	return err
+}
+
+func planLimitError(plan *entities.Plan, cfg entities.SwitchConfig) error {
+	if changed := plan.ChangedPorts(); cfg.MaxChanges > 0 && changed > cfg.MaxChanges {
+		return fmt.Errorf("%w: plan changes %d port(s), max_changes is %d", ErrChangeLimit, changed, cfg.MaxChanges)
+	}
+	deletions := 0
+	for _, a := range plan.Actions {
+		if a.Kind == entities.ActionDeleteVLAN {
+			deletions++
+		}
+	}
+	if cfg.MaxVlanDeletions > 0 && deletions > cfg.MaxVlanDeletions {
+		return fmt.Errorf("%w: plan deletes %d VLAN(s), max_vlan_deletions is %d", ErrChangeLimit, deletions, cfg.MaxVlanDeletions)
+	}
+	return nil
+}
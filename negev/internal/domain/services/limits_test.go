@@ -0,0 +1,85 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
+)
+
+func limitsDriver() *stubDriver {
+	return &stubDriver{
+		vlans: []string{"1", "10", "30", "40"},
+		ports: []entities.Port{
+			{Interface: "Gi1/0/1", Vlan: "1"},
+			{Interface: "Gi1/0/2", Vlan: "1"},
+			{Interface: "Gi1/0/3", Vlan: "1"},
+		},
+		devices: []entities.Device{
+			{Mac: "aabbcc000001", Interface: "Gi1/0/1"},
+			{Mac: "aabbcc000002", Interface: "Gi1/0/2"},
+			{Mac: "aabbcc000003", Interface: "Gi1/0/3"},
+		},
+	}
+}
+
+func TestProcessPortsRefusesPlanOverLimits(t *testing.T) {
+	cases := []struct {
+		name string
+		cfg  entities.SwitchConfig
+	}{
+		{"max_changes", entities.SwitchConfig{MaxChanges: 2}},
+		{"max_vlan_deletions", entities.SwitchConfig{CreateVLANs: true, AllowedVlans: []entities.Vlan{{ID: "1"}, {ID: "10"}}, MaxVlanDeletions: 1}},
+	}
+	for _, tc := range cases {
+		cfg := tc.cfg
+		cfg.Target = "10.0.0.1"
+		cfg.DefaultVlan = "10"
+
+		repo := &mockRepository{}
+		plan, err := NewVLANService(repo, cfg, limitsDriver()).ProcessPorts()
+		if !errors.Is(err, ErrChangeLimit) {
+			t.Fatalf("%s: expected change limit error, got %v", tc.name, err)
+		}
+		if !plan.HasChanges() {
+			t.Errorf("%s: expected the refused plan to be returned", tc.name)
+		}
+		if len(repo.executed) != 0 {
+			t.Errorf("%s: nothing must be executed, got %v", tc.name, repo.executed)
+		}
+
+		cfg.Sandbox = true
+		if _, err := NewVLANService(&mockRepository{}, cfg, limitsDriver()).ProcessPorts(); err != nil {
+			t.Errorf("%s: sandbox runs must only warn, got %v", tc.name, err)
+		}
+	}
+
+	cfg := entities.SwitchConfig{Target: "10.0.0.1", DefaultVlan: "10", MaxChanges: 3}
+	if _, err := NewVLANService(&mockRepository{}, cfg, limitsDriver()).ProcessPorts(); err != nil {
+		t.Fatalf("plan within the limit must run, got %v", err)
+	}
+}
+
+func TestChangeBudgetSharedByRuns(t *testing.T) {
+	budget := NewChangeBudget(4)
+	cfg := entities.SwitchConfig{Target: "10.0.0.1", DefaultVlan: "10"}
+
+	svc := NewVLANService(&mockRepository{}, cfg, limitsDriver())
+	svc.SetChangeBudget(budget)
+	if _, err := svc.ProcessPorts(); err != nil {
+		t.Fatalf("first run must fit in the budget, got %v", err)
+	}
+
+	repo := &mockRepository{}
+	svc = NewVLANService(repo, cfg, limitsDriver())
+	svc.SetChangeBudget(budget)
+	if _, err := svc.ProcessPorts(); !errors.Is(err, ErrChangeLimit) {
+		t.Fatalf("expected the budget to be spent, got %v", err)
+	}
+	if len(repo.executed) != 0 {
+		t.Fatalf("nothing must be executed, got %v", repo.executed)
+	}
+	if err := budget.Reserve(1); err != nil {
+		t.Fatalf("a refused run must not use the budget, got %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
+)
+
+var ErrInvalidPlan = errors.New("plan does not fit the switch configuration")
+
+// checkPlan refuses a saved plan with an action the configuration would
+// never have decided, since the plan file or API body may have been edited
+// after the run that wrote it. state is the switch as re-read before applying.
+func (s *VLANServiceImpl) checkPlan(plan *entities.Plan, state *entities.SwitchState) error {
+	active := make(map[string]entities.Port, len(state.Ports))
+	for _, p := range state.Ports {
+		active[strings.ToLower(p.Interface)] = p
+	}
+	trunks := make(map[string]bool, len(state.Trunks))
+	for _, t := range state.Trunks {
+		trunks[strings.ToLower(t)] = true
+	}
+	for i, action := range plan.Actions {
+		if reason := s.actionProblem(action, active, trunks); reason != "" {
+			return fmt.Errorf("%w: action %d (%s): %s", ErrInvalidPlan, i+1, action.Describe(), reason)
+		}
+	}
+	return nil
+}
+
+func (s *VLANServiceImpl) actionProblem(action entities.PlanAction, active map[string]entities.Port, trunks map[string]bool) string {
+	allowed := s.getAllowedVLANs()
+	switch action.Kind {
+	case entities.ActionCreateVLAN, entities.ActionRenameVLAN:
+		name, ok := allowed[action.Vlan]
+		if !ok {
+			return "VLAN " + action.Vlan + " is not in allowed_vlans"
+		}
+		if action.Kind == entities.ActionRenameVLAN && action.Name != name {
+			return fmt.Sprintf("allowed_vlans names VLAN %s %q", action.Vlan, name)
+		}
+		return ""
+	case entities.ActionDeleteVLAN:
+		return s.deletableProblem(action.Vlan, allowed)
+	case entities.ActionTrunkAddVLAN, entities.ActionTrunkRemoveVLAN:
+		if !trunks[strings.ToLower(action.Interface)] || !s.isUplink(action.Interface) {
+			return action.Interface + " is not a trunk in uplink_ports"
+		}
+		if action.Kind == entities.ActionTrunkAddVLAN {
+			if _, ok := allowed[action.Vlan]; !ok {
+				return "VLAN " + action.Vlan + " is not in allowed_vlans"
+			}
+			return ""
+		}
+		return s.deletableProblem(action.Vlan, allowed)
+	case entities.ActionEnablePort:
+		return "only rollbacks enable ports"
+	}
+
+	port, ok := active[strings.ToLower(action.Interface)]
+	switch {
+	case !ok:
+		return action.Interface + " is not an active port of the switch"
+	case trunks[strings.ToLower(action.Interface)]:
+		return action.Interface + " is a trunk"
+	case s.isUplink(action.Interface):
+		return action.Interface + " is in uplink_ports"
+	case s.isExcludedPort(action.Interface):
+		return action.Interface + " is in exclude_ports"
+	}
+	if rule, pattern, pinned := s.matchPortRule(action.Interface); pinned && rule.Exclude {
+		return action.Interface + " is excluded by port_rules " + pattern
+	}
+
+	switch action.Kind {
+	case entities.ActionConfigureAccess:
+		if s.config.IsProtectedVlan(action.TargetVlan) {
+			return "VLAN " + action.TargetVlan + " is protected"
+		}
+		if _, ok := allowed[action.TargetVlan]; len(allowed) > 0 && !ok {
+			return "VLAN " + action.TargetVlan + " is not in allowed_vlans"
+		}
+	case entities.ActionConfigureVoice:
+		if !s.voiceEnabled() || action.TargetVlan != s.config.VoiceVlan {
+			return "VLAN " + action.TargetVlan + " is not the voice_vlan"
+		}
+	case entities.ActionDescribePort:
+		if s.config.PortDescription == "" {
+			return "port_description is not configured"
+		}
+		if !strings.HasPrefix(action.Description, entities.PortDescriptionMarker) || !entities.ManagedDescription(port.Description) {
+			return "only descriptions starting with " + entities.PortDescriptionMarker + " are written or replaced"
+		}
+	case entities.ActionShutdownPort:
+		if s.config.BlockActionOrDefault() != entities.BlockShutdown {
+			return "block_action is not " + entities.BlockShutdown
+		}
+	default:
+		return fmt.Sprintf("unknown action kind %q", action.Kind)
+	}
+	return ""
+}
+
+// deletableProblem says why vlan may not be deleted or removed from an
+// uplink, empty when it may.
+func (s *VLANServiceImpl) deletableProblem(vlan string, allowed map[string]string) string {
+	if id, err := strconv.Atoi(vlan); err != nil || id < 1 || id > 4094 {
+		return fmt.Sprintf("invalid VLAN %q", vlan)
+	}
+	if s.isProtected(vlan) {
+		return "VLAN " + vlan + " is reserved or protected"
+	}
+	if _, ok := allowed[vlan]; ok {
+		return "VLAN " + vlan + " is in allowed_vlans"
+	}
+	return ""
+}
+
+func (s *VLANServiceImpl) isUplink(iface string) bool {
+	for _, pattern := range s.config.UplinkPorts {
+		if matchPortPattern(pattern, iface) {
+			return true
+		}
+	}
+	return false
+}
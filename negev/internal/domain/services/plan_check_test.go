@@ -0,0 +1,68 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
+)
+
+func TestApplySavedPlanRejectsActionsOutsideConfig(t *testing.T) {
+	drv := &stubDriver{
+		vlans:  []string{"1", "10", "30", "1002"},
+		trunks: []string{"Gi1/0/24"},
+		ports: []entities.Port{
+			{Interface: "Gi1/0/1", Vlan: "1"},
+			{Interface: "Gi1/0/2", Vlan: "1"},
+			{Interface: "Gi1/0/3", Vlan: "1"},
+			{Interface: "Gi1/0/24", Vlan: "1"},
+		},
+	}
+	cfg := entities.SwitchConfig{
+		Target:         "10.0.0.1",
+		DefaultVlan:    "10",
+		AllowedVlans:   []entities.Vlan{{ID: "10"}},
+		ProtectedVlans: []string{"30"},
+		UplinkPorts:    []string{"Gi1/0/24"},
+		ExcludePorts:   []string{"Gi1/0/2"},
+		PortRules:      []entities.PortRule{{Ports: []string{"Gi1/0/3"}, Exclude: true}},
+	}
+	plan, err := NewVLANService(&mockRepository{}, entities.SwitchConfig{Target: "10.0.0.1", Sandbox: true}, drv).BuildPlan()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, action := range []entities.PlanAction{
+		{Kind: entities.ActionDeleteVLAN, Vlan: "1"},
+		{Kind: entities.ActionDeleteVLAN, Vlan: "30"},
+		{Kind: entities.ActionDeleteVLAN, Vlan: "1002"},
+		{Kind: entities.ActionDeleteVLAN, Vlan: "10"},
+		{Kind: entities.ActionCreateVLAN, Vlan: "40"},
+		{Kind: entities.ActionTrunkRemoveVLAN, Interface: "Gi1/0/1", Vlan: "20"},
+		{Kind: entities.ActionConfigureAccess, Interface: "Gi1/0/24", CurrentVlan: "1", TargetVlan: "10"},
+		{Kind: entities.ActionConfigureAccess, Interface: "Gi1/0/2", CurrentVlan: "1", TargetVlan: "10"},
+		{Kind: entities.ActionConfigureAccess, Interface: "Gi1/0/3", CurrentVlan: "1", TargetVlan: "10"},
+		{Kind: entities.ActionConfigureAccess, Interface: "Gi1/0/9", CurrentVlan: "1", TargetVlan: "10"},
+		{Kind: entities.ActionConfigureAccess, Interface: "Gi1/0/1", CurrentVlan: "1", TargetVlan: "20"},
+		{Kind: entities.ActionShutdownPort, Interface: "Gi1/0/1"},
+		{Kind: entities.ActionEnablePort, Interface: "Gi1/0/1"},
+		{Kind: entities.ActionDescribePort, Interface: "Gi1/0/1", Description: "negev:x"},
+	} {
+		crafted := *plan
+		crafted.Actions = []entities.PlanAction{action}
+		repo := &mockRepository{}
+		err := NewVLANService(repo, cfg, drv).ApplySavedPlan(&crafted)
+		if !errors.Is(err, ErrInvalidPlan) {
+			t.Errorf("%s: expected the plan to be rejected, got %v", action.Describe(), err)
+		}
+		if len(repo.executed) != 0 {
+			t.Errorf("%s: nothing may run, executed %v", action.Describe(), repo.executed)
+		}
+	}
+
+	valid := *plan
+	valid.Actions = []entities.PlanAction{{Kind: entities.ActionConfigureAccess, Interface: "Gi1/0/1", CurrentVlan: "1", TargetVlan: "10"}}
+	if err := NewVLANService(&mockRepository{}, cfg, drv).ApplySavedPlan(&valid); err != nil {
+		t.Fatalf("expected a plan the configuration allows to run, got %v", err)
+	}
+}
@@ -1,142 +1,1074 @@
 package services
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"os"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
 	"github.com/carlosrabelo/negev/negev/internal/domain/ports"
 	"github.com/carlosrabelo/negev/negev/internal/platform"
 )
 
+var ErrStateDrift = errors.New("switch state drifted since the plan was recorded")
+
+// CommandError is returned when the switch answered a command with an error
+// message recognized by the driver.
+type CommandError struct {
+	Command string
+	Output  string
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("command %q returned error: %s", e.Command, e.Output)
+}
+
 type VLANServiceImpl struct {
-	repo   ports.SwitchRepository
-	config entities.SwitchConfig
-	driver platform.SwitchDriver
+	repo    ports.SwitchRepository
+	config  entities.SwitchConfig
+	driver  platform.SwitchDriver
+	journal ports.RunJournal
+	history ports.PortHistoryStore
+	vendors ports.VendorLookup
+	budget  *ChangeBudget
+	now     func() time.Time
+	out     io.Writer
 }
 
 func NewVLANService(repo ports.SwitchRepository, config entities.SwitchConfig, driver platform.SwitchDriver) *VLANServiceImpl {
-	return &VLANServiceImpl{repo: repo, config: config, driver: driver}
+	return &VLANServiceImpl{repo: repo, config: config, driver: driver, now: time.Now, out: os.Stdout}
 }
 
 var _ ports.VLANService = (*VLANServiceImpl)(nil)
 
-func (s *VLANServiceImpl) ProcessPorts() error {
+// SetJournal makes applied runs record their actions in j before executing
+// them, so they can be rolled back.
+func (s *VLANServiceImpl) SetJournal(j ports.RunJournal) {
+	s.journal = j
+}
+
+// SetPortHistory lets BuildPlan remember ports across runs, which the
+// no_data_after quarantine needs to know how long a port has had no MAC.
+// Only runs that may write save it.
+func (s *VLANServiceImpl) SetPortHistory(h ports.PortHistoryStore) {
+	s.history = h
+}
+
+// SetVendorLookup names the vendor of every MAC read from the switch, for
+// vendor_to_vlan, logs and reports.
+func (s *VLANServiceImpl) SetVendorLookup(v ports.VendorLookup) {
+	s.vendors = v
+}
+
+func (s *VLANServiceImpl) ProcessPorts() (*entities.Plan, error) {
+	if err := s.repo.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect: %v", err)
+	}
+	defer s.repo.Disconnect()
+
+	plan, err := s.BuildPlan()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkLimits(plan); err != nil {
+		s.printRefused(plan)
+		return plan, err
+	}
+	return plan, s.ApplyPlan(plan)
+}
+
+// printRefused shows the commands of a plan that was not executed.
+func (s *VLANServiceImpl) printRefused(plan *entities.Plan) {
+	for _, action := range plan.Actions {
+		for _, cmd := range action.Commands {
+			s.printf("NOT APPLIED: %s\n", cmd)
+		}
+	}
+}
+
+// ApplySavedPlan re-reads the switch, refuses to continue if the observed
+// state no longer matches the plan fingerprint or an action does not fit the
+// configuration, and then executes exactly the planned actions. Commands are rebuilt through the driver rather than taken
+// from the plan file.
+func (s *VLANServiceImpl) ApplySavedPlan(plan *entities.Plan) error {
+	if plan.Target != s.config.Target {
+		return fmt.Errorf("plan was recorded for %s, not %s", plan.Target, s.config.Target)
+	}
+	if plan.Platform != s.driver.Name() {
+		return fmt.Errorf("plan was recorded for platform %s, switch uses %s", plan.Platform, s.driver.Name())
+	}
+
 	if err := s.repo.Connect(); err != nil {
 		return fmt.Errorf("failed to connect: %v", err)
 	}
 	defer s.repo.Disconnect()
 
-	vlans, err := s.GetVlanList()
+	state, err := s.ObserveState()
+	if err != nil {
+		return err
+	}
+	if fp := state.Fingerprint(); fp != plan.Fingerprint {
+		return fmt.Errorf("%w: plan fingerprint %s, switch is now %s", ErrStateDrift, plan.Fingerprint, fp)
+	}
+	if err := s.checkPlan(plan, state); err != nil {
+		return err
+	}
+	if !s.config.Sandbox {
+		s.savePortHistory(s.recordMacs(s.loadPortHistory(), state.Ports, state.Devices, s.now()))
+	}
+
+	for i := range plan.Actions {
+		cmds, err := s.commandsFor(plan.Actions[i])
+		if err != nil {
+			return err
+		}
+		plan.Actions[i].Commands = cmds
+	}
+	if err := s.checkLimits(plan); err != nil {
+		s.printRefused(plan)
+		return err
+	}
+	return s.ApplyPlan(plan)
+}
+
+func (s *VLANServiceImpl) commandsFor(action entities.PlanAction) ([]string, error) {
+	switch action.Kind {
+	case entities.ActionCreateVLAN:
+		return s.driver.CreateVLANCommands(action.Vlan, action.Name), nil
+	case entities.ActionDeleteVLAN:
+		return s.driver.DeleteVLANCommands(action.Vlan), nil
+	case entities.ActionRenameVLAN:
+		return s.driver.RenameVLANCommands(action.Vlan, action.Name), nil
+	case entities.ActionConfigureAccess:
+		port := entities.Port{Interface: action.Interface, Vlan: action.CurrentVlan}
+		return s.driver.ConfigureAccessCommands(port, action.TargetVlan), nil
+	case entities.ActionConfigureVoice:
+		port := entities.Port{Interface: action.Interface, VoiceVlan: action.CurrentVlan}
+		return s.driver.ConfigureVoiceCommands(port, action.TargetVlan), nil
+	case entities.ActionDescribePort:
+		port := entities.Port{Interface: action.Interface, Description: action.CurrentDescription}
+		return s.driver.DescribePortCommands(port, action.Description), nil
+	case entities.ActionShutdownPort, entities.ActionEnablePort:
+		port := entities.Port{Interface: action.Interface}
+		return s.driver.ShutdownPortCommands(port, action.Kind == entities.ActionShutdownPort), nil
+	case entities.ActionTrunkAddVLAN, entities.ActionTrunkRemoveVLAN:
+		port := entities.Port{Interface: action.Interface}
+		return s.driver.TrunkVlanCommands(port, action.Vlan, action.Kind == entities.ActionTrunkAddVLAN), nil
+	default:
+		return nil, fmt.Errorf("unknown action kind %q", action.Kind)
+	}
+}
+
+// Inventory reports every active port with its VLAN and learned MACs, as
+// observed, without deciding anything.
+func (s *VLANServiceImpl) Inventory() (*entities.Inventory, error) {
+	if err := s.repo.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect: %v", err)
+	}
+	defer s.repo.Disconnect()
+
+	state, err := s.ObserveState()
+	if err != nil {
+		return nil, err
+	}
+
+	vlans := entities.VlanIDs(state.Vlans)
+	sort.Slice(vlans, func(i, j int) bool { return vlanLess(vlans[i], vlans[j]) })
+	inv := &entities.Inventory{
+		Target:   s.config.Target,
+		Platform: s.driver.Name(),
+		Vlans:    vlans,
+		Ports:    []entities.InventoryPort{},
+	}
+	trunks := toSet(state.Trunks)
+	for _, port := range state.Ports {
+		rule, _, ruled := s.matchPortRule(port.Interface)
+		ip := entities.InventoryPort{
+			Interface: port.Interface,
+			Vlan:      port.Vlan,
+			Trunk:     trunks[port.Interface],
+			Excluded:  s.isExcludedPort(port.Interface) || (ruled && rule.Exclude),
+			Macs:      []entities.InventoryMac{},
+		}
+		for _, d := range s.filterDevices(state.Devices, port.Interface) {
+			_, _, blocked := s.matchBlockMac([]entities.Device{d})
+			m := entities.InventoryMac{Mac: d.MacFull, Vendor: d.Vendor, Excluded: s.isExcluded(d.Mac), Blocked: blocked}
+			if m.Mac == "" {
+				m.Mac = d.Mac
+			}
+			if len(d.Mac) >= 6 {
+				m.OUI = d.Mac[:6]
+			}
+			ip.Macs = append(ip.Macs, m)
+		}
+		inv.Ports = append(inv.Ports, ip)
+	}
+	return inv, nil
+}
+
+// RollbackRun undoes a journaled run: VLANs it deleted are created again,
+// VLANs it renamed get their old name back, ports it moved go back to their
+// previous data and voice VLAN and description, ports it shut down are
+// enabled, VLANs it added to or removed from uplinks are removed or added
+// back and VLANs it created are deleted. Ports whose VLAN changed since that run are left alone. Commands
+// are built by the driver and the configuration is saved as for any run.
+func (s *VLANServiceImpl) RollbackRun(entry *entities.JournalEntry) (*entities.Plan, error) {
+	if entry.Target != s.config.Target {
+		return nil, fmt.Errorf("run %s was recorded for %s, not %s", entry.RunID, entry.Target, s.config.Target)
+	}
+	if entry.Platform != s.driver.Name() {
+		return nil, fmt.Errorf("run %s was recorded for platform %s, switch uses %s", entry.RunID, entry.Platform, s.driver.Name())
+	}
+
+	if err := s.repo.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect: %v", err)
+	}
+	defer s.repo.Disconnect()
+
+	with := observation{voice: s.voiceEnabled()}
+	for _, a := range entry.Actions {
+		with.voice = with.voice || a.Kind == entities.ActionConfigureVoice
+		with.descriptions = with.descriptions || a.Kind == entities.ActionDescribePort
+		with.trunkVlans = with.trunkVlans || a.Kind == entities.ActionTrunkAddVLAN || a.Kind == entities.ActionTrunkRemoveVLAN
+	}
+	state, err := s.observe(with)
+	if err != nil {
+		return nil, err
+	}
+	plan := &entities.Plan{
+		Target:      s.config.Target,
+		Platform:    s.driver.Name(),
+		Sandbox:     s.config.Sandbox,
+		Fingerprint: state.Fingerprint(),
+		Actions:     []entities.PlanAction{},
+		RollbackOf:  entry.RunID,
+	}
+	rule := "rollback " + entry.RunID
+
+	vlans := toSet(entities.VlanIDs(state.Vlans))
+	names := vlanNames(state.Vlans)
+	portVlans := make(map[string]entities.Port, len(state.Ports))
+	for _, p := range state.Ports {
+		portVlans[strings.ToLower(p.Interface)] = p
+	}
+
+	for _, a := range entry.Actions {
+		if a.Kind != entities.ActionDeleteVLAN || vlans[a.Vlan] {
+			continue
+		}
+		plan.Actions = append(plan.Actions, entities.PlanAction{
+			Kind:     entities.ActionCreateVLAN,
+			Vlan:     a.Vlan,
+			Name:     a.Name,
+			Rule:     rule,
+			Commands: s.driver.CreateVLANCommands(a.Vlan, a.Name),
+		})
+	}
+	for _, a := range entry.Actions {
+		if a.Kind != entities.ActionRenameVLAN || a.CurrentName == "" || !vlans[a.Vlan] {
+			continue
+		}
+		if names[a.Vlan] != a.Name {
+			slog.Warn("VLAN renamed since the run — not rolled back", "vlan", a.Vlan, "name", names[a.Vlan], "expected", a.Name, "target", s.config.Target)
+			continue
+		}
+		plan.Actions = append(plan.Actions, entities.PlanAction{
+			Kind:        entities.ActionRenameVLAN,
+			Vlan:        a.Vlan,
+			Name:        a.CurrentName,
+			CurrentName: a.Name,
+			Rule:        rule,
+			Commands:    s.driver.RenameVLANCommands(a.Vlan, a.CurrentName),
+		})
+	}
+	for _, a := range entry.Actions {
+		if a.Kind != entities.ActionConfigureAccess || a.CurrentVlan == "" {
+			continue
+		}
+		port, ok := portVlans[strings.ToLower(a.Interface)]
+		if !ok {
+			slog.Warn("Port not active — not rolled back", "port", a.Interface, "target", s.config.Target)
+			continue
+		}
+		if port.Vlan != a.TargetVlan {
+			slog.Warn("Port changed since the run — not rolled back", "port", a.Interface, "vlan", port.Vlan, "expected", a.TargetVlan, "target", s.config.Target)
+			continue
+		}
+		plan.Actions = append(plan.Actions, entities.PlanAction{
+			Kind:        entities.ActionConfigureAccess,
+			Interface:   port.Interface,
+			CurrentVlan: port.Vlan,
+			TargetVlan:  a.CurrentVlan,
+			Mac:         a.Mac,
+			Rule:        rule,
+			Commands:    s.driver.ConfigureAccessCommands(port, a.CurrentVlan),
+		})
+	}
+	for _, a := range entry.Actions {
+		if a.Kind != entities.ActionConfigureVoice {
+			continue
+		}
+		port, ok := portVlans[strings.ToLower(a.Interface)]
+		if !ok || port.VoiceVlan != a.TargetVlan {
+			slog.Warn("Voice VLAN changed since the run — not rolled back", "port", a.Interface, "expected", a.TargetVlan, "target", s.config.Target)
+			continue
+		}
+		plan.Actions = append(plan.Actions, entities.PlanAction{
+			Kind:        entities.ActionConfigureVoice,
+			Interface:   port.Interface,
+			CurrentVlan: port.VoiceVlan,
+			TargetVlan:  a.CurrentVlan,
+			Mac:         a.Mac,
+			Rule:        rule,
+			Commands:    s.driver.ConfigureVoiceCommands(port, a.CurrentVlan),
+		})
+	}
+	for _, a := range entry.Actions {
+		if a.Kind != entities.ActionDescribePort {
+			continue
+		}
+		port, ok := portVlans[strings.ToLower(a.Interface)]
+		if !ok || port.Description != a.Description {
+			slog.Warn("Port description changed since the run — not rolled back", "port", a.Interface, "expected", a.Description, "target", s.config.Target)
+			continue
+		}
+		plan.Actions = append(plan.Actions, entities.PlanAction{
+			Kind:               entities.ActionDescribePort,
+			Interface:          port.Interface,
+			Description:        a.CurrentDescription,
+			CurrentDescription: port.Description,
+			Rule:               rule,
+			Commands:           s.driver.DescribePortCommands(port, a.CurrentDescription),
+		})
+	}
+	for _, a := range entry.Actions {
+		if a.Kind != entities.ActionShutdownPort {
+			continue
+		}
+		if _, ok := portVlans[strings.ToLower(a.Interface)]; ok {
+			slog.Warn("Port already up again — not rolled back", "port", a.Interface, "target", s.config.Target)
+			continue
+		}
+		port := entities.Port{Interface: a.Interface}
+		plan.Actions = append(plan.Actions, entities.PlanAction{
+			Kind:      entities.ActionEnablePort,
+			Interface: a.Interface,
+			Mac:       a.Mac,
+			Rule:      rule,
+			Commands:  s.driver.ShutdownPortCommands(port, false),
+		})
+	}
+	trunkVlans := make(map[string]map[string]bool, len(state.TrunkVlans))
+	for iface, allowed := range state.TrunkVlans {
+		trunkVlans[strings.ToLower(iface)] = toSet(allowed)
+	}
+	for _, a := range entry.Actions {
+		if a.Kind != entities.ActionTrunkAddVLAN && a.Kind != entities.ActionTrunkRemoveVLAN {
+			continue
+		}
+		allowed, ok := trunkVlans[strings.ToLower(a.Interface)]
+		added := a.Kind == entities.ActionTrunkAddVLAN
+		if !ok || allowed[a.Vlan] != added {
+			slog.Warn("Trunk VLANs changed since the run — not rolled back", "port", a.Interface, "vlan", a.Vlan, "target", s.config.Target)
+			continue
+		}
+		kind := entities.ActionTrunkAddVLAN
+		if added {
+			kind = entities.ActionTrunkRemoveVLAN
+		}
+		plan.Actions = append(plan.Actions, entities.PlanAction{
+			Kind:      kind,
+			Interface: a.Interface,
+			Vlan:      a.Vlan,
+			Rule:      rule,
+			Commands:  s.driver.TrunkVlanCommands(entities.Port{Interface: a.Interface}, a.Vlan, !added),
+		})
+	}
+	for _, a := range entry.Actions {
+		if a.Kind != entities.ActionCreateVLAN || !vlans[a.Vlan] {
+			continue
+		}
+		plan.Actions = append(plan.Actions, entities.PlanAction{
+			Kind:     entities.ActionDeleteVLAN,
+			Vlan:     a.Vlan,
+			Name:     names[a.Vlan],
+			Rule:     rule,
+			Commands: s.driver.DeleteVLANCommands(a.Vlan),
+		})
+	}
+
+	return plan, s.ApplyPlan(plan)
+}
+
+// ObserveState reads everything BuildPlan decides on.
+func (s *VLANServiceImpl) ObserveState() (*entities.SwitchState, error) {
+	return s.observe(observation{
+		voice:        s.voiceEnabled(),
+		descriptions: s.config.PortDescription != "",
+		trunkVlans:   s.config.CreateVLANs && len(s.config.UplinkPorts) > 0,
+	})
+}
+
+// observation selects what observe reads besides the VLANs, ports and MAC
+// table; each of these costs an extra command on some platforms.
+type observation struct {
+	voice, descriptions, trunkVlans bool
+}
+
+func (s *VLANServiceImpl) observe(with observation) (*entities.SwitchState, error) {
+	vlans, err := s.driver.GetVLANList(s.repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get VLAN list: %v", err)
+	}
+	trunks, err := s.driver.GetTrunkInterfaces(s.repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trunks: %v", err)
+	}
+	ports, err := s.driver.GetActivePorts(s.repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active ports: %v", err)
+	}
+	devices, err := s.driver.GetMacTable(s.repo)
 	if err != nil {
-		return fmt.Errorf("failed to get VLAN list: %v", err)
+		return nil, fmt.Errorf("failed to get MAC table: %v", err)
 	}
+	if s.vendors != nil {
+		for i := range devices {
+			devices[i].Vendor = s.vendors.Vendor(devices[i].Mac)
+		}
+	}
+	if with.voice {
+		voice, err := s.driver.GetVoiceVlans(s.repo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get voice VLANs: %v", err)
+		}
+		byName := make(map[string]string, len(voice))
+		for iface, vlan := range voice {
+			byName[strings.ToLower(iface)] = vlan
+		}
+		for i := range ports {
+			ports[i].VoiceVlan = byName[strings.ToLower(ports[i].Interface)]
+		}
+	}
+	if with.descriptions {
+		descriptions, err := s.driver.GetPortDescriptions(s.repo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get port descriptions: %v", err)
+		}
+		byName := make(map[string]string, len(descriptions))
+		for iface, description := range descriptions {
+			byName[strings.ToLower(iface)] = description
+		}
+		for i := range ports {
+			ports[i].Description = byName[strings.ToLower(ports[i].Interface)]
+		}
+	}
+	state := &entities.SwitchState{Vlans: vlans, Trunks: trunks, Ports: ports, Devices: devices}
+	if with.trunkVlans {
+		if state.TrunkVlans, err = s.driver.GetTrunkVlans(s.repo); err != nil {
+			return nil, fmt.Errorf("failed to get trunk VLANs: %v", err)
+		}
+	}
+	return state, nil
+}
+
+// BuildPlan reads the switch state and decides every action without
+// executing anything.
+func (s *VLANServiceImpl) BuildPlan() (*entities.Plan, error) {
+	plan := &entities.Plan{
+		Target:   s.config.Target,
+		Platform: s.driver.Name(),
+		Sandbox:  s.config.Sandbox,
+		Actions:  []entities.PlanAction{},
+	}
+
+	state, err := s.ObserveState()
+	if err != nil {
+		return nil, err
+	}
+	plan.Fingerprint = state.Fingerprint()
 
-	modified := false
+	vlans := toSet(entities.VlanIDs(state.Vlans))
+	names := vlanNames(state.Vlans)
+	trunks := toSet(state.Trunks)
+	ports := state.Ports
+	devices := state.Devices
 
 	if s.config.CreateVLANs {
 		allowed := s.getAllowedVLANs()
-		for v := range allowed {
-			if !vlans[v] {
-				if err := s.CreateVLAN(v); err != nil {
-					return fmt.Errorf("failed to create VLAN %s: %v", v, err)
+		for _, v := range sortedKeys(allowed) {
+			name := allowed[v]
+			if vlans[v] {
+				if name != "" && names[v] != name {
+					plan.Actions = append(plan.Actions, entities.PlanAction{
+						Kind:        entities.ActionRenameVLAN,
+						Vlan:        v,
+						Name:        name,
+						CurrentName: names[v],
+						Rule:        "allowed_vlans",
+						Commands:    s.driver.RenameVLANCommands(v, name),
+					})
 				}
-				vlans[v] = true
-				if !s.config.Sandbox {
-					modified = true
+				continue
+			}
+			plan.Actions = append(plan.Actions, entities.PlanAction{
+				Kind:     entities.ActionCreateVLAN,
+				Vlan:     v,
+				Name:     name,
+				Rule:     "allowed_vlans",
+				Commands: s.driver.CreateVLANCommands(v, name),
+			})
+			vlans[v] = true
+		}
+		uplinks := s.uplinkVlans(state.TrunkVlans)
+		for _, iface := range sortedKeys(uplinks) {
+			for _, v := range sortedKeys(allowed) {
+				if uplinks[iface][v] {
+					continue
 				}
+				plan.Actions = append(plan.Actions, entities.PlanAction{
+					Kind:      entities.ActionTrunkAddVLAN,
+					Interface: iface,
+					Vlan:      v,
+					Rule:      "allowed_vlans",
+					Commands:  s.driver.TrunkVlanCommands(entities.Port{Interface: iface}, v, true),
+				})
 			}
 		}
-		for v := range vlans {
-			if s.isProtected(v) {
+		for _, v := range sortedKeys(vlans) {
+			if _, ok := allowed[v]; ok || s.isProtected(v) {
 				continue
 			}
-			if !allowed[v] {
-				if err := s.DeleteVLAN(v); err != nil {
-					return fmt.Errorf("failed to delete VLAN %s: %v", v, err)
-				}
-				delete(vlans, v)
-				if !s.config.Sandbox {
-					modified = true
+			for _, iface := range sortedKeys(uplinks) {
+				if !uplinks[iface][v] {
+					continue
 				}
+				plan.Actions = append(plan.Actions, entities.PlanAction{
+					Kind:      entities.ActionTrunkRemoveVLAN,
+					Interface: iface,
+					Vlan:      v,
+					Rule:      "not in allowed_vlans",
+					Commands:  s.driver.TrunkVlanCommands(entities.Port{Interface: iface}, v, false),
+				})
 			}
+			plan.Actions = append(plan.Actions, entities.PlanAction{
+				Kind:     entities.ActionDeleteVLAN,
+				Vlan:     v,
+				Name:     names[v],
+				Rule:     "not in allowed_vlans",
+				Commands: s.driver.DeleteVLANCommands(v),
+			})
+			delete(vlans, v)
 		}
 	}
 
-	trunks, err := s.GetTrunkInterfaces()
-	if err != nil {
-		return fmt.Errorf("failed to get trunks: %v", err)
+	skip := func(iface string, reason entities.SkipReason) {
+		plan.Skipped = append(plan.Skipped, entities.SkippedPort{Interface: iface, Reason: reason})
 	}
-
-	ports, err := s.GetActivePorts()
-	if err != nil {
-		return fmt.Errorf("failed to get active ports: %v", err)
+	assign := func(port entities.Port, targetVlan, rule string, device entities.Device) {
+		if !vlans[targetVlan] {
+			slog.Error("Target VLAN does not exist on switch — skipping port", "vlan", targetVlan, "port", port.Interface, "target", s.config.Target)
+			skip(port.Interface, entities.SkipMissingVLAN)
+			return
+		}
+		if targetVlan == port.Vlan {
+			return
+		}
+		plan.Actions = append(plan.Actions, entities.PlanAction{
+			Kind:        entities.ActionConfigureAccess,
+			Interface:   port.Interface,
+			CurrentVlan: port.Vlan,
+			TargetVlan:  targetVlan,
+			Mac:         device.MacFull,
+			Vendor:      device.Vendor,
+			Rule:        rule,
+			Commands:    s.driver.ConfigureAccessCommands(port, targetVlan),
+		})
+		if description, ok := s.portDescription(port, targetVlan, rule, device); ok {
+			plan.Actions = append(plan.Actions, entities.PlanAction{
+				Kind:               entities.ActionDescribePort,
+				Interface:          port.Interface,
+				Description:        description,
+				CurrentDescription: port.Description,
+				Mac:                device.MacFull,
+				Vendor:             device.Vendor,
+				Rule:               rule,
+				Commands:           s.driver.DescribePortCommands(port, description),
+			})
+		}
 	}
 
-	devices, err := s.GetMacTable()
-	if err != nil {
-		return fmt.Errorf("failed to get MAC table: %v", err)
+	noDataPeriod, quarantine := s.config.NoDataPeriod()
+	now := s.now()
+	previous := s.loadPortHistory()
+	seen := s.recordMacs(previous, ports, devices, now)
+	if s.history == nil && s.config.MinObservations > 1 {
+		slog.Warn("min_observations needs a state_dir to remember MACs between runs — ports with a new MAC are never moved", "target", s.config.Target)
 	}
 
-	changes := 0
 	for _, port := range ports {
 		if trunks[port.Interface] {
+			skip(port.Interface, entities.SkipTrunk)
 			continue
 		}
 		if s.isExcludedPort(port.Interface) {
+			skip(port.Interface, entities.SkipExcluded)
+			continue
+		}
+		portRule, pattern, pinned := s.matchPortRule(port.Interface)
+		if pinned && portRule.Exclude {
+			skip(port.Interface, entities.SkipExcluded)
+			continue
+		}
+		if device, prefix, ok := s.matchBlockMac(s.filterDevices(devices, port.Interface)); ok {
+			s.block(plan, port, device, prefix, assign)
+			continue
+		}
+		if pinned {
+			assign(port, portRule.Vlan, "port_rules "+pattern, entities.Device{})
 			continue
 		}
 
+		var targetVlan, rule string
+		var device entities.Device
 		macs := s.filterDevices(devices, port.Interface)
-		if len(macs) == 0 {
-			continue
+		if limit := s.config.MaxPortMacs; limit > 0 && len(macs) > limit {
+			slog.Warn("Too many MACs on port — possible unauthorized hub or switch", "port", port.Interface, "macs", len(macs), "max_port_macs", limit, "target", s.config.Target)
+			plan.Alerts = append(plan.Alerts, entities.PortAlert{Interface: port.Interface, Macs: len(macs), Limit: limit})
 		}
 
-		if len(macs) > 1 {
-			slog.Warn("Multiple MACs on port — skipping for safety", "port", port.Interface, "target", s.config.Target)
-			continue
+		var phone *entities.Device
+		if s.voiceEnabled() {
+			macs, phone = s.splitPhone(macs)
+		}
+		if phone != nil {
+			if s.isExcluded(phone.Mac) || (len(macs) == 1 && s.isExcluded(macs[0].Mac)) {
+				skip(port.Interface, entities.SkipExcluded)
+				continue
+			}
+			if !vlans[s.config.VoiceVlan] {
+				slog.Error("Voice VLAN does not exist on switch — skipping port", "vlan", s.config.VoiceVlan, "port", port.Interface, "target", s.config.Target)
+				skip(port.Interface, entities.SkipMissingVLAN)
+				continue
+			}
+			if port.VoiceVlan != s.config.VoiceVlan {
+				plan.Actions = append(plan.Actions, entities.PlanAction{
+					Kind:        entities.ActionConfigureVoice,
+					Interface:   port.Interface,
+					CurrentVlan: port.VoiceVlan,
+					TargetVlan:  s.config.VoiceVlan,
+					Mac:         phone.MacFull,
+					Rule:        "voice_vlan",
+					Commands:    s.driver.ConfigureVoiceCommands(port, s.config.VoiceVlan),
+				})
+			}
 		}
 
-		mac := macs[0]
-		if len(mac.Mac) < 6 {
-			continue
+		switch {
+		case len(macs) == 0:
+			if phone != nil {
+				// A phone alone only needs its voice VLAN.
+				continue
+			}
+			if !quarantine {
+				continue
+			}
+			key := strings.ToLower(port.Interface)
+			since := previous.Ports[key].NoMacSince
+			if since.IsZero() {
+				since = now
+			}
+			record := seen.Ports[key]
+			record.NoMacSince = since
+			seen.Ports[key] = record
+			if now.Sub(since) < noDataPeriod {
+				continue
+			}
+			targetVlan = s.config.NoDataVlan
+			rule = "no_data_vlan"
+
+		case len(macs) > 1:
+			if slices.ContainsFunc(macs, s.isRandomMac) {
+				plan.RandomMacPorts++
+				if s.config.RandomMacPolicyOrDefault() == entities.RandomMacSkip {
+					slog.Info("Randomized MAC on port — skipping as random_mac_policy says", "port", port.Interface, "macs", len(macs), "target", s.config.Target)
+					skip(port.Interface, entities.SkipRandomMac)
+					continue
+				}
+			}
+			var ok bool
+			targetVlan, rule, device, ok = s.resolveMultiMac(port.Interface, macs)
+			if !ok {
+				slog.Warn("Multiple MACs on port — skipping for safety", "port", port.Interface, "macs", len(macs), "policy", s.config.MultiMacPolicyOrDefault(), "target", s.config.Target)
+				skip(port.Interface, entities.SkipMultipleMacs)
+				continue
+			}
+
+		default:
+			mac := macs[0]
+			if len(mac.Mac) < 6 {
+				continue
+			}
+
+			if s.isExcluded(mac.Mac) {
+				skip(port.Interface, entities.SkipExcluded)
+				continue
+			}
+			if s.isRandomMac(mac) {
+				plan.RandomMacPorts++
+				if s.config.RandomMacPolicyOrDefault() == entities.RandomMacSkip {
+					slog.Info("Randomized MAC on port — skipping as random_mac_policy says", "port", port.Interface, "mac", mac.MacFull, "target", s.config.Target)
+					skip(port.Interface, entities.SkipRandomMac)
+					continue
+				}
+			}
+
+			targetVlan, rule, _ = s.mapMac(port.Interface, mac)
+			device = mac
 		}
 
-		if s.isExcluded(mac.Mac) {
+		if targetVlan != port.Vlan && !s.settled(seen, port.Interface, macs, now) {
+			slog.Info("MAC not seen long enough — leaving port as is for now", "port", port.Interface, "vlan", targetVlan, "min_observations", s.config.MinObservations, "min_age", s.config.MinAge, "target", s.config.Target)
+			skip(port.Interface, entities.SkipPending)
 			continue
 		}
+		assign(port, targetVlan, rule, device)
+	}
 
-		prefix := mac.Mac[:6]
-		targetVlan := s.config.MacToVlan[prefix]
-		if targetVlan == "" || targetVlan == "0" || targetVlan == "00" {
-			targetVlan = s.config.DefaultVlan
+	if !s.config.Sandbox {
+		s.savePortHistory(seen)
+	}
+
+	return plan, nil
+}
+
+// mapMac decides the VLAN of a single MAC: the longest mac_to_vlan prefix,
+// no_data_vlan for a randomized MAC under random_mac_policy quarantine, the
+// longest vendor_to_vlan key, or default_vlan when none matches.
+func (s *VLANServiceImpl) mapMac(iface string, device entities.Device) (vlan, rule string, matched bool) {
+	prefix, vlan, ok := s.matchMacToVlan(device.Mac)
+	if ok && vlan != "" && vlan != "0" && vlan != "00" {
+		slog.Debug("MAC matched mac_to_vlan", "mac", device.Mac, "vendor", device.Vendor, "prefix", prefix, "vlan", vlan, "port", iface, "target", s.config.Target)
+		return vlan, "mac_to_vlan " + prefix, true
+	}
+	if entities.IsRandomizedMac(device.Mac) && s.config.RandomMacPolicyOrDefault() == entities.RandomMacQuarantine {
+		slog.Debug("Randomized MAC — using no_data_vlan", "mac", device.Mac, "vlan", s.config.NoDataVlan, "port", iface, "target", s.config.Target)
+		return s.config.NoDataVlan, "random_mac_policy quarantine", false
+	}
+	if key, vlan, ok := s.matchVendorToVlan(device.Vendor); ok {
+		slog.Debug("MAC matched vendor_to_vlan", "mac", device.Mac, "vendor", device.Vendor, "pattern", key, "vlan", vlan, "port", iface, "target", s.config.Target)
+		return vlan, "vendor_to_vlan " + key, true
+	}
+	slog.Debug("MAC matched no mapping — using default_vlan", "mac", device.Mac, "vendor", device.Vendor, "vlan", s.config.DefaultVlan, "port", iface, "target", s.config.Target)
+	return s.config.DefaultVlan, "default_vlan", false
+}
+
+// resolveMultiMac applies multi_mac_policy to a port with several MACs. Ports
+// with an excluded MAC are never resolved.
+func (s *VLANServiceImpl) resolveMultiMac(iface string, macs []entities.Device) (vlan, rule string, device entities.Device, ok bool) {
+	policy := s.config.MultiMacPolicyOrDefault()
+	if policy == entities.MultiMacSkip {
+		return "", "", device, false
+	}
+
+	type decision struct {
+		vlan, rule string
+		device     entities.Device
+	}
+	var decisions []decision
+	votes := make(map[string]int)
+	var firstMatch *decision
+	for _, d := range macs {
+		if len(d.Mac) < 6 || s.isExcluded(d.Mac) {
+			return "", "", device, false
+		}
+		v, r, matched := s.mapMac(iface, d)
+		decisions = append(decisions, decision{v, r, d})
+		votes[v]++
+		if matched && firstMatch == nil {
+			firstMatch = &decisions[len(decisions)-1]
 		}
+	}
 
-		if !vlans[targetVlan] {
-			slog.Error("Target VLAN does not exist on switch — skipping port", "vlan", targetVlan, "port", port.Interface, "target", s.config.Target)
+	var chosen decision
+	switch policy {
+	case entities.MultiMacSameVlanOnly:
+		if len(votes) != 1 {
+			return "", "", device, false
+		}
+		chosen = decisions[0]
+	case entities.MultiMacMajority:
+		found := false
+		for _, d := range decisions {
+			if votes[d.vlan]*2 > len(macs) {
+				chosen, found = d, true
+				break
+			}
+		}
+		if !found {
+			return "", "", device, false
+		}
+	case entities.MultiMacFirstMatch:
+		chosen = decisions[0]
+		if firstMatch != nil {
+			chosen = *firstMatch
+		}
+	default:
+		return "", "", device, false
+	}
+	return chosen.vlan, fmt.Sprintf("%s (%s, %d/%d MACs)", chosen.rule, policy, votes[chosen.vlan], len(macs)), chosen.device, true
+}
+
+// portDescription renders port_description for a port moved to vlan. It
+// reports false when no template is set, the description is already right or
+// the current one was written by a person.
+func (s *VLANServiceImpl) portDescription(port entities.Port, vlan, rule string, device entities.Device) (string, bool) {
+	if s.config.PortDescription == "" {
+		return "", false
+	}
+	if !entities.ManagedDescription(port.Description) {
+		slog.Debug("Port description not written by negev — left alone", "port", port.Interface, "description", port.Description, "target", s.config.Target)
+		return "", false
+	}
+	vendor := device.Vendor
+	if vendor == "" && len(device.Mac) >= 6 {
+		vendor = device.Mac[:6]
+	}
+	description := entities.RenderPortDescription(s.config.PortDescription, map[string]string{
+		"vendor": vendor,
+		"mac":    device.MacFull,
+		"rule":   rule,
+		"vlan":   vlan,
+	})
+	return description, description != port.Description
+}
+
+func (s *VLANServiceImpl) voiceEnabled() bool {
+	return s.config.VoiceVlan != "" && len(s.config.VoiceMacs) > 0
+}
+
+// splitPhone takes the phone out of the MACs of a port when exactly one of
+// them matches voice_macs and at most one other device is behind it, the
+// usual phone with a daisy-chained PC. Otherwise macs are returned as they
+// are and phone is nil.
+func (s *VLANServiceImpl) splitPhone(macs []entities.Device) (rest []entities.Device, phone *entities.Device) {
+	if len(macs) == 0 || len(macs) > 2 {
+		return macs, nil
+	}
+	for i := range macs {
+		if !s.isVoiceMac(macs[i].Mac) {
+			rest = append(rest, macs[i])
+		} else if phone == nil {
+			phone = &macs[i]
+		} else {
+			return macs, nil
+		}
+	}
+	if phone == nil {
+		return macs, nil
+	}
+	return rest, phone
+}
+
+func (s *VLANServiceImpl) isVoiceMac(mac string) bool {
+	for _, key := range s.config.VoiceMacs {
+		if p, err := entities.ParseMacPrefix(key); err == nil && p.Matches(mac) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchMacToVlan finds the longest mac_to_vlan prefix covering mac, so that
+// an MA-S block or a single device can override the OUI it belongs to.
+func (s *VLANServiceImpl) matchMacToVlan(mac string) (prefix, vlan string, ok bool) {
+	best := -1
+	for key, v := range s.config.MacToVlan {
+		p, err := entities.ParseMacPrefix(key)
+		if err != nil || p.Bits <= best || !p.Matches(mac) {
 			continue
 		}
+		best, prefix, vlan = p.Bits, key, v
+	}
+	return prefix, vlan, best >= 0
+}
+
+// matchBlockMac finds the first of macs covered by a block_macs prefix.
+func (s *VLANServiceImpl) matchBlockMac(macs []entities.Device) (device entities.Device, prefix string, ok bool) {
+	for _, d := range macs {
+		for _, key := range s.config.BlockMacs {
+			if p, err := entities.ParseMacPrefix(key); err == nil && p.Matches(d.Mac) {
+				return d, key, true
+			}
+		}
+	}
+	return device, "", false
+}
 
-		if targetVlan == port.Vlan {
+// block applies block_action to a port where a blocked MAC showed up, right
+// away: blocked devices are not debounced. The warning is the audit trail of
+// the decision, logged whether or not the plan is applied.
+func (s *VLANServiceImpl) block(plan *entities.Plan, port entities.Port, device entities.Device, prefix string, assign func(entities.Port, string, string, entities.Device)) {
+	action := s.config.BlockActionOrDefault()
+	rule := "block_macs " + prefix
+	slog.Warn("Blocked MAC on port", "port", port.Interface, "mac", device.MacFull, "vendor", device.Vendor, "prefix", prefix, "action", action, "sandbox", s.config.Sandbox, "target", s.config.Target)
+	if action == entities.BlockQuarantine {
+		assign(port, s.config.NoDataVlan, rule, device)
+		return
+	}
+	plan.Actions = append(plan.Actions, entities.PlanAction{
+		Kind:      entities.ActionShutdownPort,
+		Interface: port.Interface,
+		Mac:       device.MacFull,
+		Vendor:    device.Vendor,
+		Rule:      rule,
+		Commands:  s.driver.ShutdownPortCommands(port, true),
+	})
+}
+
+// isRandomMac reports whether d is a randomized MAC left to random_mac_policy,
+// that is one no mac_to_vlan key maps to a VLAN.
+func (s *VLANServiceImpl) isRandomMac(d entities.Device) bool {
+	if !entities.IsRandomizedMac(d.Mac) {
+		return false
+	}
+	_, vlan, ok := s.matchMacToVlan(d.Mac)
+	return !ok || vlan == "" || vlan == "0" || vlan == "00"
+}
+
+// matchVendorToVlan finds the vendor_to_vlan entry for vendor; when several
+// match, the longest key wins.
+func (s *VLANServiceImpl) matchVendorToVlan(vendor string) (key, vlan string, ok bool) {
+	if vendor == "" {
+		return "", "", false
+	}
+	for _, k := range sortedKeys(s.config.VendorToVlan) {
+		p, err := entities.ParseVendorPattern(k)
+		if err != nil || len(k) <= len(key) || !p.Match(vendor) {
 			continue
 		}
+		key, vlan, ok = k, s.config.VendorToVlan[k], true
+	}
+	return key, vlan, ok
+}
+
+// loadPortHistory never fails: without a usable history every port and MAC
+// counts as seen for the first time, which only delays the quarantine and
+// MAC-driven moves.
+func (s *VLANServiceImpl) loadPortHistory() *entities.PortHistory {
+	empty := &entities.PortHistory{Target: s.config.Target, Ports: map[string]entities.PortRecord{}}
+	if s.history == nil {
+		return empty
+	}
+	history, err := s.history.Load(s.config.Target)
+	if err != nil {
+		slog.Warn("Failed to load port history — observations start over", "target", s.config.Target, "error", err)
+		return empty
+	}
+	return history
+}
+
+// savePortHistory is only called by runs that may write, so previews and
+// sandbox runs never advance min_observations or the no_data_after period.
+func (s *VLANServiceImpl) savePortHistory(seen *entities.PortHistory) {
+	if s.history == nil {
+		return
+	}
+	if err := s.history.Save(seen); err != nil {
+		slog.Warn("Failed to save port history", "target", s.config.Target, "error", err)
+	}
+}
+
+// recordMacs returns the history after this run: every MAC on an active port
+// is observed, MACs not seen now are kept for MacHistoryRetention and an
+// active port that is still MAC-less keeps its NoMacSince.
+func (s *VLANServiceImpl) recordMacs(previous *entities.PortHistory, ports []entities.Port, devices []entities.Device, now time.Time) *entities.PortHistory {
+	seen := &entities.PortHistory{Target: s.config.Target, LastRun: now, Ports: map[string]entities.PortRecord{}}
+	macsOf := func(key string) map[string]entities.MacRecord {
+		record := seen.Ports[key]
+		if record.Macs == nil {
+			record.Macs = map[string]entities.MacRecord{}
+			seen.Ports[key] = record
+		}
+		return record.Macs
+	}
+	for key, record := range previous.Ports {
+		for mac, m := range record.Macs {
+			if now.Sub(m.LastSeen) < entities.MacHistoryRetention {
+				macsOf(key)[mac] = m
+			}
+		}
+	}
+	for _, port := range ports {
+		key := strings.ToLower(port.Interface)
+		portDevices := s.filterDevices(devices, port.Interface)
+		if since := previous.Ports[key].NoMacSince; len(portDevices) == 0 && !since.IsZero() {
+			record := seen.Ports[key]
+			record.NoMacSince = since
+			seen.Ports[key] = record
+		}
+		for _, d := range portDevices {
+			macs := macsOf(key)
+			m := macs[d.Mac]
+			m.Observe(now, previous.LastRun)
+			macs[d.Mac] = m
+		}
+	}
+	return seen
+}
+
+// settled reports whether every MAC in macs has been seen on iface for
+// min_observations consecutive runs and for at least min_age.
+func (s *VLANServiceImpl) settled(history *entities.PortHistory, iface string, macs []entities.Device, now time.Time) bool {
+	minAge := s.config.MinMacAge()
+	record := history.Ports[strings.ToLower(iface)]
+	for _, d := range macs {
+		m := record.Macs[d.Mac]
+		if m.Observations < s.config.MinObservations || now.Sub(m.Since) < minAge {
+			return false
+		}
+	}
+	return true
+}
 
-		if err := s.ConfigureVlan(port.Interface, targetVlan); err != nil {
-			return fmt.Errorf("failed to configure VLAN on port %s: %v", port.Interface, err)
+// ApplyPlan runs the commands of every action in order (or simulates them in
+// sandbox mode) and saves the configuration when something was changed.
+func (s *VLANServiceImpl) ApplyPlan(plan *entities.Plan) error {
+	if s.journal != nil && !s.config.Sandbox && plan.HasChanges() {
+		entry := &entities.JournalEntry{
+			Target:     plan.Target,
+			Platform:   plan.Platform,
+			CreatedAt:  time.Now(),
+			Actions:    plan.Actions,
+			RollbackOf: plan.RollbackOf,
 		}
-		changes++
-		if !s.config.Sandbox {
-			modified = true
+		if err := s.journal.Record(entry); err != nil {
+			return fmt.Errorf("failed to record rollback journal: %v", err)
 		}
+		slog.Info("Run recorded in rollback journal", "target", plan.Target, "run", entry.RunID)
 	}
 
-	if changes == 0 && !modified {
-		fmt.Println("No changes required")
+	for _, action := range plan.Actions {
+		if err := s.runCommands(action.Commands); err != nil {
+			return fmt.Errorf("failed to %s: %w", action.Describe(), err)
+		}
+	}
+
+	if plan.RandomMacPorts > 0 {
+		s.printf("%d port(s) with a randomized MAC (random_mac_policy %s)\n", plan.RandomMacPorts, s.config.RandomMacPolicyOrDefault())
+	}
+	if !plan.HasChanges() {
+		s.printf("No changes required\n")
 	} else if s.config.Sandbox {
-		fmt.Printf("Changes simulated (sandbox mode, use -w to apply)\n")
-	} else if modified {
+		s.printf("Changes simulated (sandbox mode, use -w to apply)\n")
+	} else {
 		slog.Info("Saving changes to startup-config", "target", s.config.Target)
 		if err := s.saveConfiguration(); err != nil {
 			return fmt.Errorf("failed to save configuration: %v", err)
@@ -147,16 +1079,13 @@ func (s *VLANServiceImpl) ProcessPorts() error {
 	return nil
 }
 
-func (s *VLANServiceImpl) GetVlanList() (map[string]bool, error) {
+// GetVlanList maps each VLAN ID on the switch to its name.
+func (s *VLANServiceImpl) GetVlanList() (map[string]string, error) {
 	vlans, err := s.driver.GetVLANList(s.repo)
 	if err != nil {
 		return nil, err
 	}
-	result := make(map[string]bool, len(vlans))
-	for _, v := range vlans {
-		result[v] = true
-	}
-	return result, nil
+	return vlanNames(vlans), nil
 }
 
 func (s *VLANServiceImpl) GetTrunkInterfaces() (map[string]bool, error) {
@@ -164,11 +1093,7 @@ func (s *VLANServiceImpl) GetTrunkInterfaces() (map[string]bool, error) {
 	if err != nil {
 		return nil, err
 	}
-	result := make(map[string]bool, len(trunks))
-	for _, t := range trunks {
-		result[t] = true
-	}
-	return result, nil
+	return toSet(trunks), nil
 }
 
 func (s *VLANServiceImpl) GetActivePorts() ([]entities.Port, error) {
@@ -180,30 +1105,21 @@ func (s *VLANServiceImpl) GetMacTable() ([]entities.Device, error) {
 }
 
 func (s *VLANServiceImpl) ConfigureVlan(iface, vlan string) error {
-	cmds := s.driver.ConfigureAccessCommands(entities.Port{Interface: iface}, vlan)
-	if s.config.Sandbox {
-		for _, cmd := range cmds {
-			fmt.Printf("SIMULATE: %s\n", cmd)
-		}
-		return nil
-	}
-	for _, cmd := range cmds {
-		out, err := s.repo.ExecuteCommand(cmd)
-		if err != nil {
-			return fmt.Errorf("command %q failed: %v", cmd, err)
-		}
-		if s.driver.IsCommandError(out) {
-			return fmt.Errorf("command %q returned error: %s", cmd, out)
-		}
-	}
-	return nil
+	return s.runCommands(s.driver.ConfigureAccessCommands(entities.Port{Interface: iface}, vlan))
 }
 
 func (s *VLANServiceImpl) CreateVLAN(vlan string) error {
-	cmds := s.driver.CreateVLANCommands(vlan)
+	return s.runCommands(s.driver.CreateVLANCommands(vlan, s.getAllowedVLANs()[vlan]))
+}
+
+func (s *VLANServiceImpl) DeleteVLAN(vlan string) error {
+	return s.runCommands(s.driver.DeleteVLANCommands(vlan))
+}
+
+func (s *VLANServiceImpl) runCommands(cmds []string) error {
 	if s.config.Sandbox {
 		for _, cmd := range cmds {
-			fmt.Printf("SIMULATE: %s\n", cmd)
+			s.printf("SIMULATE: %s\n", cmd)
 		}
 		return nil
 	}
@@ -213,30 +1129,30 @@ func (s *VLANServiceImpl) CreateVLAN(vlan string) error {
 			return fmt.Errorf("command %q failed: %v", cmd, err)
 		}
 		if s.driver.IsCommandError(out) {
-			return fmt.Errorf("command %q returned error: %s", cmd, out)
+			s.abort()
+			return &CommandError{Command: cmd, Output: out}
 		}
 	}
 	return nil
 }
 
-func (s *VLANServiceImpl) DeleteVLAN(vlan string) error {
-	cmds := s.driver.DeleteVLANCommands(vlan)
-	if s.config.Sandbox {
-		for _, cmd := range cmds {
-			fmt.Printf("SIMULATE: %s\n", cmd)
+// abort leaves configuration mode after a rejected command, so a session
+// kept open for the next run does not start inside configure mode.
+func (s *VLANServiceImpl) abort() {
+	for _, cmd := range s.driver.AbortCommands() {
+		if _, err := s.repo.ExecuteCommand(cmd); err != nil {
+			slog.Warn("Failed to leave configuration mode", "command", cmd, "target", s.config.Target, "error", err)
+			return
 		}
-		return nil
 	}
-	for _, cmd := range cmds {
-		out, err := s.repo.ExecuteCommand(cmd)
-		if err != nil {
-			return fmt.Errorf("command %q failed: %v", cmd, err)
-		}
-		if s.driver.IsCommandError(out) {
-			return fmt.Errorf("command %q returned error: %s", cmd, out)
-		}
+}
+
+// printf writes a text output line prefixed with the switch target, since
+// the switches of a fleet run print concurrently.
+func (s *VLANServiceImpl) printf(format string, args ...any) {
+	if s.config.IsTextOutput() {
+		fmt.Fprintf(s.out, "[%s] "+format, append([]any{s.config.Target}, args...)...)
 	}
-	return nil
 }
 
 func (s *VLANServiceImpl) saveConfiguration() error {
@@ -250,12 +1166,10 @@ func (s *VLANServiceImpl) saveConfiguration() error {
 	return lastErr
 }
 
-func (s *VLANServiceImpl) getAllowedVLANs() map[string]bool {
-	result := make(map[string]bool, len(s.config.AllowedVlans))
-	for _, v := range s.config.AllowedVlans {
-		result[v] = true
-	}
-	return result
+// getAllowedVLANs maps each allowed VLAN ID to its configured name, empty
+// when it has none.
+func (s *VLANServiceImpl) getAllowedVLANs() map[string]string {
+	return vlanNames(s.config.AllowedVlans)
 }
 
 func (s *VLANServiceImpl) filterDevices(devices []entities.Device, iface string) []entities.Device {
@@ -279,28 +1193,87 @@ func (s *VLANServiceImpl) isExcluded(mac string) bool {
 
 func (s *VLANServiceImpl) isExcludedPort(iface string) bool {
 	for _, e := range s.config.ExcludePorts {
-		if strings.EqualFold(iface, e) {
+		if matchPortPattern(e, iface) {
 			return true
 		}
 	}
 	return false
 }
 
-func (s *VLANServiceImpl) isProtected(vlan string) bool {
-	vlanNum := 0
-	fmt.Sscanf(vlan, "%d", &vlanNum)
-	if vlanNum >= 1000 && vlanNum <= 4094 {
-		return true
+// uplinkVlans picks the trunks matching uplink_ports out of trunkVlans, with
+// their allowed VLANs as sets. Every other trunk is left alone.
+func (s *VLANServiceImpl) uplinkVlans(trunkVlans map[string][]string) map[string]map[string]bool {
+	uplinks := make(map[string]map[string]bool)
+	for _, pattern := range s.config.UplinkPorts {
+		found := false
+		for iface, allowed := range trunkVlans {
+			if matchPortPattern(pattern, iface) {
+				uplinks[iface] = toSet(allowed)
+				found = true
+			}
+		}
+		if !found {
+			slog.Warn("uplink_ports entry matches no trunk — ignored", "pattern", pattern, "target", s.config.Target)
+		}
 	}
-	for _, p := range s.config.ProtectedVlans {
-		if vlan == p {
-			return true
+	return uplinks
+}
+
+// matchPortRule returns the first port rule with a pattern matching iface,
+// along with that pattern.
+func (s *VLANServiceImpl) matchPortRule(iface string) (entities.PortRule, string, bool) {
+	for _, rule := range s.config.PortRules {
+		for _, p := range rule.Ports {
+			if matchPortPattern(p, iface) {
+				return rule, p, true
+			}
 		}
 	}
-	return false
+	return entities.PortRule{}, "", false
+}
+
+func matchPortPattern(pattern, iface string) bool {
+	p, err := entities.ParsePortPattern(pattern)
+	if err != nil {
+		return strings.EqualFold(iface, pattern)
+	}
+	return p.Match(iface)
+}
+
+// isProtected reports whether vlan must never be deleted, because the
+// platform reserves it or the configuration protects it.
+func (s *VLANServiceImpl) isProtected(vlan string) bool {
+	return s.driver.IsReservedVLAN(vlan) || s.config.IsProtectedVlan(vlan)
+}
+
+func toSet(items []string) map[string]bool {
+	result := make(map[string]bool, len(items))
+	for _, item := range items {
+		result[item] = true
+	}
+	return result
+}
+
+func vlanNames(vlans []entities.Vlan) map[string]string {
+	result := make(map[string]string, len(vlans))
+	for _, v := range vlans {
+		result[v.ID] = v.Name
+	}
+	return result
+}
+
+// vlanLess orders VLAN IDs numerically, falling back to string order for
+// anything that is not a number.
+func vlanLess(a, b string) bool {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+	return na < nb
 }
 
-func sortedKeys(m map[string]bool) []string {
+func sortedKeys[V any](m map[string]V) []string {
 	keys := make([]string, 0, len(m))
 	for k := range m {
 		keys = append(keys, k)
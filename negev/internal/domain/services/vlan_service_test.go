@@ -1,9 +1,13 @@
 package services
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
 	"github.com/carlosrabelo/negev/negev/internal/domain/ports"
@@ -53,9 +57,13 @@ func (m *mockRepository) IsConnected() bool {
 
 type stubDriver struct {
 	vlans           []string
+	vlanNames       map[string]string
 	trunks          []string
 	ports           []entities.Port
 	devices         []entities.Device
+	voice           map[string]string
+	descriptions    map[string]string
+	trunkVlans      map[string][]string
 	vlanListErr     error
 	trunkErr        error
 	portsErr        error
@@ -72,11 +80,15 @@ func (d *stubDriver) Detect(repo ports.SwitchRepository) (bool, error) {
 	return true, nil
 }
 func (d *stubDriver) GetAuthenticationSequence() []entities.AuthPrompt { return nil }
-func (d *stubDriver) GetVLANList(repo ports.SwitchRepository) ([]string, error) {
+func (d *stubDriver) GetVLANList(repo ports.SwitchRepository) ([]entities.Vlan, error) {
 	if d.vlanListErr != nil {
 		return nil, d.vlanListErr
 	}
-	return d.vlans, nil
+	vlans := make([]entities.Vlan, 0, len(d.vlans))
+	for _, v := range d.vlans {
+		vlans = append(vlans, entities.Vlan{ID: v, Name: d.vlanNames[v]})
+	}
+	return vlans, nil
 }
 func (d *stubDriver) GetTrunkInterfaces(repo ports.SwitchRepository) ([]string, error) {
 	if d.trunkErr != nil {
@@ -84,6 +96,9 @@ func (d *stubDriver) GetTrunkInterfaces(repo ports.SwitchRepository) ([]string,
 	}
 	return d.trunks, nil
 }
+func (d *stubDriver) GetTrunkVlans(repo ports.SwitchRepository) (map[string][]string, error) {
+	return d.trunkVlans, nil
+}
 func (d *stubDriver) GetActivePorts(repo ports.SwitchRepository) ([]entities.Port, error) {
 	if d.portsErr != nil {
 		return nil, d.portsErr
@@ -96,6 +111,36 @@ func (d *stubDriver) GetMacTable(repo ports.SwitchRepository) ([]entities.Device
 	}
 	return d.devices, nil
 }
+func (d *stubDriver) GetVoiceVlans(repo ports.SwitchRepository) (map[string]string, error) {
+	return d.voice, nil
+}
+func (d *stubDriver) GetPortDescriptions(repo ports.SwitchRepository) (map[string]string, error) {
+	return d.descriptions, nil
+}
+func (d *stubDriver) DescribePortCommands(port entities.Port, description string) []string {
+	if description == "" {
+		return []string{"no description"}
+	}
+	return []string{"description " + description}
+}
+func (d *stubDriver) ShutdownPortCommands(port entities.Port, shutdown bool) []string {
+	if !shutdown {
+		return []string{"no shutdown " + port.Interface}
+	}
+	return []string{"shutdown " + port.Interface}
+}
+func (d *stubDriver) TrunkVlanCommands(port entities.Port, vlan string, allow bool) []string {
+	if !allow {
+		return []string{"trunk " + port.Interface + " remove " + vlan}
+	}
+	return []string{"trunk " + port.Interface + " add " + vlan}
+}
+func (d *stubDriver) ConfigureVoiceCommands(port entities.Port, vlan string) []string {
+	if vlan == "" {
+		return []string{"no voice vlan"}
+	}
+	return []string{"voice vlan " + vlan}
+}
 func (d *stubDriver) ConfigureAccessCommands(port entities.Port, vlan string) []string {
 	prefix := d.configurePrefix
 	if prefix == "" {
@@ -103,12 +148,18 @@ func (d *stubDriver) ConfigureAccessCommands(port entities.Port, vlan string) []
 	}
 	return []string{prefix + vlan}
 }
-func (d *stubDriver) CreateVLANCommands(vlan string) []string {
+func (d *stubDriver) CreateVLANCommands(vlan, name string) []string {
 	if d.createCmds != nil {
 		return append([]string{}, d.createCmds...)
 	}
+	if name != "" {
+		return []string{"vlan " + vlan, "name " + name}
+	}
 	return []string{"vlan " + vlan}
 }
+func (d *stubDriver) RenameVLANCommands(vlan, name string) []string {
+	return []string{"vlan " + vlan, "name " + name}
+}
 func (d *stubDriver) DeleteVLANCommands(vlan string) []string {
 	if d.deleteCmds != nil {
 		return append([]string{}, d.deleteCmds...)
@@ -118,7 +169,11 @@ func (d *stubDriver) DeleteVLANCommands(vlan string) []string {
 func (d *stubDriver) SaveCommands() []string {
 	return []string{"write memory"}
 }
-func (d *stubDriver) ClearCache() { d.cleared = true }
+func (d *stubDriver) AbortCommands() []string {
+	return []string{"end"}
+}
+func (d *stubDriver) ClearCache(string)               { d.cleared = true }
+func (d *stubDriver) IsReservedVLAN(vlan string) bool { return vlan == "1" }
 func (d *stubDriver) IsCommandError(output string) bool {
 	return d.commandErrorOut && output != ""
 }
@@ -143,7 +198,7 @@ func TestProcessPortsSaveConfiguration(t *testing.T) {
 	}
 
 	svc := NewVLANService(repo, cfg, drv)
-	if err := svc.ProcessPorts(); err != nil {
+	if _, err := svc.ProcessPorts(); err != nil {
 		t.Fatalf("ProcessPorts failed: %v", err)
 	}
 
@@ -164,7 +219,7 @@ func TestProcessPortsSaveConfiguration(t *testing.T) {
 		MacToVlan:   map[string]string{"aabbcc": "10"},
 	}
 	svcSandbox := NewVLANService(repoSandbox, cfgSandbox, drv)
-	if err := svcSandbox.ProcessPorts(); err != nil {
+	if _, err := svcSandbox.ProcessPorts(); err != nil {
 		t.Fatalf("ProcessPorts failed: %v", err)
 	}
 	for _, cmd := range repoSandbox.executed {
@@ -177,7 +232,7 @@ func TestProcessPortsSaveConfiguration(t *testing.T) {
 func TestProcessPortsConnectFailure(t *testing.T) {
 	repo := &mockRepository{connectErr: errors.New("dial refused")}
 	svc := NewVLANService(repo, entities.SwitchConfig{}, baseDriver())
-	if err := svc.ProcessPorts(); err == nil {
+	if _, err := svc.ProcessPorts(); err == nil {
 		t.Fatal("expected connect failure")
 	}
 }
@@ -191,7 +246,7 @@ func TestProcessPortsNoChanges(t *testing.T) {
 		DefaultVlan: "10",
 		MacToVlan:   map[string]string{"aabbcc": "10"},
 	}
-	if err := NewVLANService(repo, cfg, drv).ProcessPorts(); err != nil {
+	if _, err := NewVLANService(repo, cfg, drv).ProcessPorts(); err != nil {
 		t.Fatalf("ProcessPorts failed: %v", err)
 	}
 	if len(repo.executed) != 0 {
@@ -226,9 +281,20 @@ func TestProcessPortsSkipsTrunkExcludedAndMultiMAC(t *testing.T) {
 		ExcludeMacs:  []string{"aabbccddeeff"},
 		MacToVlan:    map[string]string{"deadbe": "10"},
 	}
-	if err := NewVLANService(repo, cfg, drv).ProcessPorts(); err != nil {
+	plan, err := NewVLANService(repo, cfg, drv).ProcessPorts()
+	if err != nil {
 		t.Fatalf("ProcessPorts failed: %v", err)
 	}
+	want := []entities.SkippedPort{
+		{Interface: "Gi1/0/24", Reason: entities.SkipTrunk},
+		{Interface: "Gi1/0/2", Reason: entities.SkipExcluded},
+		{Interface: "Gi1/0/3", Reason: entities.SkipExcluded},
+		{Interface: "Gi1/0/4", Reason: entities.SkipMultipleMacs},
+		{Interface: "Gi1/0/5", Reason: entities.SkipMultipleMacs},
+	}
+	if !reflect.DeepEqual(plan.Skipped, want) {
+		t.Fatalf("Skipped = %+v; expected %+v", plan.Skipped, want)
+	}
 }
 
 func TestProcessPortsDefaultVlanAndMissingVlan(t *testing.T) {
@@ -242,7 +308,7 @@ func TestProcessPortsDefaultVlanAndMissingVlan(t *testing.T) {
 		DefaultVlan: "10",
 		MacToVlan:   map[string]string{"ffffff": "0"},
 	}
-	if err := NewVLANService(repo, cfg, drv).ProcessPorts(); err != nil {
+	if _, err := NewVLANService(repo, cfg, drv).ProcessPorts(); err != nil {
 		t.Fatalf("ProcessPorts failed: %v", err)
 	}
 
@@ -253,9 +319,13 @@ func TestProcessPortsDefaultVlanAndMissingVlan(t *testing.T) {
 		DefaultVlan: "99",
 		MacToVlan:   map[string]string{"aabbcc": "99"},
 	}
-	if err := NewVLANService(repo, cfg2, drv2).ProcessPorts(); err != nil {
+	plan, err := NewVLANService(repo, cfg2, drv2).ProcessPorts()
+	if err != nil {
 		t.Fatalf("ProcessPorts failed: %v", err)
 	}
+	if len(plan.Skipped) != 1 || plan.Skipped[0].Reason != entities.SkipMissingVLAN {
+		t.Fatalf("expected missing VLAN skip, got %+v", plan.Skipped)
+	}
 }
 
 func TestProcessPortsCreateAndDeleteVLANs(t *testing.T) {
@@ -265,12 +335,12 @@ func TestProcessPortsCreateAndDeleteVLANs(t *testing.T) {
 	cfg := entities.SwitchConfig{
 		Sandbox:        false,
 		CreateVLANs:    true,
-		AllowedVlans:   []string{"1", "10"},
+		AllowedVlans:   []entities.Vlan{{ID: "1"}, {ID: "10"}},
 		ProtectedVlans: []string{"20"},
 		DefaultVlan:    "10",
 		MacToVlan:      map[string]string{"aabbcc": "10"},
 	}
-	if err := NewVLANService(repo, cfg, drv).ProcessPorts(); err != nil {
+	if _, err := NewVLANService(repo, cfg, drv).ProcessPorts(); err != nil {
 		t.Fatalf("ProcessPorts failed: %v", err)
 	}
 
@@ -297,6 +367,149 @@ func TestProcessPortsCreateAndDeleteVLANs(t *testing.T) {
 	}
 }
 
+func TestBuildPlanProtectedRanges(t *testing.T) {
+	deleted := func(cfg entities.SwitchConfig) []string {
+		t.Helper()
+		drv := &stubDriver{vlans: []string{"1", "10", "25", "1050", "1200", "3000"}}
+		cfg.Target = "10.0.0.1"
+		cfg.CreateVLANs = true
+		cfg.AllowedVlans = []entities.Vlan{{ID: "10"}}
+		plan, err := NewVLANService(&mockRepository{}, cfg, drv).BuildPlan()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var vlans []string
+		for _, a := range plan.Actions {
+			if a.Kind == entities.ActionDeleteVLAN {
+				vlans = append(vlans, a.Vlan)
+			}
+		}
+		return vlans
+	}
+	cases := []struct {
+		cfg      entities.SwitchConfig
+		expected []string
+	}{
+		{entities.SwitchConfig{}, []string{"25"}},
+		{entities.SwitchConfig{ProtectedRanges: []string{"2000-4094"}}, []string{"1050", "1200", "25"}},
+		{entities.SwitchConfig{ProtectedRanges: []string{"2000-4094"}, ProtectedVlans: []string{"20-29", "1200"}}, []string{"1050"}},
+		// VLAN 1 is reserved by the driver even with no protected range.
+		{entities.SwitchConfig{ProtectedRanges: []string{}}, []string{"1050", "1200", "25", "3000"}},
+	}
+	for _, tc := range cases {
+		if got := deleted(tc.cfg); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("ranges %v vlans %v: deleted %v; expected %v", tc.cfg.ProtectedRanges, tc.cfg.ProtectedVlans, got, tc.expected)
+		}
+	}
+}
+
+func TestBuildPlanSyncsUplinkVlans(t *testing.T) {
+	drv := &stubDriver{
+		vlans:  []string{"1", "10", "30"},
+		trunks: []string{"Gi1/0/23", "Gi1/0/24", "Gi1/0/25"},
+		trunkVlans: map[string][]string{
+			"Gi1/0/23": {"1", "10", "30"},
+			"Gi1/0/24": {"1", "30"},
+			// Not an uplink: never touched.
+			"Gi1/0/25": {"1"},
+		},
+	}
+	cfg := entities.SwitchConfig{
+		Target:       "10.0.0.1",
+		CreateVLANs:  true,
+		AllowedVlans: []entities.Vlan{{ID: "1"}, {ID: "10"}, {ID: "20"}},
+		UplinkPorts:  []string{"gi1/0/23-24"},
+	}
+	plan, err := NewVLANService(&mockRepository{}, cfg, drv).BuildPlan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, a := range plan.Actions {
+		got = append(got, a.Describe())
+	}
+	expected := []string{
+		"create VLAN 20",
+		"add VLAN 20 to trunk Gi1/0/23",
+		"add VLAN 10 to trunk Gi1/0/24",
+		"add VLAN 20 to trunk Gi1/0/24",
+		"remove VLAN 30 from trunk Gi1/0/23",
+		"remove VLAN 30 from trunk Gi1/0/24",
+		"delete VLAN 30",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("actions = %q; expected %q", got, expected)
+	}
+
+	cfg.CreateVLANs = false
+	drv.trunkVlans = nil
+	if plan, err = NewVLANService(&mockRepository{}, cfg, drv).BuildPlan(); err != nil || plan.HasChanges() {
+		t.Fatalf("without VLAN sync uplinks must be left alone, got %+v, %v", plan, err)
+	}
+}
+
+func TestApplySavedPlanWithUplinkVlans(t *testing.T) {
+	drv := &stubDriver{
+		vlans:      []string{"1", "30"},
+		trunks:     []string{"Gi1/0/24"},
+		trunkVlans: map[string][]string{"Gi1/0/24": {"1", "30"}},
+	}
+	cfg := entities.SwitchConfig{
+		Target:       "10.0.0.1",
+		Sandbox:      true,
+		CreateVLANs:  true,
+		AllowedVlans: []entities.Vlan{{ID: "1"}, {ID: "10"}},
+		UplinkPorts:  []string{"Gi1/0/24"},
+	}
+	built, err := NewVLANService(&mockRepository{}, cfg, drv).BuildPlan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(built)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var plan entities.Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Sandbox = false
+	repo := &mockRepository{}
+	if err := NewVLANService(repo, cfg, drv).ApplySavedPlan(&plan); err != nil {
+		t.Fatalf("ApplySavedPlan failed: %v", err)
+	}
+	expected := []string{"vlan 10", "trunk Gi1/0/24 add 10", "trunk Gi1/0/24 remove 30", "no vlan 30", "write memory"}
+	if !reflect.DeepEqual(repo.executed, expected) {
+		t.Fatalf("executed = %v; expected %v", repo.executed, expected)
+	}
+}
+
+func TestRollbackRunRestoresUplinkVlans(t *testing.T) {
+	drv := &stubDriver{
+		vlans:      []string{"1", "20"},
+		trunkVlans: map[string][]string{"Gi1/0/24": {"1", "20"}},
+	}
+	entry := &entities.JournalEntry{
+		RunID:    "run-1",
+		Target:   "10.0.0.1",
+		Platform: "stub",
+		Actions: []entities.PlanAction{
+			{Kind: entities.ActionTrunkAddVLAN, Interface: "Gi1/0/24", Vlan: "20"},
+			{Kind: entities.ActionTrunkRemoveVLAN, Interface: "Gi1/0/24", Vlan: "30"},
+			{Kind: entities.ActionDeleteVLAN, Vlan: "30"},
+		},
+	}
+	repo := &mockRepository{}
+	if _, err := NewVLANService(repo, entities.SwitchConfig{Target: "10.0.0.1"}, drv).RollbackRun(entry); err != nil {
+		t.Fatalf("RollbackRun failed: %v", err)
+	}
+	expected := []string{"vlan 30", "trunk Gi1/0/24 remove 20", "trunk Gi1/0/24 add 30", "write memory"}
+	if !reflect.DeepEqual(repo.executed, expected) {
+		t.Fatalf("executed = %v; expected %v", repo.executed, expected)
+	}
+}
+
 func TestProcessPortsCreateVLANSandbox(t *testing.T) {
 	repo := &mockRepository{}
 	drv := baseDriver()
@@ -304,11 +517,11 @@ func TestProcessPortsCreateVLANSandbox(t *testing.T) {
 	cfg := entities.SwitchConfig{
 		Sandbox:      true,
 		CreateVLANs:  true,
-		AllowedVlans: []string{"1", "10"},
+		AllowedVlans: []entities.Vlan{{ID: "1"}, {ID: "10"}},
 		DefaultVlan:  "10",
 		MacToVlan:    map[string]string{"aabbcc": "10"},
 	}
-	if err := NewVLANService(repo, cfg, drv).ProcessPorts(); err != nil {
+	if _, err := NewVLANService(repo, cfg, drv).ProcessPorts(); err != nil {
 		t.Fatalf("ProcessPorts failed: %v", err)
 	}
 	for _, cmd := range repo.executed {
@@ -337,8 +550,13 @@ func TestConfigureVlanWriteAndCommandError(t *testing.T) {
 	drvErr := baseDriver()
 	drvErr.commandErrorOut = true
 	repoOut := &mockRepository{commandOut: "Invalid input"}
-	if err := NewVLANService(repoOut, entities.SwitchConfig{Sandbox: false}, drvErr).ConfigureVlan("Gi1/0/1", "10"); err == nil {
-		t.Fatal("expected IsCommandError path")
+	err := NewVLANService(repoOut, entities.SwitchConfig{Sandbox: false}, drvErr).ConfigureVlan("Gi1/0/1", "10")
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) || cmdErr.Output != "Invalid input" {
+		t.Fatalf("expected CommandError from IsCommandError path, got %v", err)
+	}
+	if !reflect.DeepEqual(repoOut.executed, []string{"switchport access vlan 10", "end"}) {
+		t.Fatalf("expected configuration mode left after the rejected command, executed %v", repoOut.executed)
 	}
 }
 
@@ -384,7 +602,7 @@ func TestProcessPortsPropagatesDriverErrors(t *testing.T) {
 	}
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			err := NewVLANService(&mockRepository{}, entities.SwitchConfig{}, tc.drv).ProcessPorts()
+			_, err := NewVLANService(&mockRepository{}, entities.SwitchConfig{}, tc.drv).ProcessPorts()
 			if err == nil {
 				t.Fatal("expected error")
 			}
@@ -399,9 +617,9 @@ func TestProcessPortsCreateVLANFailure(t *testing.T) {
 	cfg := entities.SwitchConfig{
 		Sandbox:      false,
 		CreateVLANs:  true,
-		AllowedVlans: []string{"1", "10"},
+		AllowedVlans: []entities.Vlan{{ID: "1"}, {ID: "10"}},
 	}
-	if err := NewVLANService(repo, cfg, drv).ProcessPorts(); err == nil {
+	if _, err := NewVLANService(repo, cfg, drv).ProcessPorts(); err == nil {
 		t.Fatal("expected create VLAN failure")
 	}
 }
@@ -413,9 +631,9 @@ func TestProcessPortsDeleteVLANFailure(t *testing.T) {
 	cfg := entities.SwitchConfig{
 		Sandbox:      false,
 		CreateVLANs:  true,
-		AllowedVlans: []string{"1"},
+		AllowedVlans: []entities.Vlan{{ID: "1"}},
 	}
-	if err := NewVLANService(repo, cfg, drv).ProcessPorts(); err == nil {
+	if _, err := NewVLANService(repo, cfg, drv).ProcessPorts(); err == nil {
 		t.Fatal("expected delete VLAN failure")
 	}
 }
@@ -428,7 +646,7 @@ func TestProcessPortsConfigureFailure(t *testing.T) {
 		DefaultVlan: "10",
 		MacToVlan:   map[string]string{"aabbcc": "10"},
 	}
-	if err := NewVLANService(repo, cfg, drv).ProcessPorts(); err == nil {
+	if _, err := NewVLANService(repo, cfg, drv).ProcessPorts(); err == nil {
 		t.Fatal("expected configure failure")
 	}
 }
@@ -437,15 +655,16 @@ func TestGettersAndHelpers(t *testing.T) {
 	repo := &mockRepository{}
 	drv := baseDriver()
 	drv.trunks = []string{"Gi1/0/24"}
+	drv.vlanNames = map[string]string{"10": "USERS"}
 	svc := NewVLANService(repo, entities.SwitchConfig{
-		AllowedVlans:   []string{"10", "20"},
+		AllowedVlans:   []entities.Vlan{{ID: "10"}, {ID: "20"}},
 		ProtectedVlans: []string{"30"},
 		ExcludeMacs:    []string{"AABBCCDDEEFF"},
 		ExcludePorts:   []string{"Gi1/0/9"},
 	}, drv)
 
 	vlans, err := svc.GetVlanList()
-	if err != nil || !vlans["10"] {
+	if _, ok := vlans["1"]; err != nil || !ok || vlans["10"] != "USERS" {
 		t.Fatalf("GetVlanList = %v, %v", vlans, err)
 	}
 	trunks, err := svc.GetTrunkInterfaces()
@@ -488,7 +707,1112 @@ func TestSaveConfigurationError(t *testing.T) {
 		DefaultVlan: "10",
 		MacToVlan:   map[string]string{"aabbcc": "10"},
 	}
-	if err := NewVLANService(repo, cfg, drv).ProcessPorts(); err == nil {
+	if _, err := NewVLANService(repo, cfg, drv).ProcessPorts(); err == nil {
 		t.Fatal("expected save configuration failure")
 	}
 }
+
+func TestBuildPlanRecordsDecisions(t *testing.T) {
+	repo := &mockRepository{}
+	drv := &stubDriver{
+		vlans: []string{"1", "10", "40"},
+		ports: []entities.Port{
+			{Interface: "Gi1/0/1", Vlan: "1"},
+			{Interface: "Gi1/0/2", Vlan: "1"},
+		},
+		devices: []entities.Device{
+			{Mac: "aabbccddeeff", MacFull: "aa:bb:cc:dd:ee:ff", Interface: "Gi1/0/1"},
+			{Mac: "0011223344ff", MacFull: "00:11:22:33:44:ff", Interface: "Gi1/0/2"},
+		},
+	}
+	cfg := entities.SwitchConfig{
+		Target:       "10.0.0.1",
+		Sandbox:      true,
+		CreateVLANs:  true,
+		AllowedVlans: []entities.Vlan{{ID: "1"}, {ID: "10"}, {ID: "20"}},
+		DefaultVlan:  "20",
+		MacToVlan:    map[string]string{"aabbcc": "10"},
+	}
+	plan, err := NewVLANService(repo, cfg, drv).BuildPlan()
+	if err != nil {
+		t.Fatalf("BuildPlan failed: %v", err)
+	}
+	if len(repo.executed) != 0 {
+		t.Fatalf("BuildPlan must not execute commands, got %v", repo.executed)
+	}
+	if plan.Target != "10.0.0.1" || plan.Platform != "stub" || !plan.Sandbox {
+		t.Fatalf("unexpected plan header: %+v", plan)
+	}
+	expected := []entities.PlanAction{
+		{Kind: entities.ActionCreateVLAN, Vlan: "20", Rule: "allowed_vlans", Commands: []string{"vlan 20"}},
+		{Kind: entities.ActionDeleteVLAN, Vlan: "40", Rule: "not in allowed_vlans", Commands: []string{"no vlan 40"}},
+		{Kind: entities.ActionConfigureAccess, Interface: "Gi1/0/1", CurrentVlan: "1", TargetVlan: "10",
+			Mac: "aa:bb:cc:dd:ee:ff", Rule: "mac_to_vlan aabbcc", Commands: []string{"switchport access vlan 10"}},
+		{Kind: entities.ActionConfigureAccess, Interface: "Gi1/0/2", CurrentVlan: "1", TargetVlan: "20",
+			Mac: "00:11:22:33:44:ff", Rule: "default_vlan", Commands: []string{"switchport access vlan 20"}},
+	}
+	if !reflect.DeepEqual(plan.Actions, expected) {
+		t.Errorf("plan actions = %+v\nexpected %+v", plan.Actions, expected)
+	}
+}
+
+func TestBuildPlanNamesVlans(t *testing.T) {
+	drv := &stubDriver{
+		vlans:     []string{"1", "10", "20", "40"},
+		vlanNames: map[string]string{"1": "default", "10": "VLAN0010", "20": "LAB", "40": "OLD"},
+	}
+	cfg := entities.SwitchConfig{
+		Target:      "10.0.0.1",
+		DefaultVlan: "10",
+		CreateVLANs: true,
+		AllowedVlans: []entities.Vlan{
+			{ID: "1"}, {ID: "10", Name: "USERS"}, {ID: "20", Name: "LAB"}, {ID: "30", Name: "PHONES"},
+		},
+	}
+	plan, err := NewVLANService(&mockRepository{}, cfg, drv).BuildPlan()
+	if err != nil {
+		t.Fatalf("BuildPlan failed: %v", err)
+	}
+	expected := []entities.PlanAction{
+		{Kind: entities.ActionRenameVLAN, Vlan: "10", Name: "USERS", CurrentName: "VLAN0010", Rule: "allowed_vlans", Commands: []string{"vlan 10", "name USERS"}},
+		{Kind: entities.ActionCreateVLAN, Vlan: "30", Name: "PHONES", Rule: "allowed_vlans", Commands: []string{"vlan 30", "name PHONES"}},
+		{Kind: entities.ActionDeleteVLAN, Vlan: "40", Name: "OLD", Rule: "not in allowed_vlans", Commands: []string{"no vlan 40"}},
+	}
+	if !reflect.DeepEqual(plan.Actions, expected) {
+		t.Errorf("plan actions = %+v\nexpected %+v", plan.Actions, expected)
+	}
+
+	cfg.CreateVLANs = false
+	plan, err = NewVLANService(&mockRepository{}, cfg, drv).BuildPlan()
+	if err != nil || plan.HasChanges() {
+		t.Fatalf("names must only be corrected with create_vlans, got %+v, %v", plan, err)
+	}
+}
+
+func TestBuildPlanWritesPortDescriptions(t *testing.T) {
+	drv := &stubDriver{
+		vlans: []string{"1", "10", "30"},
+		ports: []entities.Port{
+			{Interface: "Gi1/0/1", Vlan: "1"},
+			{Interface: "Gi1/0/2", Vlan: "1"},
+			{Interface: "Gi1/0/3", Vlan: "1"},
+			{Interface: "Gi1/0/4", Vlan: "30"},
+		},
+		devices: []entities.Device{
+			{Mac: "aabbcc000001", MacFull: "aa:bb:cc:00:00:01", Interface: "Gi1/0/1"},
+			{Mac: "aabbcc000002", MacFull: "aa:bb:cc:00:00:02", Interface: "Gi1/0/2"},
+			{Mac: "aabbcc000003", MacFull: "aa:bb:cc:00:00:03", Interface: "Gi1/0/3"},
+			{Mac: "aabbcc000004", MacFull: "aa:bb:cc:00:00:04", Interface: "Gi1/0/4"},
+		},
+		descriptions: map[string]string{"Gi1/0/2": "Printer 2nd floor", "Gi1/0/3": "negev:old", "Gi1/0/4": "negev:old"},
+	}
+	cfg := entities.SwitchConfig{
+		Target:          "10.0.0.1",
+		DefaultVlan:     "10",
+		MacToVlan:       map[string]string{"aabbcc": "30"},
+		PortDescription: "negev:{vendor}:{vlan}:{rule}",
+	}
+	plan, err := NewVLANService(&mockRepository{}, cfg, drv).BuildPlan()
+	if err != nil {
+		t.Fatalf("BuildPlan failed: %v", err)
+	}
+	var described []entities.PlanAction
+	for _, a := range plan.Actions {
+		if a.Kind == entities.ActionDescribePort {
+			described = append(described, a)
+		}
+	}
+	expected := []entities.PlanAction{
+		{Kind: entities.ActionDescribePort, Interface: "Gi1/0/1", Description: "negev:aabbcc:30:mac_to_vlan_aabbcc",
+			Mac: "aa:bb:cc:00:00:01", Rule: "mac_to_vlan aabbcc", Commands: []string{"description negev:aabbcc:30:mac_to_vlan_aabbcc"}},
+		{Kind: entities.ActionDescribePort, Interface: "Gi1/0/3", Description: "negev:aabbcc:30:mac_to_vlan_aabbcc", CurrentDescription: "negev:old",
+			Mac: "aa:bb:cc:00:00:03", Rule: "mac_to_vlan aabbcc", Commands: []string{"description negev:aabbcc:30:mac_to_vlan_aabbcc"}},
+	}
+	if !reflect.DeepEqual(described, expected) {
+		t.Errorf("describe actions = %+v\nexpected %+v", described, expected)
+	}
+	if plan.ChangedPorts() != 3 {
+		t.Errorf("descriptions must not count as changed ports, got %d", plan.ChangedPorts())
+	}
+
+	// A description written by hand between plan and apply is drift.
+	drv.descriptions["Gi1/0/3"] = "Reception desk"
+	repo := &mockRepository{}
+	if err := NewVLANService(repo, cfg, drv).ApplySavedPlan(plan); !errors.Is(err, ErrStateDrift) {
+		t.Fatalf("expected drift after a manual description, got %v", err)
+	}
+	if len(repo.executed) != 0 {
+		t.Fatalf("nothing may run on drift, executed %v", repo.executed)
+	}
+}
+
+func TestTextOutputNamesTheSwitch(t *testing.T) {
+	var out strings.Builder
+	svc := NewVLANService(&mockRepository{}, entities.SwitchConfig{Target: "10.0.0.1", DefaultVlan: "10", Sandbox: true}, baseDriver())
+	svc.out = &out
+	if _, err := svc.ProcessPorts(); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) < 2 {
+		t.Fatalf("expected simulated commands and a summary, got %q", out.String())
+	}
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "[10.0.0.1] ") {
+			t.Errorf("line without the switch target: %q", line)
+		}
+	}
+}
+
+func TestApplyPlanExecutesActionsInOrder(t *testing.T) {
+	repo := &mockRepository{}
+	plan := &entities.Plan{Actions: []entities.PlanAction{
+		{Kind: entities.ActionCreateVLAN, Vlan: "20", Commands: []string{"vlan 20"}},
+		{Kind: entities.ActionConfigureAccess, Interface: "Gi1/0/1", Commands: []string{"switchport access vlan 20"}},
+	}}
+	svc := NewVLANService(repo, entities.SwitchConfig{OutputFormat: "json"}, baseDriver())
+	if err := svc.ApplyPlan(plan); err != nil {
+		t.Fatalf("ApplyPlan failed: %v", err)
+	}
+	expected := []string{"vlan 20", "switchport access vlan 20", "write memory"}
+	if !reflect.DeepEqual(repo.executed, expected) {
+		t.Errorf("executed = %v; expected %v", repo.executed, expected)
+	}
+
+	repoFail := &mockRepository{failOnCmd: "switchport access vlan 20"}
+	err := NewVLANService(repoFail, entities.SwitchConfig{}, baseDriver()).ApplyPlan(plan)
+	if err == nil || !strings.Contains(err.Error(), "configure VLAN on port Gi1/0/1") {
+		t.Fatalf("expected action-scoped error, got %v", err)
+	}
+}
+
+func TestApplySavedPlan(t *testing.T) {
+	drv := baseDriver()
+	cfg := entities.SwitchConfig{
+		Target:      "10.0.0.1",
+		Sandbox:     true,
+		DefaultVlan: "10",
+		MacToVlan:   map[string]string{"aabbcc": "10"},
+	}
+	plan, err := NewVLANService(&mockRepository{}, cfg, drv).BuildPlan()
+	if err != nil || len(plan.Actions) != 1 {
+		t.Fatalf("BuildPlan = %+v, %v", plan, err)
+	}
+	plan.Actions[0].Commands = []string{"reload"}
+
+	cfg.Sandbox = false
+	repo := &mockRepository{}
+	if err := NewVLANService(repo, cfg, drv).ApplySavedPlan(plan); err != nil {
+		t.Fatalf("ApplySavedPlan failed: %v", err)
+	}
+	expected := []string{"switchport access vlan 10", "write memory"}
+	if !reflect.DeepEqual(repo.executed, expected) {
+		t.Errorf("executed = %v; expected commands rebuilt by the driver %v", repo.executed, expected)
+	}
+
+	drifted := baseDriver()
+	drifted.ports = []entities.Port{{Interface: "Gi1/0/1", Vlan: "10"}}
+	repoDrift := &mockRepository{}
+	err = NewVLANService(repoDrift, cfg, drifted).ApplySavedPlan(plan)
+	if !errors.Is(err, ErrStateDrift) {
+		t.Fatalf("expected ErrStateDrift, got %v", err)
+	}
+	if len(repoDrift.executed) != 0 {
+		t.Errorf("no command may run after drift, got %v", repoDrift.executed)
+	}
+
+	other := cfg
+	other.Target = "10.0.0.2"
+	if err := NewVLANService(&mockRepository{}, other, drv).ApplySavedPlan(plan); err == nil {
+		t.Error("expected target mismatch error")
+	}
+
+	bad := *plan
+	bad.Actions = []entities.PlanAction{{Kind: "reboot"}}
+	if err := NewVLANService(&mockRepository{}, cfg, drv).ApplySavedPlan(&bad); err == nil {
+		t.Error("expected unknown action kind error")
+	}
+}
+
+func TestInventoryReportsObservedState(t *testing.T) {
+	repo := &mockRepository{}
+	drv := &stubDriver{
+		vlans:  []string{"100", "1", "20"},
+		trunks: []string{"Gi1/0/24"},
+		ports: []entities.Port{
+			{Interface: "Gi1/0/1", Vlan: "1"},
+			{Interface: "Gi1/0/2", Vlan: "20"},
+			{Interface: "Gi1/0/24", Vlan: "1"},
+		},
+		devices: []entities.Device{
+			{Mac: "aabbccddeeff", MacFull: "aa:bb:cc:dd:ee:ff", Interface: "Gi1/0/1"},
+			{Mac: "112233445566", MacFull: "11:22:33:44:55:66", Interface: "Gi1/0/1"},
+		},
+	}
+	cfg := entities.SwitchConfig{
+		Target:       "10.0.0.1",
+		ExcludePorts: []string{"Gi1/0/2"},
+		ExcludeMacs:  []string{"112233445566"},
+	}
+	inv, err := NewVLANService(repo, cfg, drv).Inventory()
+	if err != nil {
+		t.Fatalf("Inventory failed: %v", err)
+	}
+	if len(repo.executed) != 0 {
+		t.Fatalf("inventory must not run commands, got %v", repo.executed)
+	}
+	want := &entities.Inventory{
+		Target:   "10.0.0.1",
+		Platform: "stub",
+		Vlans:    []string{"1", "20", "100"},
+		Ports: []entities.InventoryPort{
+			{Interface: "Gi1/0/1", Vlan: "1", Macs: []entities.InventoryMac{
+				{Mac: "aa:bb:cc:dd:ee:ff", OUI: "aabbcc"},
+				{Mac: "11:22:33:44:55:66", OUI: "112233", Excluded: true},
+			}},
+			{Interface: "Gi1/0/2", Vlan: "20", Excluded: true, Macs: []entities.InventoryMac{}},
+			{Interface: "Gi1/0/24", Vlan: "1", Trunk: true, Macs: []entities.InventoryMac{}},
+		},
+	}
+	if !reflect.DeepEqual(inv, want) {
+		t.Fatalf("Inventory() = %+v\nexpected %+v", inv, want)
+	}
+
+	if _, err := NewVLANService(&mockRepository{connectErr: errors.New("down")}, cfg, drv).Inventory(); err == nil {
+		t.Fatal("expected connect error")
+	}
+}
+
+type memoryJournal struct {
+	entries []*entities.JournalEntry
+	err     error
+}
+
+func (j *memoryJournal) Record(entry *entities.JournalEntry) error {
+	if j.err != nil {
+		return j.err
+	}
+	entry.RunID = fmt.Sprintf("run-%d", len(j.entries)+1)
+	j.entries = append(j.entries, entry)
+	return nil
+}
+
+func TestApplyPlanRecordsJournalBeforeExecuting(t *testing.T) {
+	cfg := entities.SwitchConfig{Target: "10.0.0.1", DefaultVlan: "10"}
+
+	sandbox := cfg
+	sandbox.Sandbox = true
+	journal := &memoryJournal{}
+	svc := NewVLANService(&mockRepository{}, sandbox, baseDriver())
+	svc.SetJournal(journal)
+	if _, err := svc.ProcessPorts(); err != nil {
+		t.Fatal(err)
+	}
+	if len(journal.entries) != 0 {
+		t.Fatalf("sandbox runs must not be journaled, got %+v", journal.entries)
+	}
+
+	svc = NewVLANService(&mockRepository{}, cfg, baseDriver())
+	svc.SetJournal(journal)
+	if _, err := svc.ProcessPorts(); err != nil {
+		t.Fatal(err)
+	}
+	if len(journal.entries) != 1 {
+		t.Fatalf("expected one journal entry, got %d", len(journal.entries))
+	}
+	a := journal.entries[0].Actions[0]
+	if a.Interface != "Gi1/0/1" || a.CurrentVlan != "1" || a.TargetVlan != "10" || journal.entries[0].Platform != "stub" {
+		t.Fatalf("unexpected journal entry: %+v", journal.entries[0])
+	}
+
+	repo := &mockRepository{}
+	svc = NewVLANService(repo, cfg, baseDriver())
+	svc.SetJournal(&memoryJournal{err: errors.New("disk full")})
+	if _, err := svc.ProcessPorts(); err == nil {
+		t.Fatal("expected journal error")
+	}
+	for _, cmd := range repo.executed {
+		if strings.HasPrefix(cmd, "switchport") {
+			t.Fatalf("nothing may run when the journal cannot be written, got %v", repo.executed)
+		}
+	}
+}
+
+func TestRollbackRun(t *testing.T) {
+	drv := &stubDriver{
+		vlans:        []string{"1", "10", "30"},
+		vlanNames:    map[string]string{"10": "STAFF"},
+		descriptions: map[string]string{"Gi1/0/1": "negev:10"},
+		ports: []entities.Port{
+			{Interface: "Gi1/0/1", Vlan: "10"},
+			{Interface: "Gi1/0/2", Vlan: "20"},
+		},
+	}
+	entry := &entities.JournalEntry{
+		RunID:    "run-1",
+		Target:   "10.0.0.1",
+		Platform: "stub",
+		Actions: []entities.PlanAction{
+			{Kind: entities.ActionCreateVLAN, Vlan: "30"},
+			{Kind: entities.ActionDeleteVLAN, Vlan: "40", Name: "GUESTS"},
+			{Kind: entities.ActionRenameVLAN, Vlan: "10", Name: "STAFF", CurrentName: "USERS"},
+			{Kind: entities.ActionConfigureAccess, Interface: "Gi1/0/1", CurrentVlan: "40", TargetVlan: "10"},
+			{Kind: entities.ActionDescribePort, Interface: "Gi1/0/1", Description: "negev:10", CurrentDescription: "Desk"},
+			{Kind: entities.ActionConfigureAccess, Interface: "Gi1/0/2", CurrentVlan: "1", TargetVlan: "10"},
+			{Kind: entities.ActionDescribePort, Interface: "Gi1/0/2", Description: "negev:10"},
+			{Kind: entities.ActionConfigureAccess, Interface: "Gi1/0/3", CurrentVlan: "1", TargetVlan: "10"},
+		},
+	}
+	repo := &mockRepository{}
+	journal := &memoryJournal{}
+	svc := NewVLANService(repo, entities.SwitchConfig{Target: "10.0.0.1"}, drv)
+	svc.SetJournal(journal)
+	plan, err := svc.RollbackRun(entry)
+	if err != nil {
+		t.Fatalf("RollbackRun failed: %v", err)
+	}
+	expected := []string{"vlan 40", "name GUESTS", "vlan 10", "name USERS", "switchport access vlan 40", "description Desk", "no vlan 30", "write memory"}
+	if !reflect.DeepEqual(repo.executed, expected) {
+		t.Fatalf("executed = %v; expected %v", repo.executed, expected)
+	}
+	if len(plan.Actions) != 5 || plan.Actions[1].Rule != "rollback run-1" || plan.RollbackOf != "run-1" {
+		t.Fatalf("unexpected plan: %+v", plan.Actions)
+	}
+	if len(journal.entries) != 1 || journal.entries[0].RollbackOf != "run-1" {
+		t.Fatal("expected the rollback itself to be journaled")
+	}
+
+	other := *entry
+	other.Platform = "ios"
+	if _, err := svc.RollbackRun(&other); err == nil {
+		t.Fatal("expected platform mismatch error")
+	}
+	other = *entry
+	other.Target = "10.0.0.2"
+	if _, err := svc.RollbackRun(&other); err == nil {
+		t.Fatal("expected target mismatch error")
+	}
+}
+
+type memoryPortHistory struct {
+	saved *entities.PortHistory
+}
+
+func (h *memoryPortHistory) Load(target string) (*entities.PortHistory, error) {
+	if h.saved == nil {
+		return &entities.PortHistory{Target: target, Ports: map[string]entities.PortRecord{}}, nil
+	}
+	return h.saved, nil
+}
+
+func (h *memoryPortHistory) Save(history *entities.PortHistory) error {
+	h.saved = history
+	return nil
+}
+
+func TestBuildPlanQuarantinesMacLessPorts(t *testing.T) {
+	drv := &stubDriver{
+		vlans: []string{"1", "10", "999"},
+		ports: []entities.Port{
+			{Interface: "Gi1/0/1", Vlan: "10"},
+			{Interface: "Gi1/0/2", Vlan: "10"},
+		},
+		devices: []entities.Device{
+			{Mac: "aabbccddeeff", MacFull: "aa:bb:cc:dd:ee:ff", Interface: "Gi1/0/1"},
+		},
+	}
+	cfg := entities.SwitchConfig{Target: "10.0.0.1", DefaultVlan: "10", NoDataVlan: "999", NoDataAfter: "30m"}
+	history := &memoryPortHistory{}
+	clock := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	newService := func() *VLANServiceImpl {
+		svc := NewVLANService(&mockRepository{}, cfg, drv)
+		svc.SetPortHistory(history)
+		svc.now = func() time.Time { return clock }
+		return svc
+	}
+
+	plan, err := newService().BuildPlan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.HasChanges() {
+		t.Fatalf("port must not move before no_data_after elapsed: %+v", plan.Actions)
+	}
+	if got := history.saved.Ports["gi1/0/2"].NoMacSince; !got.Equal(clock) {
+		t.Fatalf("expected Gi1/0/2 MAC-less since %v, got %v", clock, got)
+	}
+	if !history.saved.Ports["gi1/0/1"].NoMacSince.IsZero() {
+		t.Fatal("port with a MAC must not be tracked as MAC-less")
+	}
+
+	clock = clock.Add(10 * time.Minute)
+	if err := newService().ApplySavedPlan(plan); err != nil {
+		t.Fatal(err)
+	}
+	if got := history.saved.Ports["gi1/0/2"].NoMacSince; !got.Equal(clock.Add(-10 * time.Minute)) {
+		t.Fatalf("applying a plan must keep the MAC-less period, got %v", got)
+	}
+
+	clock = clock.Add(20 * time.Minute)
+	plan, err = newService().BuildPlan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Actions) != 1 || plan.Actions[0].Interface != "Gi1/0/2" || plan.Actions[0].TargetVlan != "999" || plan.Actions[0].Rule != "no_data_vlan" {
+		t.Fatalf("expected Gi1/0/2 quarantined, got %+v", plan.Actions)
+	}
+
+	drv.ports[1].Vlan = "999"
+	drv.devices = append(drv.devices, entities.Device{Mac: "001122334455", MacFull: "00:11:22:33:44:55", Interface: "Gi1/0/2"})
+	plan, err = newService().BuildPlan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Actions) != 1 || plan.Actions[0].TargetVlan != "10" || plan.Actions[0].Rule != "default_vlan" {
+		t.Fatalf("expected Gi1/0/2 back on its mapped VLAN, got %+v", plan.Actions)
+	}
+	for iface, record := range history.saved.Ports {
+		if !record.NoMacSince.IsZero() {
+			t.Fatalf("expected %s cleared once a MAC is learned, got %+v", iface, record)
+		}
+	}
+}
+
+func TestBuildPlanWithoutNoDataAfterIgnoresMacLessPorts(t *testing.T) {
+	drv := &stubDriver{
+		vlans: []string{"1", "999"},
+		ports: []entities.Port{{Interface: "Gi1/0/2", Vlan: "1"}},
+	}
+	history := &memoryPortHistory{}
+	svc := NewVLANService(&mockRepository{}, entities.SwitchConfig{Target: "10.0.0.1", DefaultVlan: "1", NoDataVlan: "999"}, drv)
+	svc.SetPortHistory(history)
+	plan, err := svc.BuildPlan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.HasChanges() || !history.saved.Ports["gi1/0/2"].NoMacSince.IsZero() {
+		t.Fatalf("quarantine must be opt-in, got %+v", plan.Actions)
+	}
+}
+
+func TestBuildPlanLongestPrefixMatch(t *testing.T) {
+	drv := &stubDriver{
+		vlans: []string{"1", "10", "20", "30", "40"},
+		ports: []entities.Port{
+			{Interface: "Gi1/0/1", Vlan: "1"},
+			{Interface: "Gi1/0/2", Vlan: "1"},
+			{Interface: "Gi1/0/3", Vlan: "1"},
+			{Interface: "Gi1/0/4", Vlan: "1"},
+		},
+		devices: []entities.Device{
+			{Mac: "aabbcc000001", MacFull: "aa:bb:cc:00:00:01", Interface: "Gi1/0/1"},
+			{Mac: "aabbccd00001", MacFull: "aa:bb:cc:d0:00:01", Interface: "Gi1/0/2"},
+			{Mac: "aabbccd12345", MacFull: "aa:bb:cc:d1:23:45", Interface: "Gi1/0/3"},
+			{Mac: "aabbcce12345", MacFull: "aa:bb:cc:e1:23:45", Interface: "Gi1/0/4"},
+		},
+	}
+	cfg := entities.SwitchConfig{
+		Target:      "10.0.0.1",
+		DefaultVlan: "1",
+		MacToVlan: map[string]string{
+			"aabbcc":       "10",
+			"aabbccd":      "20",
+			"aabbccd12345": "30",
+			"aabbcce/26":   "40",
+		},
+	}
+	plan, err := NewVLANService(&mockRepository{}, cfg, drv).BuildPlan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"Gi1/0/1": "10 mac_to_vlan aabbcc",
+		"Gi1/0/2": "20 mac_to_vlan aabbccd",
+		"Gi1/0/3": "30 mac_to_vlan aabbccd12345",
+		"Gi1/0/4": "40 mac_to_vlan aabbcce/26",
+	}
+	got := map[string]string{}
+	for _, a := range plan.Actions {
+		got[a.Interface] = a.TargetVlan + " " + a.Rule
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %v; expected %v", got, expected)
+	}
+}
+
+type stubVendors map[string]string
+
+func (v stubVendors) Vendor(mac string) string { return v[mac[:6]] }
+
+func TestBuildPlanVendorToVlan(t *testing.T) {
+	drv := &stubDriver{
+		vlans: []string{"1", "10", "20", "30"},
+		ports: []entities.Port{
+			{Interface: "Gi1/0/1", Vlan: "1"},
+			{Interface: "Gi1/0/2", Vlan: "1"},
+			{Interface: "Gi1/0/3", Vlan: "1"},
+			{Interface: "Gi1/0/4", Vlan: "1"},
+		},
+		devices: []entities.Device{
+			{Mac: "00074d000001", MacFull: "00:07:4d:00:00:01", Interface: "Gi1/0/1"},
+			{Mac: "001122000002", MacFull: "00:11:22:00:00:02", Interface: "Gi1/0/2"},
+			{Mac: "aabbcc000003", MacFull: "aa:bb:cc:00:00:03", Interface: "Gi1/0/3"},
+			{Mac: "00074d000004", MacFull: "00:07:4d:00:00:04", Interface: "Gi1/0/4"},
+		},
+	}
+	cfg := entities.SwitchConfig{
+		Target:      "10.0.0.1",
+		DefaultVlan: "1",
+		MacToVlan:   map[string]string{"00074d000004": "30"},
+		VendorToVlan: map[string]string{
+			"Zebra":              "10",
+			"Zebra Technologies": "20",
+			"re:^cisco":          "30",
+		},
+	}
+	svc := NewVLANService(&mockRepository{}, cfg, drv)
+	svc.SetVendorLookup(stubVendors{"00074d": "Zebra Technologies Inc.", "001122": "Cisco Systems, Inc"})
+	plan, err := svc.BuildPlan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"Gi1/0/1": "20 vendor_to_vlan Zebra Technologies Zebra Technologies Inc.",
+		"Gi1/0/2": "30 vendor_to_vlan re:^cisco Cisco Systems, Inc",
+		"Gi1/0/4": "30 mac_to_vlan 00074d000004 Zebra Technologies Inc.",
+	}
+	got := map[string]string{}
+	for _, a := range plan.Actions {
+		got[a.Interface] = a.TargetVlan + " " + a.Rule + " " + a.Vendor
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %v; expected %v", got, expected)
+	}
+}
+
+func TestBuildPlanVoiceVlan(t *testing.T) {
+	drv := &stubDriver{
+		vlans: []string{"1", "10", "30"},
+		ports: []entities.Port{
+			{Interface: "Gi1/0/1", Vlan: "1"},
+			{Interface: "Gi1/0/2", Vlan: "10"},
+			{Interface: "Gi1/0/3", Vlan: "1"},
+			{Interface: "Gi1/0/4", Vlan: "1"},
+		},
+		devices: []entities.Device{
+			{Mac: "0004f2000001", MacFull: "00:04:f2:00:00:01", Interface: "Gi1/0/1"},
+			{Mac: "aabbcc000001", MacFull: "aa:bb:cc:00:00:01", Interface: "Gi1/0/1"},
+			{Mac: "0004f2000002", MacFull: "00:04:f2:00:00:02", Interface: "Gi1/0/2"},
+			{Mac: "0004f2000003", MacFull: "00:04:f2:00:00:03", Interface: "Gi1/0/3"},
+			{Mac: "aabbcc000003", MacFull: "aa:bb:cc:00:00:03", Interface: "Gi1/0/3"},
+			{Mac: "aabbcc000004", MacFull: "aa:bb:cc:00:00:04", Interface: "Gi1/0/3"},
+			{Mac: "0004f2000004", MacFull: "00:04:f2:00:00:04", Interface: "Gi1/0/4"},
+			{Mac: "0004f2000005", MacFull: "00:04:f2:00:00:05", Interface: "Gi1/0/4"},
+		},
+		voice: map[string]string{"Gi1/0/2": "30"},
+	}
+	cfg := entities.SwitchConfig{
+		Target:      "10.0.0.1",
+		DefaultVlan: "1",
+		MacToVlan:   map[string]string{"aabbcc": "10"},
+		VoiceVlan:   "30",
+		VoiceMacs:   []string{"0004f2"},
+	}
+	plan, err := NewVLANService(&mockRepository{}, cfg, drv).BuildPlan()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, a := range plan.Actions {
+		got = append(got, fmt.Sprintf("%s %s %s->%s", a.Kind, a.Interface, a.CurrentVlan, a.TargetVlan))
+	}
+	expected := []string{
+		"configure_voice Gi1/0/1 ->30",
+		"configure_access Gi1/0/1 1->10",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("actions = %v; expected %v", got, expected)
+	}
+	if plan.ChangedPorts() != 1 {
+		t.Errorf("ChangedPorts() = %d; expected 1", plan.ChangedPorts())
+	}
+	skipped := map[string]entities.SkipReason{}
+	for _, sp := range plan.Skipped {
+		skipped[sp.Interface] = sp.Reason
+	}
+	expectedSkips := map[string]entities.SkipReason{
+		"Gi1/0/3": entities.SkipMultipleMacs,
+		"Gi1/0/4": entities.SkipMultipleMacs,
+	}
+	if !reflect.DeepEqual(skipped, expectedSkips) {
+		t.Errorf("skipped = %v; expected %v", skipped, expectedSkips)
+	}
+
+	drv.vlans = []string{"1", "10"}
+	plan, err = NewVLANService(&mockRepository{}, cfg, drv).BuildPlan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range plan.Actions {
+		if a.Interface == "Gi1/0/1" {
+			t.Fatalf("port must be left alone when the voice VLAN is missing, got %+v", a)
+		}
+	}
+}
+
+func TestRollbackRunRestoresVoiceVlan(t *testing.T) {
+	drv := &stubDriver{
+		vlans: []string{"1", "10", "30"},
+		ports: []entities.Port{{Interface: "Gi1/0/1", Vlan: "10"}},
+		voice: map[string]string{"gi1/0/1": "30"},
+	}
+	entry := &entities.JournalEntry{
+		RunID:    "run-1",
+		Target:   "10.0.0.1",
+		Platform: "stub",
+		Actions: []entities.PlanAction{
+			{Kind: entities.ActionConfigureVoice, Interface: "Gi1/0/1", TargetVlan: "30"},
+		},
+	}
+	repo := &mockRepository{}
+	if _, err := NewVLANService(repo, entities.SwitchConfig{Target: "10.0.0.1"}, drv).RollbackRun(entry); err != nil {
+		t.Fatalf("RollbackRun failed: %v", err)
+	}
+	if expected := []string{"no voice vlan", "write memory"}; !reflect.DeepEqual(repo.executed, expected) {
+		t.Fatalf("executed = %v; expected %v", repo.executed, expected)
+	}
+}
+
+func TestBuildPlanBlockMacs(t *testing.T) {
+	drv := &stubDriver{
+		vlans:  []string{"1", "10", "999"},
+		trunks: []string{"Gi1/0/24"},
+		ports: []entities.Port{
+			{Interface: "Gi1/0/1", Vlan: "10"},
+			{Interface: "Gi1/0/2", Vlan: "10"},
+			{Interface: "Gi1/0/3", Vlan: "10"},
+			{Interface: "Gi1/0/4", Vlan: "10"},
+			{Interface: "Gi1/0/24", Vlan: "1"},
+		},
+		devices: []entities.Device{
+			{Mac: "001122000001", MacFull: "00:11:22:00:00:01", Interface: "Gi1/0/1"},
+			// port_rules exclude means never touched, even when blocked.
+			{Mac: "deadbe000005", MacFull: "de:ad:be:00:00:05", Interface: "Gi1/0/4"},
+			// Pinned by a port rule and excluded, but blocked anyway.
+			{Mac: "deadbe000002", MacFull: "de:ad:be:00:00:02", Interface: "Gi1/0/2"},
+			{Mac: "001122000003", MacFull: "00:11:22:00:00:03", Interface: "Gi1/0/3"},
+			{Mac: "deadbe000003", MacFull: "de:ad:be:00:00:03", Interface: "Gi1/0/3"},
+			{Mac: "deadbe000004", MacFull: "de:ad:be:00:00:04", Interface: "Gi1/0/24"},
+		},
+	}
+	cases := []struct {
+		action   string
+		expected []string
+	}{
+		{"", []string{
+			"configure_access Gi1/0/2 999 block_macs deadbe",
+			"configure_access Gi1/0/3 999 block_macs deadbe",
+		}},
+		{entities.BlockShutdown, []string{
+			"shutdown_port Gi1/0/2  block_macs deadbe",
+			"shutdown_port Gi1/0/3  block_macs deadbe",
+		}},
+	}
+	for _, tc := range cases {
+		cfg := entities.SwitchConfig{
+			Target:      "10.0.0.1",
+			DefaultVlan: "10",
+			NoDataVlan:  "999",
+			MinAge:      "1h",
+			ExcludeMacs: []string{"deadbe000002"},
+			PortRules: []entities.PortRule{
+				{Ports: []string{"Gi1/0/2"}, Vlan: "10"},
+				{Ports: []string{"Gi1/0/4"}, Exclude: true},
+			},
+			BlockMacs:   []string{"deadbe"},
+			BlockAction: tc.action,
+		}
+		plan, err := NewVLANService(&mockRepository{}, cfg, drv).BuildPlan()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, a := range plan.Actions {
+			got = append(got, strings.Join([]string{string(a.Kind), a.Interface, a.TargetVlan, a.Rule}, " "))
+		}
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("block_action %q: got %q; expected %q", tc.action, got, tc.expected)
+		}
+		if plan.ChangedPorts() != 2 {
+			t.Errorf("block_action %q: %d changed port(s); expected 2", tc.action, plan.ChangedPorts())
+		}
+	}
+}
+
+func TestRollbackRunEnablesShutdownPorts(t *testing.T) {
+	drv := &stubDriver{
+		vlans: []string{"1", "10"},
+		ports: []entities.Port{{Interface: "Gi1/0/2", Vlan: "10"}},
+	}
+	entry := &entities.JournalEntry{
+		RunID:    "run-1",
+		Target:   "10.0.0.1",
+		Platform: "stub",
+		Actions: []entities.PlanAction{
+			{Kind: entities.ActionShutdownPort, Interface: "Gi1/0/1", Mac: "de:ad:be:00:00:01"},
+			{Kind: entities.ActionShutdownPort, Interface: "Gi1/0/2", Mac: "de:ad:be:00:00:02"},
+		},
+	}
+	repo := &mockRepository{}
+	plan, err := NewVLANService(repo, entities.SwitchConfig{Target: "10.0.0.1"}, drv).RollbackRun(entry)
+	if err != nil {
+		t.Fatalf("RollbackRun failed: %v", err)
+	}
+	if len(plan.Actions) != 1 || plan.Actions[0].Kind != entities.ActionEnablePort {
+		t.Fatalf("expected one enable_port action, got %+v", plan.Actions)
+	}
+	if expected := []string{"no shutdown Gi1/0/1", "write memory"}; !reflect.DeepEqual(repo.executed, expected) {
+		t.Fatalf("executed = %v; expected %v", repo.executed, expected)
+	}
+}
+
+func TestBuildPlanMultiMacPolicy(t *testing.T) {
+	drv := &stubDriver{
+		vlans: []string{"1", "10", "20"},
+		ports: []entities.Port{
+			{Interface: "Gi1/0/1", Vlan: "1"},
+			{Interface: "Gi1/0/2", Vlan: "1"},
+			{Interface: "Gi1/0/3", Vlan: "1"},
+		},
+		devices: []entities.Device{
+			// Hypervisor: every guest maps to VLAN 10.
+			{Mac: "aabbcc000001", MacFull: "aa:bb:cc:00:00:01", Interface: "Gi1/0/1"},
+			{Mac: "aabbcc000002", MacFull: "aa:bb:cc:00:00:02", Interface: "Gi1/0/1"},
+			// Desk switch: two devices on VLAN 20, one unknown.
+			{Mac: "ffffff000001", MacFull: "ff:ff:ff:00:00:01", Interface: "Gi1/0/2"},
+			{Mac: "001122000001", MacFull: "00:11:22:00:00:01", Interface: "Gi1/0/2"},
+			{Mac: "001122000002", MacFull: "00:11:22:00:00:02", Interface: "Gi1/0/2"},
+			// Mixed pair with no majority.
+			{Mac: "ffffff000002", MacFull: "ff:ff:ff:00:00:02", Interface: "Gi1/0/3"},
+			{Mac: "aabbcc000003", MacFull: "aa:bb:cc:00:00:03", Interface: "Gi1/0/3"},
+		},
+	}
+	cases := []struct {
+		policy   string
+		expected map[string]string
+	}{
+		{"", map[string]string{}},
+		{entities.MultiMacSkip, map[string]string{}},
+		{entities.MultiMacSameVlanOnly, map[string]string{
+			"Gi1/0/1": "10 mac_to_vlan aabbcc (same-vlan-only, 2/2 MACs)",
+		}},
+		{entities.MultiMacMajority, map[string]string{
+			"Gi1/0/1": "10 mac_to_vlan aabbcc (majority, 2/2 MACs)",
+			"Gi1/0/2": "20 mac_to_vlan 001122 (majority, 2/3 MACs)",
+		}},
+		{entities.MultiMacFirstMatch, map[string]string{
+			"Gi1/0/1": "10 mac_to_vlan aabbcc (first-match, 2/2 MACs)",
+			"Gi1/0/2": "20 mac_to_vlan 001122 (first-match, 2/3 MACs)",
+			"Gi1/0/3": "10 mac_to_vlan aabbcc (first-match, 1/2 MACs)",
+		}},
+	}
+	for _, tc := range cases {
+		cfg := entities.SwitchConfig{
+			Target:         "10.0.0.1",
+			DefaultVlan:    "1",
+			MacToVlan:      map[string]string{"aabbcc": "10", "001122": "20"},
+			MultiMacPolicy: tc.policy,
+		}
+		plan, err := NewVLANService(&mockRepository{}, cfg, drv).BuildPlan()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]string{}
+		for _, a := range plan.Actions {
+			got[a.Interface] = a.TargetVlan + " " + a.Rule
+		}
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("policy %q: got %v; expected %v", tc.policy, got, tc.expected)
+		}
+		if len(plan.Skipped) != 3-len(tc.expected) {
+			t.Errorf("policy %q: skipped %v", tc.policy, plan.Skipped)
+		}
+	}
+
+	cfg := entities.SwitchConfig{Target: "10.0.0.1", DefaultVlan: "1", MultiMacPolicy: entities.MultiMacFirstMatch, ExcludeMacs: []string{"ffffff000002"}}
+	plan, err := NewVLANService(&mockRepository{}, cfg, drv).BuildPlan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range plan.Actions {
+		if a.Interface == "Gi1/0/3" {
+			t.Fatalf("port with an excluded MAC must be skipped, got %+v", a)
+		}
+	}
+}
+
+func TestBuildPlanRandomMacPolicy(t *testing.T) {
+	drv := &stubDriver{
+		vlans: []string{"1", "10", "20", "999"},
+		ports: []entities.Port{
+			{Interface: "Gi1/0/1", Vlan: "10"},
+			{Interface: "Gi1/0/2", Vlan: "1"},
+			{Interface: "Gi1/0/3", Vlan: "20"},
+			{Interface: "Gi1/0/4", Vlan: "10"},
+		},
+		devices: []entities.Device{
+			{Mac: "001122000001", MacFull: "00:11:22:00:00:01", Interface: "Gi1/0/1"},
+			// Randomized, but pinned by a full-MAC mac_to_vlan key.
+			{Mac: "da0000000001", MacFull: "da:00:00:00:00:01", Interface: "Gi1/0/2"},
+			{Mac: "da0000000002", MacFull: "da:00:00:00:00:02", Interface: "Gi1/0/3"},
+			{Mac: "001122000004", MacFull: "00:11:22:00:00:04", Interface: "Gi1/0/4"},
+			{Mac: "6a0000000004", MacFull: "6a:00:00:00:00:04", Interface: "Gi1/0/4"},
+		},
+	}
+	cases := []struct {
+		policy   string
+		expected map[string]string
+		skipped  []entities.SkippedPort
+	}{
+		{"", map[string]string{
+			"Gi1/0/1": "20 mac_to_vlan 001122",
+			"Gi1/0/2": "10 mac_to_vlan da0000000001",
+			"Gi1/0/3": "1 default_vlan",
+			"Gi1/0/4": "20 mac_to_vlan 001122 (first-match, 1/2 MACs)",
+		}, nil},
+		{entities.RandomMacSkip, map[string]string{
+			"Gi1/0/1": "20 mac_to_vlan 001122",
+			"Gi1/0/2": "10 mac_to_vlan da0000000001",
+		}, []entities.SkippedPort{{Interface: "Gi1/0/3", Reason: entities.SkipRandomMac}, {Interface: "Gi1/0/4", Reason: entities.SkipRandomMac}}},
+		{entities.RandomMacQuarantine, map[string]string{
+			"Gi1/0/1": "20 mac_to_vlan 001122",
+			"Gi1/0/2": "10 mac_to_vlan da0000000001",
+			"Gi1/0/3": "999 random_mac_policy quarantine",
+			"Gi1/0/4": "20 mac_to_vlan 001122 (first-match, 1/2 MACs)",
+		}, nil},
+	}
+	for _, tc := range cases {
+		cfg := entities.SwitchConfig{
+			Target:          "10.0.0.1",
+			DefaultVlan:     "1",
+			NoDataVlan:      "999",
+			MacToVlan:       map[string]string{"001122": "20", "da0000000001": "10"},
+			MultiMacPolicy:  entities.MultiMacFirstMatch,
+			RandomMacPolicy: tc.policy,
+		}
+		plan, err := NewVLANService(&mockRepository{}, cfg, drv).BuildPlan()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]string{}
+		for _, a := range plan.Actions {
+			got[a.Interface] = a.TargetVlan + " " + a.Rule
+		}
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("policy %q: got %v; expected %v", tc.policy, got, tc.expected)
+		}
+		if !reflect.DeepEqual(plan.Skipped, tc.skipped) {
+			t.Errorf("policy %q: skipped %v; expected %v", tc.policy, plan.Skipped, tc.skipped)
+		}
+		if plan.RandomMacPorts != 2 {
+			t.Errorf("policy %q: %d randomized MAC port(s); expected 2", tc.policy, plan.RandomMacPorts)
+		}
+	}
+}
+
+func TestBuildPlanAlertsOnTooManyMacs(t *testing.T) {
+	drv := &stubDriver{
+		vlans: []string{"1"},
+		ports: []entities.Port{{Interface: "Gi1/0/1", Vlan: "1"}, {Interface: "Gi1/0/2", Vlan: "1"}},
+		devices: []entities.Device{
+			{Mac: "aabbcc000001", Interface: "Gi1/0/1"},
+			{Mac: "aabbcc000002", Interface: "Gi1/0/1"},
+			{Mac: "aabbcc000003", Interface: "Gi1/0/1"},
+			{Mac: "aabbcc000004", Interface: "Gi1/0/2"},
+		},
+	}
+	cfg := entities.SwitchConfig{Target: "10.0.0.1", DefaultVlan: "1", MaxPortMacs: 2}
+	plan, err := NewVLANService(&mockRepository{}, cfg, drv).BuildPlan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []entities.PortAlert{{Interface: "Gi1/0/1", Macs: 3, Limit: 2}}
+	if !reflect.DeepEqual(plan.Alerts, expected) {
+		t.Fatalf("alerts = %+v; expected %+v", plan.Alerts, expected)
+	}
+}
+
+func TestBuildPlanPortRules(t *testing.T) {
+	drv := &stubDriver{
+		vlans: []string{"1", "10", "50", "60"},
+		ports: []entities.Port{
+			{Interface: "Gi1/0/39", Vlan: "1"},
+			{Interface: "Gi1/0/40", Vlan: "1"},
+			{Interface: "Gi1/0/48", Vlan: "1"},
+			{Interface: "Te1/0/1", Vlan: "1"},
+			{Interface: "Gi1/0/5", Vlan: "1"},
+			{Interface: "Gi1/0/6", Vlan: "1"},
+		},
+		devices: []entities.Device{
+			{Mac: "aabbcc000001", Interface: "Gi1/0/39"},
+			{Mac: "aabbcc000002", Interface: "Gi1/0/40"},
+			{Mac: "aabbcc000003", Interface: "Gi1/0/40"},
+			{Mac: "aabbcc000004", Interface: "Te1/0/1"},
+			{Mac: "aabbcc000005", Interface: "Gi1/0/5"},
+			{Mac: "aabbcc000006", Interface: "Gi1/0/6"},
+		},
+	}
+	cfg := entities.SwitchConfig{
+		Target:       "10.0.0.1",
+		DefaultVlan:  "1",
+		MacToVlan:    map[string]string{"aabbcc": "10"},
+		ExcludePorts: []string{"gi1/0/5-6"},
+		PortRules: []entities.PortRule{
+			{Ports: []string{"gi1/0/48"}, Vlan: "60"},
+			{Ports: []string{"gi1/0/40-48"}, Vlan: "50"},
+			{Ports: []string{"re:^te"}, Exclude: true},
+		},
+	}
+	plan, err := NewVLANService(&mockRepository{}, cfg, drv).BuildPlan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, a := range plan.Actions {
+		got[a.Interface] = a.TargetVlan + " " + a.Rule
+	}
+	expected := map[string]string{
+		"Gi1/0/39": "10 mac_to_vlan aabbcc",
+		"Gi1/0/40": "50 port_rules gi1/0/40-48",
+		"Gi1/0/48": "60 port_rules gi1/0/48",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("actions = %v; expected %v", got, expected)
+	}
+	expectedSkipped := []entities.SkippedPort{
+		{Interface: "Te1/0/1", Reason: entities.SkipExcluded},
+		{Interface: "Gi1/0/5", Reason: entities.SkipExcluded},
+		{Interface: "Gi1/0/6", Reason: entities.SkipExcluded},
+	}
+	if !reflect.DeepEqual(plan.Skipped, expectedSkipped) {
+		t.Errorf("skipped = %+v; expected %+v", plan.Skipped, expectedSkipped)
+	}
+
+	cfg.PortRules = []entities.PortRule{{Ports: []string{"gi1/0/39"}, Vlan: "70"}}
+	plan, err = NewVLANService(&mockRepository{}, cfg, drv).BuildPlan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Skipped[0] != (entities.SkippedPort{Interface: "Gi1/0/39", Reason: entities.SkipMissingVLAN}) {
+		t.Errorf("expected missing VLAN skip, got %+v", plan.Skipped)
+	}
+}
+
+func TestBuildPlanDebouncesNewMacs(t *testing.T) {
+	drv := &stubDriver{
+		vlans:   []string{"1", "10"},
+		ports:   []entities.Port{{Interface: "Gi1/0/1", Vlan: "1"}},
+		devices: []entities.Device{{Mac: "aabbcc000001", Interface: "Gi1/0/1"}},
+	}
+	cfg := entities.SwitchConfig{
+		Target:          "10.0.0.1",
+		DefaultVlan:     "1",
+		MacToVlan:       map[string]string{"aabbcc": "10"},
+		MinObservations: 3,
+		MinAge:          "30m",
+	}
+	history := &memoryPortHistory{}
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	run := func(after time.Duration) *entities.Plan {
+		t.Helper()
+		svc := NewVLANService(&mockRepository{}, cfg, drv)
+		svc.SetPortHistory(history)
+		svc.now = func() time.Time { return start.Add(after) }
+		plan, err := svc.BuildPlan()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return plan
+	}
+	pending := []entities.SkippedPort{{Interface: "Gi1/0/1", Reason: entities.SkipPending}}
+
+	for _, after := range []time.Duration{0, 10 * time.Minute, 20 * time.Minute} {
+		if plan := run(after); plan.HasChanges() || !reflect.DeepEqual(plan.Skipped, pending) {
+			t.Fatalf("after %v: expected the port to wait, got %+v %+v", after, plan.Actions, plan.Skipped)
+		}
+	}
+	plan := run(40 * time.Minute)
+	if len(plan.Actions) != 1 || plan.Actions[0].TargetVlan != "10" {
+		t.Fatalf("expected the port moved once the MAC settled, got %+v %+v", plan.Actions, plan.Skipped)
+	}
+
+	// A run without the MAC breaks the streak, but its first sighting is kept.
+	devices := drv.devices
+	drv.devices = nil
+	run(50 * time.Minute)
+	drv.devices = devices
+	if plan := run(60 * time.Minute); plan.HasChanges() {
+		t.Fatalf("expected the streak to start over, got %+v", plan.Actions)
+	}
+	record := history.saved.Ports["gi1/0/1"].Macs["aabbcc000001"]
+	if record.Observations != 1 || !record.Since.Equal(start.Add(60*time.Minute)) || !record.FirstSeen.Equal(start) {
+		t.Fatalf("unexpected MAC record: %+v", record)
+	}
+
+	// Ports already on the VLAN of a new MAC are not reported.
+	drv.ports[0].Vlan = "10"
+	if plan := run(70 * time.Minute); len(plan.Skipped) != 0 {
+		t.Fatalf("expected nothing pending, got %+v", plan.Skipped)
+	}
+}
+
+func TestSandboxRunsDoNotAdvanceObservations(t *testing.T) {
+	drv := &stubDriver{
+		vlans:   []string{"1", "10"},
+		ports:   []entities.Port{{Interface: "Gi1/0/1", Vlan: "1"}},
+		devices: []entities.Device{{Mac: "aabbcc000001", Interface: "Gi1/0/1"}},
+	}
+	cfg := entities.SwitchConfig{
+		Target:          "10.0.0.1",
+		DefaultVlan:     "1",
+		MacToVlan:       map[string]string{"aabbcc": "10"},
+		MinObservations: 2,
+		Sandbox:         true,
+	}
+	history := &memoryPortHistory{}
+	for i := 0; i < 3; i++ {
+		svc := NewVLANService(&mockRepository{}, cfg, drv)
+		svc.SetPortHistory(history)
+		plan, err := svc.BuildPlan()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plan.HasChanges() {
+			t.Fatalf("sandbox run %d: expected the port to wait, got %+v", i, plan.Actions)
+		}
+	}
+	if history.saved != nil {
+		t.Fatalf("sandbox runs must not record observations, got %+v", history.saved)
+	}
+
+	cfg.Sandbox = false
+	newService := func() *VLANServiceImpl {
+		svc := NewVLANService(&mockRepository{}, cfg, drv)
+		svc.SetPortHistory(history)
+		return svc
+	}
+	plan, err := newService().BuildPlan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record := history.saved.Ports["gi1/0/1"].Macs["aabbcc000001"]; record.Observations != 1 {
+		t.Fatalf("expected one observation after a write run, got %+v", record)
+	}
+	if err := newService().ApplySavedPlan(plan); err != nil {
+		t.Fatal(err)
+	}
+	if record := history.saved.Ports["gi1/0/1"].Macs["aabbcc000001"]; record.Observations != 2 {
+		t.Fatalf("expected applying a saved plan to count as an observation, got %+v", record)
+	}
+}
@@ -2,28 +2,90 @@ package config
 
 import (
 	"fmt"
+	"maps"
 	"os"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Platform       string                  `yaml:"platform"`
-	LegacyVendor   string                  `yaml:"vendor"`
-	Transport      string                  `yaml:"transport"`
-	Username       string                  `yaml:"username"`
-	Password       string                  `yaml:"password"`
-	EnablePassword string                  `yaml:"enable_password"`
-	DefaultVlan    string                  `yaml:"default_vlan"`
-	NoDataVlan     string                  `yaml:"no_data_vlan"`
-	ExcludeMacs    []string                `yaml:"exclude_macs"`
-	MacToVlan      map[string]string       `yaml:"mac_to_vlan"`
-	AllowedVlans   []string                `yaml:"allowed_vlans"`
-	ProtectedVlans []string                `yaml:"protected_vlans"`
-	Switches       []entities.SwitchConfig `yaml:"switches"`
+	Platform         string                  `yaml:"platform"`
+	LegacyVendor     string                  `yaml:"vendor"`
+	Transport        string                  `yaml:"transport"`
+	Username         string                  `yaml:"username"`
+	Password         string                  `yaml:"password"`
+	EnablePassword   string                  `yaml:"enable_password"`
+	DefaultVlan      string                  `yaml:"default_vlan"`
+	NoDataVlan       string                  `yaml:"no_data_vlan"`
+	NoDataAfter      string                  `yaml:"no_data_after"`
+	VoiceVlan        string                  `yaml:"voice_vlan"`
+	VoiceMacs        []string                `yaml:"voice_macs"`
+	MultiMacPolicy   string                  `yaml:"multi_mac_policy"`
+	RandomMacPolicy  string                  `yaml:"random_mac_policy"`
+	MaxPortMacs      int                     `yaml:"max_port_macs"`
+	MinObservations  int                     `yaml:"min_observations"`
+	MinAge           string                  `yaml:"min_age"`
+	MaxChanges       int                     `yaml:"max_changes"`
+	MaxVlanDeletions int                     `yaml:"max_vlan_deletions"`
+	PortDescription  string                  `yaml:"port_description"`
+	MaxFleetChanges  int                     `yaml:"max_fleet_changes"`
+	PortRules        []entities.PortRule     `yaml:"port_rules"`
+	ChangeWindows    []entities.ChangeWindow `yaml:"change_windows"`
+	ExcludeMacs      []string                `yaml:"exclude_macs"`
+	BlockMacs        []string                `yaml:"block_macs"`
+	BlockAction      string                  `yaml:"block_action"`
+	MacToVlan        map[string]string       `yaml:"mac_to_vlan"`
+	VendorToVlan     map[string]string       `yaml:"vendor_to_vlan"`
+	AllowedVlans     []entities.Vlan         `yaml:"allowed_vlans"`
+	ProtectedVlans   []string                `yaml:"protected_vlans"`
+	ProtectedRanges  []string                `yaml:"protected_ranges"`
+	StateDir         string                  `yaml:"state_dir"`
+	OuiFile          string                  `yaml:"oui_file"`
+	Groups           map[string]GroupConfig  `yaml:"groups"`
+	Switches         []entities.SwitchConfig `yaml:"switches"`
+}
+
+// GroupConfig holds defaults shared by every switch that sets `group`. They
+// are merged between the global block and the switch block.
+type GroupConfig struct {
+	DefaultVlan      string                  `yaml:"default_vlan"`
+	NoDataVlan       string                  `yaml:"no_data_vlan"`
+	NoDataAfter      string                  `yaml:"no_data_after"`
+	VoiceVlan        string                  `yaml:"voice_vlan"`
+	VoiceMacs        []string                `yaml:"voice_macs"`
+	MultiMacPolicy   string                  `yaml:"multi_mac_policy"`
+	RandomMacPolicy  string                  `yaml:"random_mac_policy"`
+	MaxPortMacs      int                     `yaml:"max_port_macs"`
+	MinObservations  int                     `yaml:"min_observations"`
+	MinAge           string                  `yaml:"min_age"`
+	MaxChanges       int                     `yaml:"max_changes"`
+	MaxVlanDeletions int                     `yaml:"max_vlan_deletions"`
+	PortDescription  string                  `yaml:"port_description"`
+	PortRules        []entities.PortRule     `yaml:"port_rules"`
+	ChangeWindows    []entities.ChangeWindow `yaml:"change_windows"`
+	ExcludeMacs      []string                `yaml:"exclude_macs"`
+	BlockMacs        []string                `yaml:"block_macs"`
+	BlockAction      string                  `yaml:"block_action"`
+	MacToVlan        map[string]string       `yaml:"mac_to_vlan"`
+	VendorToVlan     map[string]string       `yaml:"vendor_to_vlan"`
+	AllowedVlans     []entities.Vlan         `yaml:"allowed_vlans"`
+	ProtectedVlans   []string                `yaml:"protected_vlans"`
+	ProtectedRanges  []string                `yaml:"protected_ranges"`
+	Tags             map[string]string       `yaml:"tags"`
+}
+
+func (c *Config) Targets() []string {
+	targets := make([]string, 0, len(c.Switches))
+	for _, sw := range c.Switches {
+		targets = append(targets, sw.Target)
+	}
+	return targets
 }
 
 func debugf(verbose bool, format string, args ...any) {
@@ -32,9 +94,12 @@ func debugf(verbose bool, format string, args ...any) {
 	}
 }
 
-func mergeStringSlices(global, local []string, validate func(string) error) ([]string, error) {
+// mergeStringSlices appends local to global without duplicates. Local values
+// failing validate are left out and reported.
+func mergeStringSlices(global, local []string, validate func(string) error) ([]string, []error) {
 	seen := make(map[string]bool)
 	var result []string
+	var errs []error
 	for _, v := range global {
 		if !seen[v] {
 			seen[v] = true
@@ -44,7 +109,8 @@ func mergeStringSlices(global, local []string, validate func(string) error) ([]s
 	for _, v := range local {
 		if validate != nil {
 			if err := validate(v); err != nil {
-				return nil, err
+				errs = append(errs, err)
+				continue
 			}
 		}
 		if !seen[v] {
@@ -52,13 +118,205 @@ func mergeStringSlices(global, local []string, validate func(string) error) ([]s
 			result = append(result, v)
 		}
 	}
-	return result, nil
+	return result, errs
+}
+
+// mergeVlans adds the local allowed_vlans entries to the inherited ones. An
+// ID listed again keeps its place; a name given for it replaces the
+// inherited name.
+func mergeVlans(inherited, local []entities.Vlan, context string, validateVLAN func(string, string) error) ([]entities.Vlan, []error) {
+	result := append([]entities.Vlan(nil), inherited...)
+	var errs []error
+	for _, v := range local {
+		v.Name = strings.TrimSpace(v.Name)
+		if err := validateVLAN(v.ID, context+" allowed_vlans"); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := entities.ValidateVlanName(v.Name); err != nil {
+			errs = append(errs, fmt.Errorf("%s allowed_vlans %s: %v", context, v.ID, err))
+			continue
+		}
+		i := slices.IndexFunc(result, func(r entities.Vlan) bool { return r.ID == v.ID })
+		if i < 0 {
+			result = append(result, v)
+		} else if v.Name != "" {
+			result[i].Name = v.Name
+		}
+	}
+	return result, errs
+}
+
+func overlayMacToVlan(merged, overlay map[string]string, context string, validateVLAN func(string, string) error) []error {
+	var errs []error
+	for _, prefix := range sortedMapKeys(overlay) {
+		vlan := overlay[prefix]
+		if vlan == "0" || vlan == "00" || vlan == "" {
+			removeMacPrefix(merged, prefix)
+			continue
+		}
+		norm, err := NormalizeMacPrefix(prefix)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s mac_to_vlan prefix %s is invalid: %v", context, prefix, err))
+			continue
+		}
+		if err := validateVLAN(vlan, fmt.Sprintf("%s mac_to_vlan prefix %s", context, prefix)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		merged[norm] = vlan
+	}
+	return errs
+}
+
+// removeMacPrefix drops the inherited keys inside the block of a removal
+// entry, so removing an OUI also removes the devices and MA-M/MA-S blocks of
+// it. A removal that is not a valid prefix, such as "00", removes nothing.
+func removeMacPrefix(merged map[string]string, prefix string) {
+	norm, err := NormalizeMacPrefix(prefix)
+	if err != nil {
+		return
+	}
+	block, _ := entities.ParseMacPrefix(norm)
+	for key := range merged {
+		if p, err := entities.ParseMacPrefix(key); err == nil && block.Contains(p) {
+			delete(merged, key)
+		}
+	}
+}
+
+// overlayVendorToVlan is overlayMacToVlan for vendor_to_vlan, whose keys are
+// vendor names or "re:" regular expressions.
+func overlayVendorToVlan(merged, overlay map[string]string, context string, validateVLAN func(string, string) error) []error {
+	var errs []error
+	for _, key := range sortedMapKeys(overlay) {
+		vlan := overlay[key]
+		vendor := strings.TrimSpace(key)
+		if _, err := entities.ParseVendorPattern(vendor); err != nil {
+			errs = append(errs, fmt.Errorf("%s vendor_to_vlan %q is invalid: %v", context, key, err))
+			continue
+		}
+		if vlan == "0" || vlan == "00" || vlan == "" {
+			delete(merged, vendor)
+			continue
+		}
+		if err := validateVLAN(vlan, fmt.Sprintf("%s vendor_to_vlan %s", context, vendor)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		merged[vendor] = vlan
+	}
+	return errs
+}
+
+// normalizeMacPrefixes adds the prefixes of a layer's list, such as
+// voice_macs, in canonical form to the ones inherited.
+func normalizeMacPrefixes(inherited, local []string, context, key string) ([]string, []error) {
+	var normalized []string
+	var errs []error
+	for _, prefix := range local {
+		norm, err := NormalizeMacPrefix(prefix)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s prefix %s is invalid: %v", context, key, prefix, err))
+			continue
+		}
+		normalized = append(normalized, norm)
+	}
+	merged, _ := mergeStringSlices(inherited, normalized, nil)
+	return merged, errs
+}
+
+func normalizePortPattern(pattern string) string {
+	pattern = strings.TrimSpace(pattern)
+	if strings.HasPrefix(pattern, "re:") {
+		return pattern
+	}
+	return strings.ToLower(pattern)
+}
+
+// normalizePortPatterns normalizes and deduplicates a list of port patterns,
+// reporting the invalid ones.
+func normalizePortPatterns(patterns []string, context string, report func(error) bool) []string {
+	seen := make(map[string]bool)
+	var normalized []string
+	for _, p := range patterns {
+		pNorm := normalizePortPattern(p)
+		if pNorm == "" || seen[pNorm] {
+			continue
+		}
+		if _, err := entities.ParsePortPattern(pNorm); err != nil {
+			report(fmt.Errorf("%s entry %s is invalid: %v", context, p, err))
+			continue
+		}
+		seen[pNorm] = true
+		normalized = append(normalized, pNorm)
+	}
+	return normalized
+}
+
+func normalizePortRule(rule entities.PortRule, context string, validateVLAN func(string, string) error, report func(error) bool) (entities.PortRule, bool) {
+	ok := true
+	if len(rule.Ports) == 0 {
+		report(fmt.Errorf("%s has no ports", context))
+		ok = false
+	}
+	if rule.Exclude == (rule.Vlan != "") {
+		report(fmt.Errorf("%s must set either vlan or exclude", context))
+		ok = false
+	} else if rule.Vlan != "" && report(validateVLAN(rule.Vlan, context)) {
+		ok = false
+	}
+	patterns := make([]string, 0, len(rule.Ports))
+	for _, p := range rule.Ports {
+		norm := normalizePortPattern(p)
+		if _, err := entities.ParsePortPattern(norm); err != nil {
+			report(fmt.Errorf("%s port %s is invalid: %v", context, p, err))
+			ok = false
+			continue
+		}
+		patterns = append(patterns, norm)
+	}
+	rule.Ports = patterns
+	return rule, ok
+}
+
+func sortedMapKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// mergeTags normalizes tag keys to lowercase; switch tags win over group tags.
+func mergeTags(group, local map[string]string) map[string]string {
+	if len(group) == 0 && len(local) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(group)+len(local))
+	for _, tags := range []map[string]string{group, local} {
+		for k, v := range tags {
+			result[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+		}
+	}
+	return result
 }
 
 func NormalizeMAC(mac string) string {
 	return strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(mac, ":", ""), ".", ""))
 }
 
+// NormalizeMacPrefix turns a mac_to_vlan key as written into its canonical
+// form, so that "AA:BB:CC:D" and "aabbccd0/28" end up as the same key.
+func NormalizeMacPrefix(key string) (string, error) {
+	p, err := entities.ParseMacPrefix(NormalizeMAC(strings.TrimSpace(key)))
+	if err != nil {
+		return "", err
+	}
+	return p.String(), nil
+}
+
 func validatePlatform(platform string) error {
 	switch platform {
 	case "ios", "dmos", "auto":
@@ -77,6 +335,27 @@ func Load(yamlFile, target string, sandbox bool, verbosityLevel int, createVLANs
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse YAML: %v", err)
 	}
+	if errs := cfg.resolve(target, verbosityLevel); len(errs) > 0 {
+		return nil, errs[0]
+	}
+	if cfg.StateDir == "" {
+		cfg.StateDir = DefaultStateDir()
+	}
+	return &cfg, nil
+}
+
+// resolve validates the configuration and merges the global, group and switch
+// settings into every switch. It keeps going after a problem so that every
+// one of them is reported; invalid values are left out of the merge.
+func (cfg *Config) resolve(target string, verbosityLevel int) []error {
+	var errs []error
+	report := func(err error) bool {
+		if err != nil {
+			errs = append(errs, err)
+			return true
+		}
+		return false
+	}
 
 	verbose := verbosityLevel == 1 || verbosityLevel == 3
 
@@ -91,7 +370,7 @@ func Load(yamlFile, target string, sandbox bool, verbosityLevel int, createVLANs
 	}
 	cfg.Transport = strings.ToLower(cfg.Transport)
 	if cfg.Transport != "telnet" && cfg.Transport != "ssh" {
-		return nil, fmt.Errorf("transport %s is invalid, must be 'telnet' or 'ssh'", cfg.Transport)
+		report(fmt.Errorf("transport %s is invalid, must be 'telnet' or 'ssh'", cfg.Transport))
 	}
 
 	validateVLAN := func(vlan string, context string) error {
@@ -105,31 +384,134 @@ func Load(yamlFile, target string, sandbox bool, verbosityLevel int, createVLANs
 		return nil
 	}
 
-	if cfg.DefaultVlan == "" {
-		return nil, fmt.Errorf("global default_vlan is required")
+	validateVLANRange := func(vlans string, context string) error {
+		if _, _, err := entities.ParseVlanRange(vlans); err != nil {
+			return fmt.Errorf("%v in %s", err, context)
+		}
+		return nil
 	}
-	if err := validateVLAN(cfg.DefaultVlan, "global default_vlan"); err != nil {
-		return nil, err
+	validateProtectedRanges := func(ranges []string, context string) {
+		for _, r := range ranges {
+			report(validateVLANRange(r, context+" protected_ranges"))
+		}
+	}
+	validateProtectedRanges(cfg.ProtectedRanges, "global")
+
+	validatePeriod := func(period string, context string) error {
+		d, err := time.ParseDuration(period)
+		if err != nil {
+			return fmt.Errorf("invalid duration in %s: %s", context, period)
+		}
+		if d < 0 {
+			return fmt.Errorf("%s must not be negative", context)
+		}
+		return nil
+	}
+
+	if cfg.DefaultVlan == "" {
+		report(fmt.Errorf("global default_vlan is required"))
+	} else {
+		report(validateVLAN(cfg.DefaultVlan, "global default_vlan"))
 	}
 	if cfg.NoDataVlan == "" {
-		return nil, fmt.Errorf("global no_data_vlan is required")
+		report(fmt.Errorf("global no_data_vlan is required"))
+	} else {
+		report(validateVLAN(cfg.NoDataVlan, "global no_data_vlan"))
+	}
+	if cfg.NoDataAfter != "" {
+		report(validatePeriod(cfg.NoDataAfter, "global no_data_after"))
 	}
-	if err := validateVLAN(cfg.NoDataVlan, "global no_data_vlan"); err != nil {
-		return nil, err
+	if cfg.VoiceVlan != "" {
+		report(validateVLAN(cfg.VoiceVlan, "global voice_vlan"))
+	}
+	globalVoiceMacs, voiceErrs := normalizeMacPrefixes(nil, cfg.VoiceMacs, "global", "voice_macs")
+	errs = append(errs, voiceErrs...)
+	globalBlockMacs, blockErrs := normalizeMacPrefixes(nil, cfg.BlockMacs, "global", "block_macs")
+	errs = append(errs, blockErrs...)
+	validateBlockAction := func(action, context string) {
+		if action != "" && !slices.Contains(entities.BlockActions, action) {
+			report(fmt.Errorf("%s block_action %s is invalid, must be one of %s", context, action, strings.Join(entities.BlockActions, ", ")))
+		}
+	}
+	validateBlockAction(cfg.BlockAction, "global")
+	validateMultiMac := func(policy string, maxMacs int, context string) {
+		if policy != "" && !slices.Contains(entities.MultiMacPolicies, policy) {
+			report(fmt.Errorf("%s multi_mac_policy %s is invalid, must be one of %s", context, policy, strings.Join(entities.MultiMacPolicies, ", ")))
+		}
+		if maxMacs < 0 {
+			report(fmt.Errorf("%s max_port_macs must not be negative", context))
+		}
 	}
+	validateMultiMac(cfg.MultiMacPolicy, cfg.MaxPortMacs, "global")
+	validateRandomMac := func(policy, context string) {
+		if policy != "" && !slices.Contains(entities.RandomMacPolicies, policy) {
+			report(fmt.Errorf("%s random_mac_policy %s is invalid, must be one of %s", context, policy, strings.Join(entities.RandomMacPolicies, ", ")))
+		}
+	}
+	validateRandomMac(cfg.RandomMacPolicy, "global")
+	validateLimits := func(maxChanges, maxDeletions int, context string) {
+		if maxChanges < 0 {
+			report(fmt.Errorf("%s max_changes must not be negative", context))
+		}
+		if maxDeletions < 0 {
+			report(fmt.Errorf("%s max_vlan_deletions must not be negative", context))
+		}
+	}
+	validateLimits(cfg.MaxChanges, cfg.MaxVlanDeletions, "global")
+	validateDebounce := func(minObservations int, minAge string, context string) {
+		if minObservations < 0 {
+			report(fmt.Errorf("%s min_observations must not be negative", context))
+		}
+		if minAge != "" {
+			report(validatePeriod(minAge, context+" min_age"))
+		}
+	}
+	validateDebounce(cfg.MinObservations, cfg.MinAge, "global")
+	if cfg.MaxFleetChanges < 0 {
+		report(fmt.Errorf("global max_fleet_changes must not be negative"))
+	}
+	validateWindows := func(windows []entities.ChangeWindow, context string) {
+		for i, w := range windows {
+			if err := w.Validate(); err != nil {
+				report(fmt.Errorf("%s change_windows[%d] is invalid: %v", context, i, err))
+			}
+		}
+	}
+	validateWindows(cfg.ChangeWindows, "global")
+	validateDescription := func(template, context string) {
+		if template == "" {
+			return
+		}
+		if err := entities.ValidatePortDescription(template); err != nil {
+			report(fmt.Errorf("%s port_description %s is invalid: %v", context, template, err))
+		}
+	}
+	validateDescription(cfg.PortDescription, "global")
 	if cfg.Username == "" {
-		return nil, fmt.Errorf("global username is required")
+		report(fmt.Errorf("global username is required"))
 	}
 	if cfg.Password == "" {
-		return nil, fmt.Errorf("global password is required")
+		report(fmt.Errorf("global password is required"))
 	}
 	if cfg.EnablePassword == "" {
-		return nil, fmt.Errorf("global enable_password is required")
+		report(fmt.Errorf("global enable_password is required"))
 	}
 
 	debugf(verbose, "DEBUG: Global values: Platform=%s, Transport=%s, DefaultVlan=%s, NoDataVlan=%s\n",
 		cfg.Platform, cfg.Transport, cfg.DefaultVlan, cfg.NoDataVlan)
 
+	globalAllowed, allowedErrs := mergeVlans(nil, cfg.AllowedVlans, "global", validateVLAN)
+	errs = append(errs, allowedErrs...)
+	globalProtected, protectedErrs := mergeStringSlices(nil, cfg.ProtectedVlans, func(v string) error {
+		return validateVLANRange(v, "global protected_vlans")
+	})
+	errs = append(errs, protectedErrs...)
+
+	globalMacToVlan := make(map[string]string)
+	errs = append(errs, overlayMacToVlan(globalMacToVlan, cfg.MacToVlan, "global", validateVLAN)...)
+	globalVendorToVlan := make(map[string]string)
+	errs = append(errs, overlayVendorToVlan(globalVendorToVlan, cfg.VendorToVlan, "global", validateVLAN)...)
+
 	for i := range cfg.Switches {
 		sw := &cfg.Switches[i]
 		swVerbose := verbose
@@ -137,14 +519,15 @@ func Load(yamlFile, target string, sandbox bool, verbosityLevel int, createVLANs
 			swVerbose = false
 		}
 		if sw.Target == "" {
-			return nil, fmt.Errorf("target is required for switch %d", i)
+			report(fmt.Errorf("target is required for switch %d", i))
+			continue
 		}
 		sw.Transport = strings.ToLower(strings.TrimSpace(sw.Transport))
 		if sw.Transport == "" {
 			sw.Transport = cfg.Transport
 		}
 		if sw.Transport != "telnet" && sw.Transport != "ssh" {
-			return nil, fmt.Errorf("transport %s is invalid for switch %s", sw.Transport, sw.Target)
+			report(fmt.Errorf("transport %s is invalid for switch %s", sw.Transport, sw.Target))
 		}
 
 		rawPlatform := sw.Platform
@@ -156,10 +539,9 @@ func Load(yamlFile, target string, sandbox bool, verbosityLevel int, createVLANs
 			sw.Platform = cfg.Platform
 		}
 		if sw.Platform == "" {
-			return nil, fmt.Errorf("platform is required for switch %s", sw.Target)
-		}
-		if err := validatePlatform(sw.Platform); err != nil {
-			return nil, fmt.Errorf("invalid platform for switch %s: %w", sw.Target, err)
+			report(fmt.Errorf("platform is required for switch %s", sw.Target))
+		} else if err := validatePlatform(sw.Platform); err != nil {
+			report(fmt.Errorf("invalid platform for switch %s: %w", sw.Target, err))
 		}
 
 		if sw.Username == "" {
@@ -172,101 +554,214 @@ func Load(yamlFile, target string, sandbox bool, verbosityLevel int, createVLANs
 			sw.EnablePassword = cfg.EnablePassword
 		}
 
+		var group GroupConfig
+		if sw.Group != "" {
+			g, ok := cfg.Groups[sw.Group]
+			if !ok {
+				report(fmt.Errorf("switch %s references unknown group %s", sw.Target, sw.Group))
+			}
+			group = g
+		}
+		groupCtx := fmt.Sprintf("group %s", sw.Group)
+
 		if sw.DefaultVlan == "" {
-			sw.DefaultVlan = cfg.DefaultVlan
-		} else {
-			if err := validateVLAN(sw.DefaultVlan, fmt.Sprintf("switch %s default_vlan", sw.Target)); err != nil {
-				return nil, err
+			sw.DefaultVlan = group.DefaultVlan
+			if sw.DefaultVlan == "" {
+				sw.DefaultVlan = cfg.DefaultVlan
+			} else {
+				report(validateVLAN(sw.DefaultVlan, groupCtx+" default_vlan"))
 			}
+		} else {
+			report(validateVLAN(sw.DefaultVlan, fmt.Sprintf("switch %s default_vlan", sw.Target)))
 		}
 
 		if sw.NoDataVlan == "" {
-			sw.NoDataVlan = cfg.NoDataVlan
+			sw.NoDataVlan = group.NoDataVlan
+			if sw.NoDataVlan == "" {
+				sw.NoDataVlan = cfg.NoDataVlan
+			} else {
+				report(validateVLAN(sw.NoDataVlan, groupCtx+" no_data_vlan"))
+			}
 		} else {
-			if err := validateVLAN(sw.NoDataVlan, fmt.Sprintf("switch %s no_data_vlan", sw.Target)); err != nil {
-				return nil, err
+			report(validateVLAN(sw.NoDataVlan, fmt.Sprintf("switch %s no_data_vlan", sw.Target)))
+		}
+
+		if sw.NoDataAfter == "" {
+			sw.NoDataAfter = group.NoDataAfter
+			if sw.NoDataAfter == "" {
+				sw.NoDataAfter = cfg.NoDataAfter
+			} else {
+				report(validatePeriod(sw.NoDataAfter, groupCtx+" no_data_after"))
 			}
+		} else {
+			report(validatePeriod(sw.NoDataAfter, fmt.Sprintf("switch %s no_data_after", sw.Target)))
 		}
 
-		var err error
-		sw.AllowedVlans, err = mergeStringSlices(cfg.AllowedVlans, sw.AllowedVlans, func(v string) error {
-			return validateVLAN(v, fmt.Sprintf("switch %s allowed_vlans", sw.Target))
-		})
-		if err != nil {
-			return nil, err
+		if sw.VoiceVlan == "" {
+			sw.VoiceVlan = group.VoiceVlan
+			if sw.VoiceVlan == "" {
+				sw.VoiceVlan = cfg.VoiceVlan
+			} else {
+				report(validateVLAN(sw.VoiceVlan, groupCtx+" voice_vlan"))
+			}
+		} else {
+			report(validateVLAN(sw.VoiceVlan, fmt.Sprintf("switch %s voice_vlan", sw.Target)))
+		}
+		validateMultiMac(group.MultiMacPolicy, group.MaxPortMacs, groupCtx)
+		validateMultiMac(sw.MultiMacPolicy, sw.MaxPortMacs, "switch "+sw.Target)
+		for _, policy := range []string{group.MultiMacPolicy, cfg.MultiMacPolicy} {
+			if sw.MultiMacPolicy == "" {
+				sw.MultiMacPolicy = policy
+			}
+		}
+		for _, maxMacs := range []int{group.MaxPortMacs, cfg.MaxPortMacs} {
+			if sw.MaxPortMacs == 0 {
+				sw.MaxPortMacs = maxMacs
+			}
+		}
+		validateRandomMac(group.RandomMacPolicy, groupCtx)
+		validateRandomMac(sw.RandomMacPolicy, "switch "+sw.Target)
+		for _, policy := range []string{group.RandomMacPolicy, cfg.RandomMacPolicy} {
+			if sw.RandomMacPolicy == "" {
+				sw.RandomMacPolicy = policy
+			}
+		}
+		validateLimits(group.MaxChanges, group.MaxVlanDeletions, groupCtx)
+		validateLimits(sw.MaxChanges, sw.MaxVlanDeletions, "switch "+sw.Target)
+		for _, limits := range [][2]int{{group.MaxChanges, group.MaxVlanDeletions}, {cfg.MaxChanges, cfg.MaxVlanDeletions}} {
+			if sw.MaxChanges == 0 {
+				sw.MaxChanges = limits[0]
+			}
+			if sw.MaxVlanDeletions == 0 {
+				sw.MaxVlanDeletions = limits[1]
+			}
+		}
+		validateDebounce(group.MinObservations, group.MinAge, groupCtx)
+		validateDebounce(sw.MinObservations, sw.MinAge, "switch "+sw.Target)
+		for _, layer := range []struct {
+			minObservations int
+			minAge          string
+		}{{group.MinObservations, group.MinAge}, {cfg.MinObservations, cfg.MinAge}} {
+			if sw.MinObservations == 0 {
+				sw.MinObservations = layer.minObservations
+			}
+			if sw.MinAge == "" {
+				sw.MinAge = layer.minAge
+			}
+		}
+		validateDescription(group.PortDescription, groupCtx)
+		validateDescription(sw.PortDescription, "switch "+sw.Target)
+		for _, template := range []string{group.PortDescription, cfg.PortDescription} {
+			if sw.PortDescription == "" {
+				sw.PortDescription = template
+			}
+		}
+		validateWindows(group.ChangeWindows, groupCtx)
+		validateWindows(sw.ChangeWindows, "switch "+sw.Target)
+		for _, windows := range [][]entities.ChangeWindow{group.ChangeWindows, cfg.ChangeWindows} {
+			if len(sw.ChangeWindows) == 0 {
+				sw.ChangeWindows = windows
+			}
+		}
+
+		voiceMacs, voiceErrs := normalizeMacPrefixes(globalVoiceMacs, group.VoiceMacs, groupCtx, "voice_macs")
+		errs = append(errs, voiceErrs...)
+		sw.VoiceMacs, voiceErrs = normalizeMacPrefixes(voiceMacs, sw.VoiceMacs, "switch "+sw.Target, "voice_macs")
+		errs = append(errs, voiceErrs...)
+
+		blockMacs, blockErrs := normalizeMacPrefixes(globalBlockMacs, group.BlockMacs, groupCtx, "block_macs")
+		errs = append(errs, blockErrs...)
+		sw.BlockMacs, blockErrs = normalizeMacPrefixes(blockMacs, sw.BlockMacs, "switch "+sw.Target, "block_macs")
+		errs = append(errs, blockErrs...)
+		validateBlockAction(group.BlockAction, groupCtx)
+		validateBlockAction(sw.BlockAction, "switch "+sw.Target)
+		for _, action := range []string{group.BlockAction, cfg.BlockAction} {
+			if sw.BlockAction == "" {
+				sw.BlockAction = action
+			}
 		}
 
-		sw.ProtectedVlans, err = mergeStringSlices(cfg.ProtectedVlans, sw.ProtectedVlans, func(v string) error {
-			return validateVLAN(v, fmt.Sprintf("switch %s protected_vlans", sw.Target))
+		allowed, mergeErrs := mergeVlans(globalAllowed, group.AllowedVlans, groupCtx, validateVLAN)
+		errs = append(errs, mergeErrs...)
+		sw.AllowedVlans, mergeErrs = mergeVlans(allowed, sw.AllowedVlans, "switch "+sw.Target, validateVLAN)
+		errs = append(errs, mergeErrs...)
+
+		protected, mergeErrs := mergeStringSlices(globalProtected, group.ProtectedVlans, func(v string) error {
+			return validateVLANRange(v, groupCtx+" protected_vlans")
 		})
-		if err != nil {
-			return nil, err
+		errs = append(errs, mergeErrs...)
+		sw.ProtectedVlans, mergeErrs = mergeStringSlices(protected, sw.ProtectedVlans, func(v string) error {
+			return validateVLANRange(v, fmt.Sprintf("switch %s protected_vlans", sw.Target))
+		})
+		errs = append(errs, mergeErrs...)
+		validateProtectedRanges(group.ProtectedRanges, groupCtx)
+		validateProtectedRanges(sw.ProtectedRanges, "switch "+sw.Target)
+		// An empty list protects no range, so only a missing one is inherited.
+		for _, ranges := range [][]string{group.ProtectedRanges, cfg.ProtectedRanges} {
+			if sw.ProtectedRanges == nil {
+				sw.ProtectedRanges = ranges
+			}
 		}
 
 		normalizedExclude := make(map[string]bool)
-		for _, mac := range cfg.ExcludeMacs {
-			normalizedExclude[NormalizeMAC(mac)] = true
-		}
-		for _, mac := range sw.ExcludeMacs {
-			normalizedExclude[NormalizeMAC(mac)] = true
+		for _, list := range [][]string{cfg.ExcludeMacs, group.ExcludeMacs, sw.ExcludeMacs} {
+			for _, mac := range list {
+				normalizedExclude[NormalizeMAC(mac)] = true
+			}
 		}
 		sw.ExcludeMacs = make([]string, 0, len(normalizedExclude))
 		for mac := range normalizedExclude {
 			sw.ExcludeMacs = append(sw.ExcludeMacs, mac)
 		}
 
-		// Normalizar ExcludePorts: trim, lowercase, dedup
-		seenPorts := make(map[string]bool)
-		var normalizedPorts []string
-		for _, p := range sw.ExcludePorts {
-			pNorm := strings.ToLower(strings.TrimSpace(p))
-			if pNorm != "" && !seenPorts[pNorm] {
-				seenPorts[pNorm] = true
-				normalizedPorts = append(normalizedPorts, pNorm)
-			}
-		}
-		sw.ExcludePorts = normalizedPorts
+		// Normalizar ExcludePorts e UplinkPorts: trim, lowercase (exceto regex), dedup
+		sw.ExcludePorts = normalizePortPatterns(sw.ExcludePorts, fmt.Sprintf("switch %s exclude_ports", sw.Target), report)
+		sw.UplinkPorts = normalizePortPatterns(sw.UplinkPorts, fmt.Sprintf("switch %s uplink_ports", sw.Target), report)
 
-		// Mesclar MacToVlan: chaves do switch sobrescrevem globais, chaves e valores normalizados e validados
-		mergedMacToVlan := make(map[string]string)
-		for prefix, vlan := range cfg.MacToVlan {
-			norm := NormalizeMAC(prefix)
-			if len(norm) > 6 {
-				norm = norm[:6]
-			}
-			if vlan == "0" || vlan == "00" || vlan == "" {
-				continue
-			}
-			if err := validateVLAN(vlan, fmt.Sprintf("global mac_to_vlan prefix %s", prefix)); err != nil {
-				return nil, err
+		// Regras de porta: switch antes do grupo antes do global, a primeira que casar vence
+		var rules []entities.PortRule
+		for _, layer := range []struct {
+			context string
+			rules   []entities.PortRule
+		}{
+			{"switch " + sw.Target, sw.PortRules},
+			{groupCtx, group.PortRules},
+			{"global", cfg.PortRules},
+		} {
+			for i, rule := range layer.rules {
+				if rule, ok := normalizePortRule(rule, fmt.Sprintf("%s port_rules[%d]", layer.context, i), validateVLAN, report); ok {
+					rules = append(rules, rule)
+				}
 			}
-			mergedMacToVlan[norm] = vlan
 		}
-		for prefix, vlan := range sw.MacToVlan {
-			norm := NormalizeMAC(prefix)
-			if len(norm) > 6 {
-				norm = norm[:6]
-			}
-			if vlan == "0" || vlan == "00" || vlan == "" {
-				delete(mergedMacToVlan, norm)
-				continue
-			}
-			if err := validateVLAN(vlan, fmt.Sprintf("switch %s mac_to_vlan prefix %s", sw.Target, prefix)); err != nil {
-				return nil, err
-			}
-			mergedMacToVlan[norm] = vlan
+		sw.PortRules = rules
+
+		// Mesclar MacToVlan em camadas global -> grupo -> switch: chaves posteriores sobrescrevem, "0"/"00"/"" removem
+		mergedMacToVlan := make(map[string]string, len(globalMacToVlan))
+		for prefix, vlan := range globalMacToVlan {
+			mergedMacToVlan[prefix] = vlan
 		}
+		errs = append(errs, overlayMacToVlan(mergedMacToVlan, group.MacToVlan, groupCtx, validateVLAN)...)
+		errs = append(errs, overlayMacToVlan(mergedMacToVlan, sw.MacToVlan, "switch "+sw.Target, validateVLAN)...)
 		sw.MacToVlan = mergedMacToVlan
+
+		mergedVendorToVlan := maps.Clone(globalVendorToVlan)
+		errs = append(errs, overlayVendorToVlan(mergedVendorToVlan, group.VendorToVlan, groupCtx, validateVLAN)...)
+		errs = append(errs, overlayVendorToVlan(mergedVendorToVlan, sw.VendorToVlan, "switch "+sw.Target, validateVLAN)...)
+		sw.VendorToVlan = mergedVendorToVlan
+
+		sw.Tags = mergeTags(group.Tags, sw.Tags)
 		debugf(swVerbose, "DEBUG: Merged exclude_macs for %s: %v\n", sw.Target, sw.ExcludeMacs)
 		debugf(swVerbose, "DEBUG: Merged mac_to_vlan for %s: %v\n", sw.Target, sw.MacToVlan)
 		debugf(swVerbose, "DEBUG: Normalized exclude_ports for %s: %v\n", sw.Target, sw.ExcludePorts)
+		debugf(swVerbose, "DEBUG: Group %q and tags for %s: %v\n", sw.Group, sw.Target, sw.Tags)
 		debugf(swVerbose, "DEBUG: Switch %s: Platform=%s, Transport=%s, DefaultVlan=%s\n",
 			sw.Target, sw.Platform, sw.Transport, sw.DefaultVlan)
 	}
 
 	if len(cfg.Switches) == 0 {
-		return nil, fmt.Errorf("no switches defined in the YAML configuration")
+		report(fmt.Errorf("no switches defined in the YAML configuration"))
 	}
 
-	return &cfg, nil
+	return errs
 }
@@ -4,7 +4,10 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
 )
 
 func TestConfigLoadMergeDefaultAndVlans(t *testing.T) {
@@ -67,7 +70,7 @@ switches:
 	if sw1.NoDataVlan != "999" {
 		t.Errorf("sw1.NoDataVlan = %q; expected \"999\"", sw1.NoDataVlan)
 	}
-	if !reflect.DeepEqual(sw1.AllowedVlans, []string{"10", "20"}) {
+	if !reflect.DeepEqual(entities.VlanIDs(sw1.AllowedVlans), []string{"10", "20"}) {
 		t.Errorf("sw1.AllowedVlans = %v; expected [\"10\", \"20\"]", sw1.AllowedVlans)
 	}
 	if !reflect.DeepEqual(sw1.ProtectedVlans, []string{"999"}) {
@@ -84,7 +87,7 @@ switches:
 	}
 	// "10", "20" (global) + "20", "30" (local) -> "10", "20", "30"
 	expectedAllowed := []string{"10", "20", "30"}
-	if !reflect.DeepEqual(sw2.AllowedVlans, expectedAllowed) {
+	if !reflect.DeepEqual(entities.VlanIDs(sw2.AllowedVlans), expectedAllowed) {
 		t.Errorf("sw2.AllowedVlans = %v; expected %v", sw2.AllowedVlans, expectedAllowed)
 	}
 	// "999" (global) + "100" (local) -> "999", "100"
@@ -168,3 +171,585 @@ switches:
 		t.Errorf("sw1.MacToVlan = %v; expected %v", sw1.MacToVlan, expectedMacToVlan)
 	}
 }
+
+func TestConfigLoadFullMacKeys(t *testing.T) {
+	yamlData := `
+platform: ios
+username: admin
+password: cisco123
+enable_password: cisco123
+default_vlan: "1"
+no_data_vlan: "999"
+mac_to_vlan:
+  "11:22:33:44:55:66": "20"
+  "00:11:22:A0/28": "40"
+  "00:11:22:B1:23:45": "50"
+  "00:11:23": "60"
+
+switches:
+  - target: 192.168.1.10
+  - target: 192.168.1.20
+    mac_to_vlan:
+      "00:11:22": ""
+      "00": ""
+`
+	tmpFile := filepath.Join(t.TempDir(), "fullmac.yaml")
+	if err := os.WriteFile(tmpFile, []byte(yamlData), 0644); err != nil {
+		t.Fatalf("failed to write temp yaml config: %v", err)
+	}
+	cfg, err := Load(tmpFile, "", false, 0, false)
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+
+	// A full MAC is one device, no longer the OUI of its first 6 digits.
+	expected := map[string]string{"112233445566": "20", "001122a": "40", "001122b12345": "50", "001123": "60"}
+	if got := cfg.Switches[0].MacToVlan; !reflect.DeepEqual(got, expected) {
+		t.Errorf("switch 192.168.1.10 mac_to_vlan = %v; expected %v", got, expected)
+	}
+	// Removing an OUI removes every inherited key inside it; "00" is too
+	// short to be a prefix and removes nothing.
+	expected = map[string]string{"112233445566": "20", "001123": "60"}
+	if got := cfg.Switches[1].MacToVlan; !reflect.DeepEqual(got, expected) {
+		t.Errorf("switch 192.168.1.20 mac_to_vlan = %v; expected %v", got, expected)
+	}
+}
+
+func TestConfigTargets(t *testing.T) {
+	cfg := &Config{Switches: []entities.SwitchConfig{{Target: "10.0.0.1"}, {Target: "10.0.0.2"}}}
+	if got := cfg.Targets(); !reflect.DeepEqual(got, []string{"10.0.0.1", "10.0.0.2"}) {
+		t.Errorf("Targets() = %v", got)
+	}
+}
+
+func TestConfigLoadGroupDefaults(t *testing.T) {
+	yamlData := `
+platform: ios
+username: admin
+password: cisco123
+enable_password: cisco123
+default_vlan: "1"
+no_data_vlan: "999"
+allowed_vlans: ["10"]
+mac_to_vlan:
+  "aabbcc": "10"
+  "112233": "20"
+
+groups:
+  building-b:
+    default_vlan: "50"
+    allowed_vlans: ["50"]
+    mac_to_vlan:
+      "aabbcc": "50"
+      "112233": "0"
+    tags:
+      site: hq
+      Building: B
+
+switches:
+  - target: 192.168.1.10
+    group: building-b
+    tags:
+      role: access
+      building: b2
+    mac_to_vlan:
+      "deadbe": "60"
+  - target: 192.168.1.20
+    group: building-b
+    default_vlan: "70"
+  - target: 192.168.1.30
+`
+	tmpFile := filepath.Join(t.TempDir(), "groups.yaml")
+	if err := os.WriteFile(tmpFile, []byte(yamlData), 0644); err != nil {
+		t.Fatalf("failed to write temp yaml config: %v", err)
+	}
+	cfg, err := Load(tmpFile, "", false, 0, false)
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+
+	sw1 := cfg.Switches[0]
+	if sw1.DefaultVlan != "50" {
+		t.Errorf("sw1.DefaultVlan = %q; expected group value \"50\"", sw1.DefaultVlan)
+	}
+	if !reflect.DeepEqual(entities.VlanIDs(sw1.AllowedVlans), []string{"10", "50"}) {
+		t.Errorf("sw1.AllowedVlans = %v", sw1.AllowedVlans)
+	}
+	if expected := map[string]string{"aabbcc": "50", "deadbe": "60"}; !reflect.DeepEqual(sw1.MacToVlan, expected) {
+		t.Errorf("sw1.MacToVlan = %v; expected %v", sw1.MacToVlan, expected)
+	}
+	if expected := map[string]string{"site": "hq", "building": "b2", "role": "access"}; !reflect.DeepEqual(sw1.Tags, expected) {
+		t.Errorf("sw1.Tags = %v; expected %v", sw1.Tags, expected)
+	}
+	if cfg.Switches[1].DefaultVlan != "70" {
+		t.Errorf("sw2.DefaultVlan = %q; switch value should override group", cfg.Switches[1].DefaultVlan)
+	}
+	sw3 := cfg.Switches[2]
+	if sw3.DefaultVlan != "1" || sw3.MacToVlan["112233"] != "20" || sw3.Tags != nil {
+		t.Errorf("sw3 without group should only inherit globals, got %+v", sw3)
+	}
+}
+
+func TestConfigLoadUnknownGroup(t *testing.T) {
+	yamlData := `
+platform: ios
+username: admin
+password: cisco123
+enable_password: cisco123
+default_vlan: "1"
+no_data_vlan: "999"
+switches:
+  - target: 192.168.1.10
+    group: missing
+`
+	tmpFile := filepath.Join(t.TempDir(), "groups.yaml")
+	if err := os.WriteFile(tmpFile, []byte(yamlData), 0644); err != nil {
+		t.Fatalf("failed to write temp yaml config: %v", err)
+	}
+	if _, err := Load(tmpFile, "", false, 0, false); err == nil {
+		t.Fatal("expected error for unknown group")
+	}
+}
+
+func TestConfigLoadNoDataAfter(t *testing.T) {
+	yamlData := `
+platform: ios
+username: admin
+password: cisco123
+enable_password: cisco123
+default_vlan: "1"
+no_data_vlan: "999"
+no_data_after: 1h
+groups:
+  lab:
+    no_data_after: 10m
+switches:
+  - target: 192.168.1.10
+  - target: 192.168.1.20
+    group: lab
+  - target: 192.168.1.30
+    group: lab
+    no_data_after: 0s
+`
+	tmpFile := filepath.Join(t.TempDir(), "nodata.yaml")
+	if err := os.WriteFile(tmpFile, []byte(yamlData), 0644); err != nil {
+		t.Fatalf("failed to write temp yaml config: %v", err)
+	}
+	cfg, err := Load(tmpFile, "", false, 0, false)
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	for i, expected := range []string{"1h", "10m", "0s"} {
+		if got := cfg.Switches[i].NoDataAfter; got != expected {
+			t.Errorf("switch %d no_data_after = %q; expected %q", i, got, expected)
+		}
+	}
+
+	invalid := strings.Replace(yamlData, "no_data_after: 1h", "no_data_after: soon", 1)
+	if err := os.WriteFile(tmpFile, []byte(invalid), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(tmpFile, "", false, 0, false); err == nil || !strings.Contains(err.Error(), "no_data_after") {
+		t.Fatalf("expected invalid no_data_after error, got %v", err)
+	}
+}
+
+func TestConfigLoadVoice(t *testing.T) {
+	yamlData := `
+platform: ios
+username: admin
+password: cisco123
+enable_password: cisco123
+default_vlan: "1"
+no_data_vlan: "999"
+voice_vlan: "30"
+voice_macs: ["00:04:F2"]
+groups:
+  phones:
+    voice_vlan: "31"
+    voice_macs: ["0004f2", "00:1B:54:A0/28"]
+switches:
+  - target: 192.168.1.10
+  - target: 192.168.1.20
+    group: phones
+    voice_macs: ["bad-prefix"]
+`
+	tmpFile := filepath.Join(t.TempDir(), "voice.yaml")
+	if err := os.WriteFile(tmpFile, []byte(yamlData), 0644); err != nil {
+		t.Fatalf("failed to write temp yaml config: %v", err)
+	}
+	if _, err := Load(tmpFile, "", false, 0, false); err == nil || !strings.Contains(err.Error(), "voice_macs prefix bad-prefix") {
+		t.Fatalf("expected invalid voice_macs error, got %v", err)
+	}
+
+	valid := strings.Replace(yamlData, `
+    voice_macs: ["bad-prefix"]`, "", 1)
+	if err := os.WriteFile(tmpFile, []byte(valid), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(tmpFile, "", false, 0, false)
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	sw1, sw2 := cfg.Switches[0], cfg.Switches[1]
+	if sw1.VoiceVlan != "30" || !reflect.DeepEqual(sw1.VoiceMacs, []string{"0004f2"}) {
+		t.Errorf("sw1 voice = %s %v", sw1.VoiceVlan, sw1.VoiceMacs)
+	}
+	if sw2.VoiceVlan != "31" || !reflect.DeepEqual(sw2.VoiceMacs, []string{"0004f2", "001b54a"}) {
+		t.Errorf("sw2 voice = %s %v", sw2.VoiceVlan, sw2.VoiceMacs)
+	}
+}
+
+func TestConfigLoadMultiMacPolicy(t *testing.T) {
+	yamlData := `
+platform: ios
+username: admin
+password: cisco123
+enable_password: cisco123
+default_vlan: "1"
+no_data_vlan: "999"
+max_port_macs: 8
+max_changes: 20
+max_fleet_changes: 100
+min_observations: 3
+block_macs: ["DE:AD:BE"]
+groups:
+  lab:
+    multi_mac_policy: majority
+    random_mac_policy: quarantine
+    block_action: shutdown
+    max_vlan_deletions: 2
+    min_age: 1h
+switches:
+  - target: 192.168.1.10
+  - target: 192.168.1.20
+    group: lab
+    max_port_macs: 4
+    max_changes: 5
+  - target: 192.168.1.30
+    group: lab
+    multi_mac_policy: same-vlan-only
+    random_mac_policy: skip
+    block_macs: ["0011.2233.4455"]
+    block_action: quarantine
+`
+	tmpFile := filepath.Join(t.TempDir(), "multimac.yaml")
+	if err := os.WriteFile(tmpFile, []byte(yamlData), 0644); err != nil {
+		t.Fatalf("failed to write temp yaml config: %v", err)
+	}
+	cfg, err := Load(tmpFile, "", false, 0, false)
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	for i, expected := range []struct {
+		policy       string
+		maxMacs      int
+		maxChanges   int
+		maxDeletions int
+	}{{"", 8, 20, 0}, {"majority", 4, 5, 2}, {"same-vlan-only", 8, 20, 2}} {
+		sw := cfg.Switches[i]
+		if sw.MultiMacPolicy != expected.policy || sw.MaxPortMacs != expected.maxMacs {
+			t.Errorf("switch %s: policy %q max %d; expected %q %d", sw.Target, sw.MultiMacPolicy, sw.MaxPortMacs, expected.policy, expected.maxMacs)
+		}
+		if sw.MaxChanges != expected.maxChanges || sw.MaxVlanDeletions != expected.maxDeletions {
+			t.Errorf("switch %s: max_changes %d max_vlan_deletions %d; expected %d %d", sw.Target, sw.MaxChanges, sw.MaxVlanDeletions, expected.maxChanges, expected.maxDeletions)
+		}
+	}
+	for i, expected := range []struct {
+		minObservations int
+		minAge          string
+	}{{3, ""}, {3, "1h"}, {3, "1h"}} {
+		sw := cfg.Switches[i]
+		if sw.MinObservations != expected.minObservations || sw.MinAge != expected.minAge {
+			t.Errorf("switch %s: min_observations %d min_age %q; expected %d %q", sw.Target, sw.MinObservations, sw.MinAge, expected.minObservations, expected.minAge)
+		}
+	}
+	for i, expected := range []string{"", "quarantine", "skip"} {
+		if sw := cfg.Switches[i]; sw.RandomMacPolicy != expected {
+			t.Errorf("switch %s: random_mac_policy %q; expected %q", sw.Target, sw.RandomMacPolicy, expected)
+		}
+	}
+	for i, expected := range []struct {
+		macs   []string
+		action string
+	}{{[]string{"deadbe"}, ""}, {[]string{"deadbe"}, "shutdown"}, {[]string{"deadbe", "001122334455"}, "quarantine"}} {
+		if sw := cfg.Switches[i]; !reflect.DeepEqual(sw.BlockMacs, expected.macs) || sw.BlockAction != expected.action {
+			t.Errorf("switch %s: block_macs %v block_action %q; expected %v %q", sw.Target, sw.BlockMacs, sw.BlockAction, expected.macs, expected.action)
+		}
+	}
+	if cfg.MaxFleetChanges != 100 {
+		t.Errorf("max_fleet_changes = %d; expected 100", cfg.MaxFleetChanges)
+	}
+
+	for _, tc := range []struct{ old, new, expected string }{
+		{"multi_mac_policy: majority", "multi_mac_policy: vote", "multi_mac_policy vote is invalid"},
+		{"random_mac_policy: quarantine", "random_mac_policy: ignore", "random_mac_policy ignore is invalid"},
+		{"block_action: shutdown", "block_action: drop", "block_action drop is invalid"},
+		{`block_macs: ["DE:AD:BE"]`, `block_macs: ["DE:AD"]`, "block_macs prefix DE:AD is invalid"},
+	} {
+		invalid := strings.Replace(yamlData, tc.old, tc.new, 1)
+		if err := os.WriteFile(tmpFile, []byte(invalid), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(tmpFile, "", false, 0, false); err == nil || !strings.Contains(err.Error(), tc.expected) {
+			t.Fatalf("expected %q error, got %v", tc.expected, err)
+		}
+	}
+}
+
+func TestConfigLoadProtectedRanges(t *testing.T) {
+	yamlData := `
+platform: ios
+username: admin
+password: cisco123
+enable_password: cisco123
+default_vlan: "1"
+no_data_vlan: "999"
+protected_vlans: ["999", "900-909"]
+protected_ranges: ["2000-4094"]
+groups:
+  campus:
+    protected_ranges: ["1000-1099", "1400-4094"]
+switches:
+  - target: 192.168.1.10
+  - target: 192.168.1.20
+    group: campus
+    protected_vlans: ["50"]
+  - target: 192.168.1.30
+    group: campus
+    protected_ranges: []
+`
+	tmpFile := filepath.Join(t.TempDir(), "protected.yaml")
+	if err := os.WriteFile(tmpFile, []byte(yamlData), 0644); err != nil {
+		t.Fatalf("failed to write temp yaml config: %v", err)
+	}
+	cfg, err := Load(tmpFile, "", false, 0, false)
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	for i, expected := range []struct {
+		vlans, ranges []string
+	}{
+		{[]string{"999", "900-909"}, []string{"2000-4094"}},
+		{[]string{"999", "900-909", "50"}, []string{"1000-1099", "1400-4094"}},
+		{[]string{"999", "900-909"}, []string{}},
+	} {
+		sw := cfg.Switches[i]
+		if !reflect.DeepEqual(sw.ProtectedVlans, expected.vlans) || !reflect.DeepEqual(sw.ProtectedRanges, expected.ranges) {
+			t.Errorf("switch %s: protected_vlans %v protected_ranges %v; expected %v %v", sw.Target, sw.ProtectedVlans, sw.ProtectedRanges, expected.vlans, expected.ranges)
+		}
+	}
+
+	for _, tc := range []struct{ old, new, expected string }{
+		{`protected_vlans: ["999", "900-909"]`, `protected_vlans: ["909-900"]`, "VLAN range 909-900 must be between 1 and 4094 in global protected_vlans"},
+		{`protected_ranges: ["2000-4094"]`, `protected_ranges: ["2000-5000"]`, "in global protected_ranges"},
+		{`protected_vlans: ["50"]`, `protected_vlans: ["fifty"]`, "invalid VLAN range fifty in switch 192.168.1.20 protected_vlans"},
+		{`protected_ranges: []`, `protected_ranges: ["x"]`, "in switch 192.168.1.30 protected_ranges"},
+	} {
+		invalid := strings.Replace(yamlData, tc.old, tc.new, 1)
+		if err := os.WriteFile(tmpFile, []byte(invalid), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(tmpFile, "", false, 0, false); err == nil || !strings.Contains(err.Error(), tc.expected) {
+			t.Fatalf("expected %q error, got %v", tc.expected, err)
+		}
+	}
+}
+
+func TestConfigLoadPortRules(t *testing.T) {
+	yamlData := `
+platform: ios
+username: admin
+password: cisco123
+enable_password: cisco123
+default_vlan: "1"
+no_data_vlan: "999"
+port_rules:
+  - ports: ["Te*"]
+    exclude: true
+groups:
+  lab:
+    port_rules:
+      - ports: ["Gi1/0/40-48"]
+        vlan: "50"
+switches:
+  - target: 192.168.1.10
+    group: lab
+    exclude_ports: [" Gi1/0/1 ", "gi1/0/1", "re:^Fa0/(1|2)$"]
+    port_rules:
+      - ports: ["Gi1/0/48"]
+        vlan: "60"
+`
+	tmpFile := filepath.Join(t.TempDir(), "portrules.yaml")
+	if err := os.WriteFile(tmpFile, []byte(yamlData), 0644); err != nil {
+		t.Fatalf("failed to write temp yaml config: %v", err)
+	}
+	cfg, err := Load(tmpFile, "", false, 0, false)
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	sw := cfg.Switches[0]
+	expectedRules := []entities.PortRule{
+		{Ports: []string{"gi1/0/48"}, Vlan: "60"},
+		{Ports: []string{"gi1/0/40-48"}, Vlan: "50"},
+		{Ports: []string{"te*"}, Exclude: true},
+	}
+	if !reflect.DeepEqual(sw.PortRules, expectedRules) {
+		t.Errorf("port_rules = %+v; expected %+v", sw.PortRules, expectedRules)
+	}
+	if expected := []string{"gi1/0/1", "re:^Fa0/(1|2)$"}; !reflect.DeepEqual(sw.ExcludePorts, expected) {
+		t.Errorf("exclude_ports = %v; expected %v", sw.ExcludePorts, expected)
+	}
+
+	for _, tc := range []struct{ from, to, want string }{
+		{`vlan: "60"`, `vlan: "60"
+        exclude: true`, "switch 192.168.1.10 port_rules[0] must set either vlan or exclude"},
+		{`"Gi1/0/40-48"`, `"Gi1/0/48-40"`, "group lab port_rules[0] port Gi1/0/48-40 is invalid: range 48-40 is reversed"},
+		{`"re:^Fa0/(1|2)$"`, `"re:(("`, "switch 192.168.1.10 exclude_ports entry re:(( is invalid"},
+	} {
+		invalid := strings.Replace(yamlData, tc.from, tc.to, 1)
+		if err := os.WriteFile(tmpFile, []byte(invalid), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(tmpFile, "", false, 0, false); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("expected %q, got %v", tc.want, err)
+		}
+	}
+}
+
+func TestConfigLoadChangeWindows(t *testing.T) {
+	yamlData := `
+platform: ios
+username: admin
+password: cisco123
+enable_password: cisco123
+default_vlan: "1"
+no_data_vlan: "999"
+change_windows:
+  - days: [sat, sun]
+groups:
+  core:
+    change_windows:
+      - days: [mon-fri]
+        hours: ["22:00-06:00"]
+        timezone: America/Sao_Paulo
+switches:
+  - target: 192.168.1.10
+  - target: 192.168.1.20
+    group: core
+  - target: 192.168.1.30
+    group: core
+    change_windows:
+      - hours: ["12:00-13:00"]
+`
+	tmpFile := filepath.Join(t.TempDir(), "windows.yaml")
+	if err := os.WriteFile(tmpFile, []byte(yamlData), 0644); err != nil {
+		t.Fatalf("failed to write temp yaml config: %v", err)
+	}
+	cfg, err := Load(tmpFile, "", false, 0, false)
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	for i, expected := range []string{"sat,sun all day", "mon-fri 22:00-06:00 America/Sao_Paulo", "every day 12:00-13:00"} {
+		sw := cfg.Switches[i]
+		if len(sw.ChangeWindows) != 1 || sw.ChangeWindows[0].String() != expected {
+			t.Errorf("switch %s: change_windows %v; expected %s", sw.Target, sw.ChangeWindows, expected)
+		}
+	}
+
+	invalid := strings.Replace(yamlData, "timezone: America/Sao_Paulo", "timezone: Nowhere/City", 1)
+	if err := os.WriteFile(tmpFile, []byte(invalid), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(tmpFile, "", false, 0, false); err == nil || !strings.Contains(err.Error(), "group core change_windows[0] is invalid: unknown timezone Nowhere/City") {
+		t.Fatalf("expected invalid timezone error, got %v", err)
+	}
+}
+
+func TestConfigLoadNamedVlans(t *testing.T) {
+	yamlData := `
+platform: ios
+username: admin
+password: cisco123
+enable_password: cisco123
+default_vlan: "1"
+no_data_vlan: "999"
+allowed_vlans:
+  - 1
+  - {id: 10, name: USERS}
+  - id: "20"
+groups:
+  lab:
+    allowed_vlans:
+      - {id: 20, name: LAB}
+switches:
+  - target: 192.168.1.10
+    group: lab
+    allowed_vlans:
+      - {id: 10, name: STAFF}
+      - "30"
+  - target: 192.168.1.20
+`
+	tmpFile := filepath.Join(t.TempDir(), "named.yaml")
+	if err := os.WriteFile(tmpFile, []byte(yamlData), 0644); err != nil {
+		t.Fatalf("failed to write temp yaml config: %v", err)
+	}
+	cfg, err := Load(tmpFile, "", false, 0, false)
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	expected := []entities.Vlan{{ID: "1"}, {ID: "10", Name: "STAFF"}, {ID: "20", Name: "LAB"}, {ID: "30"}}
+	if !reflect.DeepEqual(cfg.Switches[0].AllowedVlans, expected) {
+		t.Errorf("sw1.AllowedVlans = %v; expected %v", cfg.Switches[0].AllowedVlans, expected)
+	}
+	expected = []entities.Vlan{{ID: "1"}, {ID: "10", Name: "USERS"}, {ID: "20"}}
+	if !reflect.DeepEqual(cfg.Switches[1].AllowedVlans, expected) {
+		t.Errorf("sw2.AllowedVlans = %v; expected %v", cfg.Switches[1].AllowedVlans, expected)
+	}
+
+	invalid := strings.Replace(yamlData, "name: LAB", "name: LAB ROOM", 1)
+	if err := os.WriteFile(tmpFile, []byte(invalid), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(tmpFile, "", false, 0, false); err == nil || !strings.Contains(err.Error(), "group lab allowed_vlans 20: VLAN name") {
+		t.Fatalf("expected invalid VLAN name error, got %v", err)
+	}
+}
+
+func TestConfigLoadPortDescription(t *testing.T) {
+	yamlData := `
+platform: ios
+username: admin
+password: cisco123
+enable_password: cisco123
+default_vlan: "1"
+no_data_vlan: "999"
+port_description: "negev:{vendor}:{mac}:{rule}"
+groups:
+  lab:
+    port_description: "negev:{vlan}"
+switches:
+  - target: 192.168.1.10
+  - target: 192.168.1.20
+    group: lab
+`
+	tmpFile := filepath.Join(t.TempDir(), "description.yaml")
+	if err := os.WriteFile(tmpFile, []byte(yamlData), 0644); err != nil {
+		t.Fatalf("failed to write temp yaml config: %v", err)
+	}
+	cfg, err := Load(tmpFile, "", false, 0, false)
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	if cfg.Switches[0].PortDescription != "negev:{vendor}:{mac}:{rule}" || cfg.Switches[1].PortDescription != "negev:{vlan}" {
+		t.Errorf("port_description = %q, %q", cfg.Switches[0].PortDescription, cfg.Switches[1].PortDescription)
+	}
+
+	invalid := strings.Replace(yamlData, `"negev:{vlan}"`, `"{vlan}"`, 1)
+	if err := os.WriteFile(tmpFile, []byte(invalid), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(tmpFile, "", false, 0, false); err == nil || !strings.Contains(err.Error(), "group lab port_description {vlan} is invalid: must start with negev:") {
+		t.Fatalf("expected invalid port_description error, got %v", err)
+	}
+}
@@ -42,3 +42,20 @@ func FindPath(yamlFile string, verbosity int) (string, error) {
 	}
 	return "", fmt.Errorf("no config.yaml file found in ./, ~/.config/negev/, or /etc/negev/")
 }
+
+// DefaultStateDir is where negev keeps data between runs (the rollback
+// journal) when the configuration does not set state_dir.
+func DefaultStateDir() string {
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("LOCALAPPDATA"); dir != "" {
+			return filepath.Join(dir, "negev")
+		}
+	}
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "negev")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".local", "state", "negev")
+	}
+	return filepath.Join(os.TempDir(), "negev")
+}
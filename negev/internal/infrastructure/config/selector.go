@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
+)
+
+type selectorTerm struct {
+	key    string
+	value  string
+	negate bool
+}
+
+// Selector is a conjunction of key=value / key!=value terms matched against
+// switch tags. The keys "group" and "platform" match the switch fields of the
+// same name.
+type Selector []selectorTerm
+
+func ParseSelector(expr string) (Selector, error) {
+	var sel Selector
+	for _, raw := range strings.Split(expr, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		term := selectorTerm{}
+		key, value, ok := strings.Cut(raw, "!=")
+		if ok {
+			term.negate = true
+		} else if key, value, ok = strings.Cut(raw, "="); !ok {
+			return nil, fmt.Errorf("invalid selector term %q, expected key=value or key!=value", raw)
+		}
+		term.key = strings.ToLower(strings.TrimSpace(key))
+		term.value = strings.TrimSpace(value)
+		if term.key == "" {
+			return nil, fmt.Errorf("invalid selector term %q, key is empty", raw)
+		}
+		sel = append(sel, term)
+	}
+	if len(sel) == 0 {
+		return nil, fmt.Errorf("selector %q has no terms", expr)
+	}
+	return sel, nil
+}
+
+func (s Selector) Matches(sw entities.SwitchConfig) bool {
+	for _, term := range s {
+		var actual string
+		switch term.key {
+		case "group":
+			actual = sw.Group
+		case "platform":
+			actual = sw.PlatformID()
+		default:
+			actual = sw.Tags[term.key]
+		}
+		if strings.EqualFold(actual, term.value) == term.negate {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Config) Select(sel Selector) []string {
+	var targets []string
+	for _, sw := range c.Switches {
+		if sel.Matches(sw) {
+			targets = append(targets, sw.Target)
+		}
+	}
+	return targets
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
+)
+
+func TestParseSelector(t *testing.T) {
+	sel, err := ParseSelector(" Site=hq , role!=core ")
+	if err != nil {
+		t.Fatalf("ParseSelector() returned error: %v", err)
+	}
+	expected := Selector{
+		{key: "site", value: "hq"},
+		{key: "role", value: "core", negate: true},
+	}
+	if !reflect.DeepEqual(sel, expected) {
+		t.Errorf("ParseSelector() = %+v; expected %+v", sel, expected)
+	}
+
+	for _, bad := range []string{"", ",", "site", "=hq"} {
+		if _, err := ParseSelector(bad); err == nil {
+			t.Errorf("ParseSelector(%q): expected error", bad)
+		}
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	cfg := &Config{Switches: []entities.SwitchConfig{
+		{Target: "10.0.0.1", Group: "building-b", Platform: "ios", Tags: map[string]string{"site": "hq", "role": "access"}},
+		{Target: "10.0.0.2", Group: "building-b", Platform: "dmos", Tags: map[string]string{"site": "hq", "role": "core"}},
+		{Target: "10.0.0.3", Platform: "ios", Tags: map[string]string{"site": "branch", "role": "access"}},
+	}}
+	cases := []struct {
+		expr     string
+		expected []string
+	}{
+		{"site=hq,role=access", []string{"10.0.0.1"}},
+		{"site=HQ", []string{"10.0.0.1", "10.0.0.2"}},
+		{"group=building-b", []string{"10.0.0.1", "10.0.0.2"}},
+		{"platform=ios,role!=core", []string{"10.0.0.1", "10.0.0.3"}},
+		{"rack=12", nil},
+	}
+	for _, tc := range cases {
+		sel, err := ParseSelector(tc.expr)
+		if err != nil {
+			t.Fatalf("ParseSelector(%q) returned error: %v", tc.expr, err)
+		}
+		if got := cfg.Select(sel); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("Select(%q) = %v; expected %v", tc.expr, got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	unknownFieldRegex = regexp.MustCompile(`^(line \d+): field (\S+) not found in type \S+$`)
+	hexRegex          = regexp.MustCompile(`^[0-9a-f]+$`)
+)
+
+// Validate checks yamlFile without connecting to any switch and returns every
+// problem found: everything Load rejects, unknown keys, and settings that load
+// fine but cannot work as intended. validInterface tells whether a name looks
+// like an interface of a platform; nil skips that check.
+func Validate(yamlFile string, validInterface func(platform, name string) bool) []error {
+	data, err := os.ReadFile(yamlFile)
+	if err != nil {
+		return []error{fmt.Errorf("failed to read YAML file %s: %v", yamlFile, err)}
+	}
+
+	var errs []error
+	var cfg Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return []error{fmt.Errorf("failed to parse YAML: %v", err)}
+		}
+		// The decoder keeps going after type errors, so cfg holds everything
+		// else and the remaining checks still apply.
+		for _, msg := range typeErr.Errors {
+			if m := unknownFieldRegex.FindStringSubmatch(msg); m != nil {
+				msg = fmt.Sprintf("%s: unknown key %q", m[1], m[2])
+			}
+			errs = append(errs, errors.New(msg))
+		}
+	}
+
+	errs = append(errs, lintRaw(&cfg)...)
+	errs = append(errs, cfg.resolve("", 0)...)
+	errs = append(errs, lintResolved(&cfg, validInterface)...)
+	return errs
+}
+
+// lintRaw checks what resolve normalizes away: MAC prefixes written in
+// different ways and duplicate targets.
+func lintRaw(cfg *Config) []error {
+	var errs []error
+
+	seen := make(map[string]bool)
+	for _, sw := range cfg.Switches {
+		if sw.Target == "" {
+			continue
+		}
+		if seen[sw.Target] {
+			errs = append(errs, fmt.Errorf("switch %s is defined more than once", sw.Target))
+		}
+		seen[sw.Target] = true
+	}
+
+	errs = append(errs, lintMacs("global", cfg.MacToVlan, cfg.ExcludeMacs)...)
+	for _, name := range sortedGroupNames(cfg.Groups) {
+		g := cfg.Groups[name]
+		errs = append(errs, lintMacs("group "+name, g.MacToVlan, g.ExcludeMacs)...)
+	}
+	for _, sw := range cfg.Switches {
+		errs = append(errs, lintMacs("switch "+sw.Target, sw.MacToVlan, sw.ExcludeMacs)...)
+	}
+	return errs
+}
+
+func lintMacs(context string, macToVlan map[string]string, excludeMacs []string) []error {
+	var errs []error
+	owners := make(map[string]string)
+	for _, prefix := range sortedMapKeys(macToVlan) {
+		// Invalid keys are reported by resolve.
+		norm, err := NormalizeMacPrefix(prefix)
+		if err != nil {
+			continue
+		}
+		if other, ok := owners[norm]; ok && macToVlan[other] != macToVlan[prefix] {
+			errs = append(errs, fmt.Errorf("%s mac_to_vlan prefixes %s and %s are the same block with different VLANs", context, other, prefix))
+		}
+		owners[norm] = prefix
+	}
+	for _, mac := range excludeMacs {
+		if norm := NormalizeMAC(mac); len(norm) != 12 || !hexRegex.MatchString(norm) {
+			errs = append(errs, fmt.Errorf("%s exclude_macs entry %s is not a MAC address", context, mac))
+		}
+	}
+	return errs
+}
+
+// lintResolved checks the merged settings of every switch.
+func lintResolved(cfg *Config, validInterface func(platform, name string) bool) []error {
+	var errs []error
+	for _, sw := range cfg.Switches {
+		if sw.Target == "" {
+			continue
+		}
+		ctx := "switch " + sw.Target
+		allowed := entities.VlanIDs(sw.AllowedVlans)
+
+		for _, field := range []struct{ name, vlan string }{
+			{"default_vlan", sw.DefaultVlan},
+			{"no_data_vlan", sw.NoDataVlan},
+			{"voice_vlan", sw.VoiceVlan},
+		} {
+			if field.vlan == "" {
+				continue
+			}
+			if sw.IsProtectedVlan(field.vlan) {
+				errs = append(errs, fmt.Errorf("%s %s %s is protected", ctx, field.name, field.vlan))
+			}
+			if len(allowed) > 0 && !slices.Contains(allowed, field.vlan) {
+				errs = append(errs, fmt.Errorf("%s %s %s is not in allowed_vlans", ctx, field.name, field.vlan))
+			}
+		}
+
+		if sw.VoiceVlan != "" && len(sw.VoiceMacs) == 0 {
+			errs = append(errs, fmt.Errorf("%s voice_vlan is set but voice_macs is empty, so no phone is recognized", ctx))
+		} else if sw.VoiceVlan == "" && len(sw.VoiceMacs) > 0 {
+			errs = append(errs, fmt.Errorf("%s voice_macs is set but voice_vlan is not", ctx))
+		}
+
+		for _, rule := range sw.PortRules {
+			if rule.Vlan == "" {
+				continue
+			}
+			if sw.IsProtectedVlan(rule.Vlan) {
+				errs = append(errs, fmt.Errorf("%s port_rules %s -> %s is protected", ctx, strings.Join(rule.Ports, ","), rule.Vlan))
+			}
+			if len(allowed) > 0 && !slices.Contains(allowed, rule.Vlan) {
+				errs = append(errs, fmt.Errorf("%s port_rules %s -> %s is not in allowed_vlans", ctx, strings.Join(rule.Ports, ","), rule.Vlan))
+			}
+		}
+
+		if len(allowed) > 0 {
+			for _, prefix := range sortedMapKeys(sw.MacToVlan) {
+				if vlan := sw.MacToVlan[prefix]; !slices.Contains(allowed, vlan) {
+					errs = append(errs, fmt.Errorf("%s mac_to_vlan %s -> %s is not in allowed_vlans", ctx, prefix, vlan))
+				}
+			}
+			for _, vendor := range sortedMapKeys(sw.VendorToVlan) {
+				if vlan := sw.VendorToVlan[vendor]; !slices.Contains(allowed, vlan) {
+					errs = append(errs, fmt.Errorf("%s vendor_to_vlan %s -> %s is not in allowed_vlans", ctx, vendor, vlan))
+				}
+			}
+		}
+		if len(sw.UplinkPorts) > 0 && len(sw.AllowedVlans) == 0 {
+			errs = append(errs, fmt.Errorf("%s uplink_ports is set but allowed_vlans is not, so no VLAN is synchronized to them", ctx))
+		}
+		if len(sw.VendorToVlan) > 0 && cfg.OuiFile == "" {
+			errs = append(errs, fmt.Errorf("%s vendor_to_vlan is set but oui_file is not, so no vendor is recognized", ctx))
+		}
+
+		if validInterface != nil && validatePlatform(sw.Platform) == nil {
+			for _, port := range sw.ExcludePorts {
+				if !looksLikeInterface(validInterface, sw.Platform, port) {
+					errs = append(errs, fmt.Errorf("%s exclude_ports entry %s does not look like a %s interface", ctx, port, sw.Platform))
+				}
+			}
+			for _, port := range sw.UplinkPorts {
+				if !looksLikeInterface(validInterface, sw.Platform, port) {
+					errs = append(errs, fmt.Errorf("%s uplink_ports entry %s does not look like a %s interface", ctx, port, sw.Platform))
+				}
+			}
+			for _, rule := range sw.PortRules {
+				for _, port := range rule.Ports {
+					if !looksLikeInterface(validInterface, sw.Platform, port) {
+						errs = append(errs, fmt.Errorf("%s port_rules entry %s does not look like a %s interface", ctx, port, sw.Platform))
+					}
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// looksLikeInterface checks names and ranges; globs and regular expressions
+// are taken as they are.
+func looksLikeInterface(validInterface func(platform, name string) bool, platform, pattern string) bool {
+	p, err := entities.ParsePortPattern(pattern)
+	if err != nil || !p.IsName() {
+		return true
+	}
+	return validInterface(platform, p.FirstName())
+}
+
+func sortedGroupNames(groups map[string]GroupConfig) []string {
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func iosOnly(platform, name string) bool {
+	return platform != "ios" || strings.HasPrefix(name, "gi")
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	path := writeConfig(t, `
+username: admin
+password: secret
+enable_password: secret
+default_vlan: "10"
+no_data_vlan: "999"
+allowed_vlans: ["10", "20", "999"]
+protected_vlans: ["999"]
+colour: blue
+mac_to_vlan:
+  "aa:bb:cc": "20"
+  "aabbcc/24": "30"
+  "aabbcc11": "30"
+  "xyz123": "20"
+  "aabb": "20"
+exclude_macs: ["00:11:22"]
+vendor_to_vlan:
+  "Zebra Technologies": "40"
+  "re:(unclosed": "20"
+groups:
+  lab:
+    default_vlan: "abc"
+switches:
+  - target: 10.0.0.1
+    platform: ios
+    group: lab
+    exclude_ports: ["Gi1/0/1", "eth 1/1", "Gi1/0/40-48", "Te*"]
+    uplink_ports: ["Gi1/0/49", "eth 1/49"]
+    port_rules:
+      - ports: ["eth1/1-4"]
+        vlan: "999"
+    defualt_vlan: "5"
+  - target: 10.0.0.1
+    platform: ios
+  - target: 10.0.0.2
+    group: missing
+    voice_vlan: "30"
+`)
+	problems := Validate(path, iosOnly)
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.Error())
+	}
+	all := strings.Join(got, "\n")
+	for _, want := range []string{
+		`line 9: unknown key "colour"`,
+		`unknown key "defualt_vlan"`,
+		"switch 10.0.0.1 is defined more than once",
+		"global mac_to_vlan prefix aabb is invalid: must cover between 24 and 48 bits",
+		"global mac_to_vlan prefixes aa:bb:cc and aabbcc/24 are the same block with different VLANs",
+		"global mac_to_vlan prefix xyz123 is invalid: not hexadecimal",
+		"global exclude_macs entry 00:11:22 is not a MAC address",
+		"invalid VLAN number in group lab default_vlan: abc",
+		"platform is required for switch 10.0.0.2",
+		"switch 10.0.0.2 references unknown group missing",
+		"switch 10.0.0.1 no_data_vlan 999 is protected",
+		"switch 10.0.0.1 mac_to_vlan aabbcc -> 30 is not in allowed_vlans",
+		`global vendor_to_vlan "re:(unclosed" is invalid: invalid regular expression`,
+		"switch 10.0.0.1 vendor_to_vlan Zebra Technologies -> 40 is not in allowed_vlans",
+		"switch 10.0.0.1 vendor_to_vlan is set but oui_file is not, so no vendor is recognized",
+		"switch 10.0.0.1 exclude_ports entry eth 1/1 does not look like a ios interface",
+		"switch 10.0.0.1 uplink_ports entry eth 1/49 does not look like a ios interface",
+		"switch 10.0.0.2 voice_vlan is set but voice_macs is empty",
+		"switch 10.0.0.1 port_rules eth1/1-4 -> 999 is protected",
+		"switch 10.0.0.1 port_rules entry eth1/1-4 does not look like a ios interface",
+	} {
+		if !strings.Contains(all, want) {
+			t.Errorf("missing problem %q in:\n%s", want, all)
+		}
+	}
+	if strings.Contains(all, "prefix aabbcc11") {
+		t.Errorf("longer prefix reported as a problem:\n%s", all)
+	}
+	if strings.Count(all, "prefix xyz123") != 1 {
+		t.Errorf("global prefix problem reported more than once:\n%s", all)
+	}
+	if strings.Contains(all, "exclude_ports entry gi1/0/1") || strings.Contains(all, "entry gi1/0/40-48") || strings.Contains(all, "entry te*") || strings.Contains(all, "entry gi1/0/49") {
+		t.Errorf("valid interface reported:\n%s", all)
+	}
+}
+
+func TestValidateCleanConfig(t *testing.T) {
+	path := writeConfig(t, `
+username: admin
+password: secret
+enable_password: secret
+default_vlan: "10"
+no_data_vlan: "999"
+voice_vlan: "30"
+voice_macs: ["00:04:f2"]
+mac_to_vlan:
+  "aa:bb:cc": "20"
+switches:
+  - target: 10.0.0.1
+    platform: ios
+    exclude_ports: ["Gi1/0/24"]
+    port_rules:
+      - ports: ["Gi1/0/40-48"]
+        vlan: "20"
+`)
+	if problems := Validate(path, iosOnly); len(problems) != 0 {
+		t.Fatalf("expected no problems, got %v", problems)
+	}
+}
+
+func TestValidateDemoConfig(t *testing.T) {
+	if problems := Validate(filepath.Join("..", "..", "..", "..", "demos", "config.yaml"), iosOnly); len(problems) != 0 {
+		t.Fatalf("the shipped demo config must stay valid, got %v", problems)
+	}
+}
+
+func TestValidateSyntaxError(t *testing.T) {
+	problems := Validate(writeConfig(t, "switches: [\n"), nil)
+	if len(problems) != 1 || !strings.Contains(problems[0].Error(), "failed to parse YAML") {
+		t.Fatalf("expected a single parse error, got %v", problems)
+	}
+	if problems := Validate(filepath.Join(t.TempDir(), "missing.yaml"), nil); len(problems) != 1 {
+		t.Fatalf("expected a single read error, got %v", problems)
+	}
+}
@@ -0,0 +1,244 @@
+package httpapi
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/carlosrabelo/negev/negev/internal/application/services"
+	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
+	"github.com/carlosrabelo/negev/negev/internal/domain/ports"
+	domainServices "github.com/carlosrabelo/negev/negev/internal/domain/services"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/config"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/metrics"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/storage"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/transport"
+)
+
+// maxPlanSize caps the body of an apply request.
+const maxPlanSize = 1 << 20
+
+type switchSummary struct {
+	Target    string            `json:"target"`
+	Platform  string            `json:"platform"`
+	Transport string            `json:"transport"`
+	Group     string            `json:"group,omitempty"`
+	Tags      map[string]string `json:"tags,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Server exposes the configured switches over HTTP:
+//
+//	GET  /switches                  list switches (no credentials)
+//	POST /switches/{target}/plan    sandbox run, returns the plan
+//	POST /switches/{target}/apply   apply the plan in the body (needs allowApply and a token)
+//	GET  /switches/{target}/result  last run recorded for the switch
+//
+// With a token every request must send it as "Authorization: Bearer <token>".
+type Server struct {
+	config     func() *config.Config
+	tracker    *services.RunTracker
+	metrics    *metrics.Registry
+	opts       services.RunOptions
+	allowApply bool
+	token      string
+	newAdapter services.AdapterFactory
+	mux        *http.ServeMux
+}
+
+func NewServer(cfg func() *config.Config, tracker *services.RunTracker, opts services.RunOptions, allowApply bool, token string) *Server {
+	s := &Server{
+		config:     cfg,
+		tracker:    tracker,
+		opts:       opts,
+		allowApply: allowApply,
+		token:      token,
+		newAdapter: newPersistentAdapter,
+		mux:        http.NewServeMux(),
+	}
+	s.mux.HandleFunc("GET /switches", s.listSwitches)
+	s.mux.HandleFunc("POST /switches/{target}/plan", s.plan)
+	s.mux.HandleFunc("POST /switches/{target}/apply", s.apply)
+	s.mux.HandleFunc("GET /switches/{target}/result", s.result)
+	return s
+}
+
+// SetMetrics makes runs started through the API report to m.
+func (s *Server) SetMetrics(m *metrics.Registry) {
+	s.metrics = m
+}
+
+func newPersistentAdapter(sc entities.SwitchConfig) ports.SwitchRepository {
+	return transport.NewPersistentSwitchAdapter(sc)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.token != "" && !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="negev"`)
+		writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "missing or invalid API token"})
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) == 1
+}
+
+func (s *Server) listSwitches(w http.ResponseWriter, r *http.Request) {
+	cfg, ok := s.currentConfig(w)
+	if !ok {
+		return
+	}
+	result := make([]switchSummary, 0, len(cfg.Switches))
+	for _, sw := range cfg.Switches {
+		result = append(result, switchSummary{
+			Target:    sw.Target,
+			Platform:  sw.PlatformID(),
+			Transport: sw.Transport,
+			Group:     sw.Group,
+			Tags:      sw.Tags,
+		})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) plan(w http.ResponseWriter, r *http.Request) {
+	s.run(w, r, true)
+}
+
+// apply executes a plan returned by the plan endpoint, or written by
+// negev plan, after re-reading the switch: nothing runs if its state drifted
+// since the plan was reviewed or an action does not fit the configuration.
+func (s *Server) apply(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case !s.allowApply:
+		writeJSON(w, http.StatusForbidden, errorResponse{Error: "applying changes is disabled, start the server with --write"})
+		return
+	case s.token == "":
+		writeJSON(w, http.StatusForbidden, errorResponse{Error: "applying changes needs an API token, set --api-token or NEGEV_API_TOKEN"})
+		return
+	}
+	target := r.PathValue("target")
+	app, ok := s.app(w, target)
+	if !ok {
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPlanSize))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "failed to read plan: " + err.Error()})
+		return
+	}
+	plan, err := storage.DecodePlan(data)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	if plan.Target != target {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "plan was recorded for " + plan.Target + ", not " + target})
+		return
+	}
+
+	opts := s.opts
+	opts.Output = services.OutputJSON
+	err = app.Apply(plan, opts)
+	switch {
+	case errors.Is(err, services.ErrTargetBusy), errors.Is(err, domainServices.ErrStateDrift):
+		writeJSON(w, http.StatusConflict, errorResponse{Error: err.Error()})
+	case errors.Is(err, domainServices.ErrInvalidPlan):
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+	case err != nil:
+		slog.Error("API apply failed", "target", target, "error", err)
+		writeJSON(w, http.StatusBadGateway, errorResponse{Error: err.Error()})
+	default:
+		record, _ := s.tracker.Last(target)
+		writeJSON(w, http.StatusOK, record)
+	}
+}
+
+func (s *Server) run(w http.ResponseWriter, r *http.Request, sandbox bool) {
+	target := r.PathValue("target")
+	app, ok := s.app(w, target)
+	if !ok {
+		return
+	}
+
+	opts := s.opts
+	opts.Sandbox = sandbox
+	opts.Output = services.OutputJSON
+	_, err := app.Run(opts)
+	if errors.Is(err, services.ErrTargetBusy) {
+		writeJSON(w, http.StatusConflict, errorResponse{Error: err.Error()})
+		return
+	}
+
+	record, _ := s.tracker.Last(target)
+	status := http.StatusOK
+	if err != nil {
+		slog.Error("API run failed", "target", target, "sandbox", sandbox, "error", err)
+		status = http.StatusBadGateway
+	}
+	writeJSON(w, status, record)
+}
+
+// app returns the application service for target, or writes why there is none.
+func (s *Server) app(w http.ResponseWriter, target string) (*services.VLANApplicationService, bool) {
+	cfg, ok := s.currentConfig(w)
+	if !ok {
+		return nil, false
+	}
+	if !hasTarget(cfg, target) {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "target " + target + " not found in configuration"})
+		return nil, false
+	}
+	app := services.NewVLANApplicationService(cfg, target)
+	app.SetAdapterFactory(s.newAdapter)
+	app.SetTracker(s.tracker)
+	app.SetMetrics(s.metrics)
+	return app, true
+}
+
+func (s *Server) result(w http.ResponseWriter, r *http.Request) {
+	target := r.PathValue("target")
+	record, ok := s.tracker.Last(target)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "no run recorded for " + target})
+		return
+	}
+	writeJSON(w, http.StatusOK, record)
+}
+
+func (s *Server) currentConfig(w http.ResponseWriter) (*config.Config, bool) {
+	cfg := s.config()
+	if cfg == nil {
+		writeJSON(w, http.StatusServiceUnavailable, errorResponse{Error: "configuration not loaded yet"})
+		return nil, false
+	}
+	return cfg, true
+}
+
+func hasTarget(cfg *config.Config, target string) bool {
+	for _, sw := range cfg.Switches {
+		if sw.Target == target {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("Failed to write API response", "error", err)
+	}
+}
@@ -0,0 +1,278 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/carlosrabelo/negev/negev/internal/application/services"
+	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
+	"github.com/carlosrabelo/negev/negev/internal/domain/ports"
+	"github.com/carlosrabelo/negev/negev/internal/infrastructure/config"
+	_ "github.com/carlosrabelo/negev/negev/internal/platform/ios"
+)
+
+type fakeSwitch struct {
+	mu        sync.Mutex
+	connected bool
+	executed  []string
+	block     chan struct{}
+}
+
+var fakeResponses = map[string]string{
+	"show vlan brief": `
+VLAN Name                             Status    Ports
+---- -------------------------------- --------- -------------------------------
+1    default                          active    Gi1/0/1
+10   VLAN_10                          active
+`,
+	"show interfaces trunk": `
+Port        Mode             Encapsulation  Status        Native vlan
+Gi1/0/24    on               802.1q         trunking      1
+`,
+	"show interfaces status": `
+Port      Name               Status       Vlan       Duplex  Speed Type
+Gi1/0/1                      connected    1          a-full  a-1000 10/100/1000BaseTX
+`,
+	"show mac address-table dynamic": `
+Vlan    Mac Address       Type        Ports
+----    -----------       ----        -----
+   1    aabb.ccdd.eeff    DYNAMIC     Gi1/0/1
+`,
+}
+
+func (f *fakeSwitch) Connect() error {
+	if f.block != nil {
+		<-f.block
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.connected = true
+	return nil
+}
+
+func (f *fakeSwitch) Disconnect() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.connected = false
+}
+
+func (f *fakeSwitch) ExecuteCommand(cmd string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.executed = append(f.executed, cmd)
+	return fakeResponses[cmd], nil
+}
+
+func (f *fakeSwitch) IsConnected() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.connected
+}
+
+func (f *fakeSwitch) ran(cmd string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.executed {
+		if c == cmd {
+			return true
+		}
+	}
+	return false
+}
+
+const testToken = "s3cr3t-token"
+
+func newTestServer(sw *fakeSwitch, allowApply bool, token string) (*Server, *services.RunTracker) {
+	cfg := &config.Config{Switches: []entities.SwitchConfig{{
+		Target:      "10.0.0.1",
+		Platform:    "ios",
+		Password:    "secret",
+		DefaultVlan: "10",
+		Group:       "hq",
+		Tags:        map[string]string{"role": "access"},
+	}}}
+	tracker := services.NewRunTracker()
+	s := NewServer(func() *config.Config { return cfg }, tracker, services.RunOptions{}, allowApply, token)
+	s.newAdapter = func(entities.SwitchConfig) ports.SwitchRepository { return sw }
+	return s, tracker
+}
+
+func do(s *Server, method, path string) *httptest.ResponseRecorder {
+	return doWith(s, method, path, "", nil)
+}
+
+func doWith(s *Server, method, path, token string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestListSwitchesOmitsCredentials(t *testing.T) {
+	s, _ := newTestServer(&fakeSwitch{}, false, "")
+	rec := do(s, http.MethodGet, "/switches")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; expected 200", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "secret") {
+		t.Fatalf("response leaks credentials: %s", rec.Body)
+	}
+	var got []switchSummary
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Target != "10.0.0.1" || got[0].Platform != "ios" || got[0].Group != "hq" || got[0].Tags["role"] != "access" {
+		t.Fatalf("unexpected switches: %+v", got)
+	}
+}
+
+func TestPlanIsSandboxedAndRecorded(t *testing.T) {
+	sw := &fakeSwitch{}
+	s, _ := newTestServer(sw, false, "")
+
+	if rec := do(s, http.MethodGet, "/switches/10.0.0.1/result"); rec.Code != http.StatusNotFound {
+		t.Fatalf("result before any run: status = %d; expected 404", rec.Code)
+	}
+
+	rec := do(s, http.MethodPost, "/switches/10.0.0.1/plan")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("plan status = %d; body %s", rec.Code, rec.Body)
+	}
+	var record services.RunRecord
+	if err := json.Unmarshal(rec.Body.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if !record.Sandbox || record.Plan == nil || len(record.Plan.Actions) != 1 || record.Plan.Actions[0].TargetVlan != "10" {
+		t.Fatalf("unexpected record: %+v", record)
+	}
+	if sw.ran("switchport access vlan 10") || sw.ran("write memory") {
+		t.Fatal("plan must not change the switch")
+	}
+
+	rec = do(s, http.MethodGet, "/switches/10.0.0.1/result")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"target_vlan":"10"`) {
+		t.Fatalf("result status = %d; body %s", rec.Code, rec.Body)
+	}
+}
+
+func TestTokenRequired(t *testing.T) {
+	s, _ := newTestServer(&fakeSwitch{}, false, testToken)
+	for _, token := range []string{"", "wrong"} {
+		rec := doWith(s, http.MethodGet, "/switches", token, nil)
+		if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("token %q: status = %d; expected 401 with a challenge", token, rec.Code)
+		}
+	}
+	if rec := doWith(s, http.MethodGet, "/switches", testToken, nil); rec.Code != http.StatusOK {
+		t.Errorf("status = %d; expected 200", rec.Code)
+	}
+}
+
+func TestApplyRequiresWriteAndToken(t *testing.T) {
+	sw := &fakeSwitch{}
+	for _, tc := range []struct {
+		allowApply bool
+		token      string
+	}{{false, testToken}, {true, ""}} {
+		s, _ := newTestServer(sw, tc.allowApply, tc.token)
+		if rec := doWith(s, http.MethodPost, "/switches/10.0.0.1/apply", tc.token, nil); rec.Code != http.StatusForbidden {
+			t.Errorf("write %v token %q: status = %d; expected 403", tc.allowApply, tc.token, rec.Code)
+		}
+	}
+	if len(sw.executed) != 0 {
+		t.Fatalf("switch was contacted: %v", sw.executed)
+	}
+}
+
+func TestApplyExecutesReviewedPlan(t *testing.T) {
+	sw := &fakeSwitch{}
+	s, _ := newTestServer(sw, true, testToken)
+
+	rec := doWith(s, http.MethodPost, "/switches/10.0.0.1/plan", testToken, nil)
+	var record services.RunRecord
+	if err := json.Unmarshal(rec.Body.Bytes(), &record); err != nil || record.Plan == nil {
+		t.Fatalf("plan: status = %d; body %s", rec.Code, rec.Body)
+	}
+	reviewed, err := json.Marshal(record.Plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := doWith(s, http.MethodPost, "/switches/10.0.0.1/apply", testToken, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("apply without a plan: status = %d; expected 400", rec.Code)
+	}
+	if rec := doWith(s, http.MethodPost, "/switches/10.0.0.1/apply", testToken, bytes.Replace(reviewed, []byte(`"10.0.0.1"`), []byte(`"10.0.0.2"`), 1)); rec.Code != http.StatusBadRequest {
+		t.Errorf("apply with a plan for another switch: status = %d; expected 400", rec.Code)
+	}
+	drifted := *record.Plan
+	drifted.Fingerprint = "0000"
+	body, _ := json.Marshal(&drifted)
+	if rec := doWith(s, http.MethodPost, "/switches/10.0.0.1/apply", testToken, body); rec.Code != http.StatusConflict {
+		t.Errorf("apply with a drifted plan: status = %d; expected 409", rec.Code)
+	}
+	crafted := *record.Plan
+	crafted.Actions = []entities.PlanAction{{Kind: entities.ActionDeleteVLAN, Vlan: "1"}}
+	body, _ = json.Marshal(&crafted)
+	if rec := doWith(s, http.MethodPost, "/switches/10.0.0.1/apply", testToken, body); rec.Code != http.StatusBadRequest {
+		t.Errorf("apply with an action outside the config: status = %d; expected 400", rec.Code)
+	}
+	if sw.ran("switchport access vlan 10") || sw.ran("no vlan 1") {
+		t.Fatal("a refused plan must not change the switch")
+	}
+
+	rec = doWith(s, http.MethodPost, "/switches/10.0.0.1/apply", testToken, reviewed)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body %s", rec.Code, rec.Body)
+	}
+	if !sw.ran("switchport access vlan 10") {
+		t.Fatalf("expected port to be configured, executed %v", sw.executed)
+	}
+}
+
+func TestUnknownTarget(t *testing.T) {
+	s, _ := newTestServer(&fakeSwitch{}, true, testToken)
+	for _, path := range []string{"/switches/10.0.0.9/plan", "/switches/10.0.0.9/apply"} {
+		if rec := doWith(s, http.MethodPost, path, testToken, nil); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: status = %d; expected 404", path, rec.Code)
+		}
+	}
+}
+
+func TestConcurrentRunConflicts(t *testing.T) {
+	sw := &fakeSwitch{block: make(chan struct{})}
+	s, tracker := newTestServer(sw, false, "")
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- do(s, http.MethodPost, "/switches/10.0.0.1/plan") }()
+	deadline := time.Now().Add(2 * time.Second)
+	for !tracker.Busy("10.0.0.1") {
+		if time.Now().After(deadline) {
+			t.Fatal("first run never started")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if rec := do(s, http.MethodPost, "/switches/10.0.0.1/plan"); rec.Code != http.StatusConflict {
+		t.Errorf("status = %d; expected 409", rec.Code)
+	}
+	close(sw.block)
+	if rec := <-done; rec.Code != http.StatusOK {
+		t.Errorf("first run status = %d; body %s", rec.Code, rec.Body)
+	}
+}
+
+func TestConfigNotLoaded(t *testing.T) {
+	s := NewServer(func() *config.Config { return nil }, services.NewRunTracker(), services.RunOptions{}, false, "")
+	if rec := do(s, http.MethodGet, "/switches"); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d; expected 503", rec.Code)
+	}
+}
@@ -0,0 +1,291 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ConnectBuckets are the upper bounds, in seconds, of the connection duration
+// histogram.
+var ConnectBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+type family struct {
+	name   string
+	help   string
+	kind   string
+	values map[string]float64
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Registry collects negev metrics and renders them in the Prometheus text
+// exposition format. It is safe for concurrent use.
+type Registry struct {
+	mu          sync.Mutex
+	runs        *family
+	changed     *family
+	skipped     *family
+	cmdErrors   *family
+	lastSuccess *family
+	connects    map[string]*histogram
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		runs:        newFamily("negev_runs_total", "Runs per switch by result.", "counter"),
+		changed:     newFamily("negev_ports_changed_total", "Access ports moved to another VLAN (applied runs only).", "counter"),
+		skipped:     newFamily("negev_ports_skipped_total", "Active ports left untouched, by reason.", "counter"),
+		cmdErrors:   newFamily("negev_command_errors_total", "Commands the switch answered with an error.", "counter"),
+		lastSuccess: newFamily("negev_last_success_timestamp_seconds", "Unix time of the last successful run per switch.", "gauge"),
+		connects:    make(map[string]*histogram),
+	}
+}
+
+func newFamily(name, help, kind string) *family {
+	return &family{name: name, help: help, kind: kind, values: make(map[string]float64)}
+}
+
+func (r *Registry) ObserveRun(target string, ok bool, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := "failure"
+	if ok {
+		result = "success"
+		r.lastSuccess.values[labels("target", target)] = float64(at.Unix())
+	}
+	r.runs.values[labels("result", result, "target", target)]++
+}
+
+func (r *Registry) AddPortsChanged(target string, n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.changed.values[labels("target", target)] += float64(n)
+}
+
+func (r *Registry) AddPortSkipped(target, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.skipped.values[labels("reason", reason, "target", target)]++
+}
+
+func (r *Registry) IncCommandErrors(target string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cmdErrors.values[labels("target", target)]++
+}
+
+func (r *Registry) ObserveConnect(target string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h := r.connects[target]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(ConnectBuckets))}
+		r.connects[target] = h
+	}
+	secs := d.Seconds()
+	for i, le := range ConnectBuckets {
+		if secs <= le {
+			h.counts[i]++
+		}
+	}
+	h.sum += secs
+	h.count++
+}
+
+// WriteTo renders every metric in the Prometheus text format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var b strings.Builder
+	for _, f := range []*family{r.runs, r.changed, r.skipped, r.cmdErrors, r.lastSuccess} {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+		for _, l := range sortedKeys(f.values) {
+			fmt.Fprintf(&b, "%s{%s} %s\n", f.name, l, formatValue(f.values[l]))
+		}
+	}
+
+	const name = "negev_connect_duration_seconds"
+	fmt.Fprintf(&b, "# HELP %s Time taken to open a session to a switch.\n# TYPE %s histogram\n", name, name)
+	targets := make([]string, 0, len(r.connects))
+	for t := range r.connects {
+		targets = append(targets, t)
+	}
+	sort.Strings(targets)
+	for _, t := range targets {
+		h := r.connects[t]
+		for i, le := range ConnectBuckets {
+			fmt.Fprintf(&b, "%s_bucket{%s} %d\n", name, labels("le", formatValue(le), "target", t), h.counts[i])
+		}
+		fmt.Fprintf(&b, "%s_bucket{%s} %d\n", name, labels("le", "+Inf", "target", t), h.count)
+		fmt.Fprintf(&b, "%s_sum{%s} %s\n", name, labels("target", t), formatValue(h.sum))
+		fmt.Fprintf(&b, "%s_count{%s} %d\n", name, labels("target", t), h.count)
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
+
+// LoadTextfile seeds the registry from a textfile written by a previous run,
+// so counters keep growing across cron runs and a failed run does not erase
+// the last success timestamp of a switch. Lines it does not know are ignored.
+func (r *Registry) LoadTextfile(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	families := make(map[string]*family)
+	for _, f := range []*family{r.runs, r.changed, r.skipped, r.cmdErrors, r.lastSuccess} {
+		families[f.name] = f
+	}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		name, rest, ok := strings.Cut(sc.Text(), "{")
+		if !ok {
+			continue
+		}
+		l, value, ok := strings.Cut(rest, "} ")
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+		if fam, ok := families[name]; ok {
+			if fam.kind == "counter" {
+				fam.values[l] += v
+			} else if _, exists := fam.values[l]; !exists {
+				fam.values[l] = v
+			}
+			continue
+		}
+		r.loadConnect(name, parseLabels(l), v)
+	}
+	return sc.Err()
+}
+
+// loadConnect adds one line of the connection duration histogram.
+func (r *Registry) loadConnect(name string, l map[string]string, v float64) {
+	const prefix = "negev_connect_duration_seconds_"
+	suffix, ok := strings.CutPrefix(name, prefix)
+	target, hasTarget := l["target"]
+	if !ok || !hasTarget {
+		return
+	}
+	h := r.connects[target]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(ConnectBuckets))}
+		r.connects[target] = h
+	}
+	switch suffix {
+	case "bucket":
+		for i, le := range ConnectBuckets {
+			if formatValue(le) == l["le"] {
+				h.counts[i] += uint64(v)
+			}
+		}
+	case "sum":
+		h.sum += v
+	case "count":
+		h.count += uint64(v)
+	}
+}
+
+// parseLabels reads a label set rendered by labels.
+func parseLabels(s string) map[string]string {
+	result := make(map[string]string)
+	for s != "" {
+		name, rest, ok := strings.Cut(s, `="`)
+		if !ok {
+			break
+		}
+		var value strings.Builder
+		i := 0
+		for ; i < len(rest) && rest[i] != '"'; i++ {
+			if rest[i] == '\\' && i+1 < len(rest) {
+				i++
+				if rest[i] == 'n' {
+					value.WriteByte('\n')
+					continue
+				}
+			}
+			value.WriteByte(rest[i])
+		}
+		result[name] = value.String()
+		s = strings.TrimPrefix(rest[min(i+1, len(rest)):], ",")
+	}
+	return result
+}
+
+// WriteTextfile writes the metrics for the node_exporter textfile collector.
+// The file is replaced atomically so the collector never reads a partial one.
+func (r *Registry) WriteTextfile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := r.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// labels renders name/value pairs as a Prometheus label set; pairs must be
+// given sorted by name.
+func labels(pairs ...string) string {
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, pairs[i]+`="`+labelEscaper.Replace(pairs[i+1])+`"`)
+	}
+	return strings.Join(parts, ",")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
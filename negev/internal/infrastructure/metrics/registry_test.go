@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistryExposition(t *testing.T) {
+	r := NewRegistry()
+	at := time.Unix(1700000000, 0)
+	r.ObserveRun("10.0.0.1", true, at)
+	r.ObserveRun("10.0.0.1", false, at)
+	r.ObserveRun("10.0.0.2", false, at)
+	r.AddPortsChanged("10.0.0.1", 3)
+	r.AddPortSkipped("10.0.0.1", "trunk")
+	r.AddPortSkipped("10.0.0.1", "trunk")
+	r.IncCommandErrors("10.0.0.2")
+	r.ObserveConnect("10.0.0.1", 300*time.Millisecond)
+	r.ObserveConnect("10.0.0.1", 40*time.Second)
+
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"# TYPE negev_runs_total counter",
+		`negev_runs_total{result="success",target="10.0.0.1"} 1`,
+		`negev_runs_total{result="failure",target="10.0.0.2"} 1`,
+		`negev_ports_changed_total{target="10.0.0.1"} 3`,
+		`negev_ports_skipped_total{reason="trunk",target="10.0.0.1"} 2`,
+		`negev_command_errors_total{target="10.0.0.2"} 1`,
+		`negev_last_success_timestamp_seconds{target="10.0.0.1"} 1.7e+09`,
+		"# TYPE negev_connect_duration_seconds histogram",
+		`negev_connect_duration_seconds_bucket{le="0.25",target="10.0.0.1"} 0`,
+		`negev_connect_duration_seconds_bucket{le="0.5",target="10.0.0.1"} 1`,
+		`negev_connect_duration_seconds_bucket{le="+Inf",target="10.0.0.1"} 2`,
+		`negev_connect_duration_seconds_count{target="10.0.0.1"} 2`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `negev_last_success_timestamp_seconds{target="10.0.0.2"}`) {
+		t.Error("failed switch must not get a last success timestamp")
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") || rec.Body.String() != out {
+		t.Errorf("unexpected HTTP response: %q", rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	if got := labels("target", `a"b\c`); got != `target="a\"b\\c"` {
+		t.Fatalf("labels() = %s", got)
+	}
+	if got := parseLabels(labels("le", "0.5", "target", "a\"b\\c\nd")); got["le"] != "0.5" || got["target"] != "a\"b\\c\nd" {
+		t.Fatalf("parseLabels() = %q", got)
+	}
+}
+
+func TestTextfileCarriesOverPreviousRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "negev.prom")
+
+	first := NewRegistry()
+	first.ObserveRun("10.0.0.1", true, time.Unix(1000, 0))
+	first.ObserveRun("10.0.0.2", true, time.Unix(1000, 0))
+	first.AddPortsChanged("10.0.0.2", 3)
+	first.ObserveConnect("10.0.0.2", 200*time.Millisecond)
+	if err := first.WriteTextfile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	second := NewRegistry()
+	if err := second.LoadTextfile(path); err != nil {
+		t.Fatal(err)
+	}
+	second.ObserveRun("10.0.0.1", false, time.Unix(2000, 0))
+	second.ObserveRun("10.0.0.2", true, time.Unix(2000, 0))
+	second.AddPortsChanged("10.0.0.2", 2)
+	second.ObserveConnect("10.0.0.2", 2*time.Second)
+	if err := second.WriteTextfile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	for _, want := range []string{
+		`negev_last_success_timestamp_seconds{target="10.0.0.1"} 1000`,
+		`negev_last_success_timestamp_seconds{target="10.0.0.2"} 2000`,
+		`negev_runs_total{result="failure",target="10.0.0.1"} 1`,
+		`negev_runs_total{result="success",target="10.0.0.1"} 1`,
+		`negev_runs_total{result="success",target="10.0.0.2"} 2`,
+		`negev_ports_changed_total{target="10.0.0.2"} 5`,
+		`negev_connect_duration_seconds_bucket{le="0.25",target="10.0.0.2"} 1`,
+		`negev_connect_duration_seconds_bucket{le="2.5",target="10.0.0.2"} 2`,
+		`negev_connect_duration_seconds_bucket{le="+Inf",target="10.0.0.2"} 2`,
+		`negev_connect_duration_seconds_sum{target="10.0.0.2"} 2.2`,
+		`negev_connect_duration_seconds_count{target="10.0.0.2"} 2`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if matches, _ := filepath.Glob(path + ".tmp*"); len(matches) != 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+
+	if err := NewRegistry().LoadTextfile(filepath.Join(t.TempDir(), "missing.prom")); err != nil {
+		t.Errorf("missing file should be ignored, got %v", err)
+	}
+}
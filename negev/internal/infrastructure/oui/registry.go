@@ -0,0 +1,126 @@
+package oui
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/carlosrabelo/negev/negev/internal/domain/ports"
+)
+
+// Assignments are 24 (MA-L), 28 (MA-M) or 36 (MA-S) bits long.
+var assignmentDigits = []int{9, 7, 6}
+
+var txtLineRegex = regexp.MustCompile(`^\s*([0-9A-Fa-f]{2})-([0-9A-Fa-f]{2})-([0-9A-Fa-f]{2})\s+\(hex\)\s+(.+?)\s*$`)
+
+// Registry names the organization an IEEE MAC address block was assigned to.
+// It reads the CSV exports (oui.csv, mam.csv, oas.csv) and oui.txt.
+type Registry struct {
+	vendors map[string]string
+}
+
+var _ ports.VendorLookup = (*Registry)(nil)
+
+func Load(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OUI registry %s: %v", path, err)
+	}
+	r, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OUI registry %s: %v", path, err)
+	}
+	return r, nil
+}
+
+// Parse reads a registry in CSV form, recognized by its "Registry,Assignment"
+// header, or in the text form of oui.txt.
+func Parse(r io.Reader) (*Registry, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(64)
+	reg := &Registry{vendors: make(map[string]string)}
+	var err error
+	if strings.HasPrefix(strings.TrimPrefix(string(head), "\ufeff"), "Registry,") {
+		err = reg.parseCSV(br)
+	} else {
+		err = reg.parseTxt(br)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(reg.vendors) == 0 {
+		return nil, fmt.Errorf("no assignments found")
+	}
+	return reg, nil
+}
+
+func (r *Registry) parseCSV(in io.Reader) error {
+	cr := csv.NewReader(in)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	if _, err := cr.Read(); err != nil {
+		return err
+	}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(record) < 3 {
+			continue
+		}
+		r.add(record[1], record[2])
+	}
+}
+
+func (r *Registry) parseTxt(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		if m := txtLineRegex.FindStringSubmatch(scanner.Text()); m != nil {
+			r.add(m[1]+m[2]+m[3], m[4])
+		}
+	}
+	return scanner.Err()
+}
+
+func (r *Registry) add(assignment, name string) {
+	assignment = strings.ToLower(strings.TrimSpace(assignment))
+	name = strings.TrimSpace(name)
+	if name == "" || strings.Trim(assignment, "0123456789abcdef") != "" {
+		return
+	}
+	for _, n := range assignmentDigits {
+		if len(assignment) == n {
+			r.vendors[assignment] = name
+			return
+		}
+	}
+}
+
+// Vendor returns the organization of a normalized MAC address, preferring
+// the longest assignment, or "" when it is not registered.
+func (r *Registry) Vendor(mac string) string {
+	mac = strings.ToLower(mac)
+	for _, n := range assignmentDigits {
+		if len(mac) < n {
+			continue
+		}
+		if name, ok := r.vendors[mac[:n]]; ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// Len returns the number of assignments loaded.
+func (r *Registry) Len() int {
+	return len(r.vendors)
+}
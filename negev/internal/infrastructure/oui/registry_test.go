@@ -0,0 +1,74 @@
+package oui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseCSV(t *testing.T) {
+	data := `Registry,Assignment,Organization Name,Organization Address
+MA-L,00A0F8,Zebra Technologies Inc.,475 Half Day Road Lincolnshire IL US 60069
+MA-L,70B3D5,IEEE Registration Authority,445 Hoes Lane Piscataway NJ US 08554
+MA-S,70B3D5123,"Acme, Inc.",Somewhere
+MA-M,70B3D51,Example Devices,Elsewhere
+`
+	reg, err := Parse(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Parse() returned error: %v", err)
+	}
+	for mac, expected := range map[string]string{
+		"00a0f8112233": "Zebra Technologies Inc.",
+		"70b3d5123456": "Acme, Inc.",
+		"70b3d5199999": "Example Devices",
+		"70b3d5a00000": "IEEE Registration Authority",
+		"001122334455": "",
+	} {
+		if got := reg.Vendor(mac); got != expected {
+			t.Errorf("Vendor(%s) = %q; expected %q", mac, got, expected)
+		}
+	}
+	if reg.Len() != 4 {
+		t.Errorf("Len() = %d; expected 4", reg.Len())
+	}
+}
+
+func TestParseTxt(t *testing.T) {
+	data := `OUI/MA-L                                                    Organization
+company_id                                                  Organization
+                                                            Address
+
+00-A0-F8   (hex)		Zebra Technologies Inc.
+00A0F8     (base 16)		Zebra Technologies Inc.
+				475 Half Day Road
+				Lincolnshire  IL  60069
+				US
+
+F4-8E-38   (hex)		Dell Inc.
+F48E38     (base 16)		Dell Inc.
+`
+	reg, err := Parse(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Parse() returned error: %v", err)
+	}
+	if got := reg.Vendor("f48e38000001"); got != "Dell Inc." {
+		t.Errorf("Vendor() = %q; expected Dell Inc.", got)
+	}
+	if reg.Len() != 2 {
+		t.Errorf("Len() = %d; expected 2", reg.Len())
+	}
+}
+
+func TestLoad(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.csv")); err == nil {
+		t.Fatal("expected error for a missing file")
+	}
+	empty := filepath.Join(t.TempDir(), "empty.txt")
+	if err := os.WriteFile(empty, []byte("nothing here\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(empty); err == nil || !strings.Contains(err.Error(), "no assignments found") {
+		t.Fatalf("expected no assignments error, got %v", err)
+	}
+}
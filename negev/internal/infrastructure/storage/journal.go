@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
+	"github.com/carlosrabelo/negev/negev/internal/domain/ports"
+)
+
+const runIDLayout = "20060102T150405.000000Z"
+
+// FileJournal keeps one JSON file per applied run under dir/<target>/.
+type FileJournal struct {
+	dir string
+}
+
+func NewFileJournal(dir string) *FileJournal {
+	return &FileJournal{dir: dir}
+}
+
+var _ ports.RunJournal = (*FileJournal)(nil)
+
+// Record writes entry, assigning a run ID from its creation time if it has
+// none.
+func (j *FileJournal) Record(entry *entities.JournalEntry) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	if entry.RunID == "" {
+		entry.RunID = entry.CreatedAt.UTC().Format(runIDLayout)
+	}
+	dir := j.targetDir(entry.Target)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create journal directory %s: %v", dir, err)
+	}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode journal entry: %v", err)
+	}
+	path := filepath.Join(dir, entry.RunID+".json")
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write journal entry %s: %v", path, err)
+	}
+	return nil
+}
+
+// Runs lists the run IDs recorded for target, oldest first.
+func (j *FileJournal) Runs(target string) ([]string, error) {
+	files, err := os.ReadDir(j.targetDir(target))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal for %s: %v", target, err)
+	}
+	var runs []string
+	for _, f := range files {
+		if id, ok := strings.CutSuffix(f.Name(), ".json"); ok && !f.IsDir() {
+			runs = append(runs, id)
+		}
+	}
+	sort.Strings(runs)
+	return runs, nil
+}
+
+// Load reads the entry of runID, or of the latest run when runID is empty.
+// The latest run skips rollbacks and fails when it was already rolled back,
+// so repeating a rollback never re-applies the change it undid.
+func (j *FileJournal) Load(target, runID string) (*entities.JournalEntry, error) {
+	if runID != "" {
+		return j.load(target, runID)
+	}
+	runs, err := j.Runs(target)
+	if err != nil {
+		return nil, err
+	}
+	undone := make(map[string]string)
+	for i := len(runs) - 1; i >= 0; i-- {
+		entry, err := j.load(target, runs[i])
+		if err != nil {
+			return nil, err
+		}
+		if entry.RollbackOf != "" {
+			undone[entry.RollbackOf] = entry.RunID
+			continue
+		}
+		if by, ok := undone[entry.RunID]; ok {
+			return nil, fmt.Errorf("latest run %s of %s was already rolled back by %s", entry.RunID, target, by)
+		}
+		return entry, nil
+	}
+	return nil, fmt.Errorf("no applied runs recorded for %s", target)
+}
+
+func (j *FileJournal) load(target, runID string) (*entities.JournalEntry, error) {
+	if strings.ContainsAny(runID, `/\`) {
+		return nil, fmt.Errorf("invalid run ID %q", runID)
+	}
+	path := filepath.Join(j.targetDir(target), runID+".json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal entry %s: %v", path, err)
+	}
+	var entry entities.JournalEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to parse journal entry %s: %v", path, err)
+	}
+	if entry.Target != target {
+		return nil, fmt.Errorf("journal entry %s was recorded for %s, not %s", path, entry.Target, target)
+	}
+	return &entry, nil
+}
+
+func (j *FileJournal) targetDir(target string) string {
+	return filepath.Join(j.dir, sanitizeTarget(target))
+}
+
+// sanitizeTarget turns a switch target into a safe file name.
+func sanitizeTarget(target string) string {
+	return strings.NewReplacer("/", "_", `\`, "_", ":", "_").Replace(target)
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
+)
+
+func TestFileJournalRecordAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	j := NewFileJournal(dir)
+
+	if runs, err := j.Runs("10.0.0.1"); err != nil || len(runs) != 0 {
+		t.Fatalf("Runs() on empty journal = %v, %v", runs, err)
+	}
+	if _, err := j.Load("10.0.0.1", ""); err == nil {
+		t.Fatal("expected error when no run is recorded")
+	}
+
+	first := &entities.JournalEntry{
+		Target:    "10.0.0.1",
+		Platform:  "ios",
+		CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Actions: []entities.PlanAction{{
+			Kind: entities.ActionConfigureAccess, Interface: "Gi1/0/1", CurrentVlan: "1", TargetVlan: "10",
+		}},
+	}
+	second := &entities.JournalEntry{
+		Target:    "10.0.0.1",
+		Platform:  "ios",
+		CreatedAt: first.CreatedAt.Add(time.Hour),
+		Actions:   []entities.PlanAction{{Kind: entities.ActionCreateVLAN, Vlan: "30"}},
+	}
+	for _, e := range []*entities.JournalEntry{first, second} {
+		if err := j.Record(e); err != nil {
+			t.Fatalf("Record() returned error: %v", err)
+		}
+	}
+	if first.RunID != "20260102T030405.000000Z" {
+		t.Fatalf("unexpected run ID %q", first.RunID)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "10.0.0.1", first.RunID+".json"))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("expected journal file with 0600 permissions, got %v, %v", info, err)
+	}
+
+	runs, _ := j.Runs("10.0.0.1")
+	if !reflect.DeepEqual(runs, []string{first.RunID, second.RunID}) {
+		t.Fatalf("Runs() = %v", runs)
+	}
+	latest, err := j.Load("10.0.0.1", "")
+	if err != nil || latest.RunID != second.RunID {
+		t.Fatalf("Load(latest) = %+v, %v", latest, err)
+	}
+	got, err := j.Load("10.0.0.1", first.RunID)
+	if err != nil || !reflect.DeepEqual(got.Actions, first.Actions) {
+		t.Fatalf("Load(first) = %+v, %v", got, err)
+	}
+
+	if _, err := j.Load("10.0.0.1", "../../etc/passwd"); err == nil {
+		t.Fatal("expected invalid run ID error")
+	}
+	if _, err := j.Load("10.0.0.2", first.RunID); err == nil {
+		t.Fatal("expected error for a run of another switch")
+	}
+}
+
+func TestFileJournalSanitizesTarget(t *testing.T) {
+	dir := t.TempDir()
+	j := NewFileJournal(dir)
+	entry := &entities.JournalEntry{Target: "fe80::1", Platform: "ios"}
+	if err := j.Record(entry); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "fe80__1", entry.RunID+".json")); err != nil {
+		t.Fatalf("expected sanitized directory: %v", err)
+	}
+	if _, err := j.Load("fe80::1", ""); err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+}
+
+func TestFileJournalLatestSkipsRollbacks(t *testing.T) {
+	j := NewFileJournal(t.TempDir())
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	record := func(offset time.Duration, rollbackOf string) *entities.JournalEntry {
+		e := &entities.JournalEntry{Target: "10.0.0.1", Platform: "ios", CreatedAt: start.Add(offset), RollbackOf: rollbackOf}
+		if err := j.Record(e); err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+
+	first := record(0, "")
+	second := record(time.Hour, "")
+	record(2*time.Hour, second.RunID)
+	if _, err := j.Load("10.0.0.1", ""); err == nil {
+		t.Fatal("expected error when the latest run was already rolled back")
+	}
+	if got, err := j.Load("10.0.0.1", first.RunID); err != nil || got.RunID != first.RunID {
+		t.Fatalf("Load(first) = %+v, %v", got, err)
+	}
+
+	third := record(3*time.Hour, "")
+	record(4*time.Hour, first.RunID)
+	latest, err := j.Load("10.0.0.1", "")
+	if err != nil || latest.RunID != third.RunID {
+		t.Fatalf("Load(latest) = %+v, %v; expected %s", latest, err, third.RunID)
+	}
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
+)
+
+func SavePlan(path string, plan *entities.Plan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode plan: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write plan file %s: %v", path, err)
+	}
+	return nil
+}
+
+func LoadPlan(path string) (*entities.Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan file %s: %v", path, err)
+	}
+	plan, err := DecodePlan(data)
+	if err != nil {
+		return nil, fmt.Errorf("plan file %s: %v", path, err)
+	}
+	return plan, nil
+}
+
+// DecodePlan reads a plan as written by SavePlan or returned by the HTTP API.
+func DecodePlan(data []byte) (*entities.Plan, error) {
+	var plan entities.Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %v", err)
+	}
+	if plan.Target == "" {
+		return nil, fmt.Errorf("plan has no target")
+	}
+	if plan.Fingerprint == "" {
+		return nil, fmt.Errorf("plan has no state fingerprint")
+	}
+	return &plan, nil
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
+)
+
+func TestSaveAndLoadPlan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plan.json")
+	plan := &entities.Plan{
+		Target:      "10.0.0.1",
+		Platform:    "ios",
+		Sandbox:     true,
+		Fingerprint: "abc123",
+		Actions: []entities.PlanAction{{
+			Kind:        entities.ActionConfigureAccess,
+			Interface:   "Gi1/0/1",
+			CurrentVlan: "1",
+			TargetVlan:  "10",
+			Commands:    []string{"switchport access vlan 10"},
+		}},
+	}
+	if err := SavePlan(path, plan); err != nil {
+		t.Fatalf("SavePlan() returned error: %v", err)
+	}
+	got, err := LoadPlan(path)
+	if err != nil {
+		t.Fatalf("LoadPlan() returned error: %v", err)
+	}
+	if !reflect.DeepEqual(got, plan) {
+		t.Errorf("LoadPlan() = %+v; expected %+v", got, plan)
+	}
+}
+
+func TestLoadPlanRejectsIncompleteFiles(t *testing.T) {
+	dir := t.TempDir()
+	cases := map[string]string{
+		"garbage.json":        "not json",
+		"no-target.json":      `{"fingerprint": "abc"}`,
+		"no-fingerprint.json": `{"target": "10.0.0.1"}`,
+	}
+	for name, content := range cases {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadPlan(path); err == nil {
+			t.Errorf("LoadPlan(%s): expected error", name)
+		}
+	}
+	if _, err := LoadPlan(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
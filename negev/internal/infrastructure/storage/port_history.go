@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
+	"github.com/carlosrabelo/negev/negev/internal/domain/ports"
+)
+
+// FilePortHistory keeps the port history of each switch in dir/<target>.json.
+type FilePortHistory struct {
+	dir string
+}
+
+func NewFilePortHistory(dir string) *FilePortHistory {
+	return &FilePortHistory{dir: dir}
+}
+
+var _ ports.PortHistoryStore = (*FilePortHistory)(nil)
+
+// Load returns the history of target, empty if none was saved yet.
+func (h *FilePortHistory) Load(target string) (*entities.PortHistory, error) {
+	history := &entities.PortHistory{Target: target, Ports: map[string]entities.PortRecord{}}
+	path := h.path(target)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read port history %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, history); err != nil {
+		return nil, fmt.Errorf("failed to parse port history %s: %v", path, err)
+	}
+	if history.Target != target {
+		return nil, fmt.Errorf("port history %s was recorded for %s, not %s", path, history.Target, target)
+	}
+	if history.Ports == nil {
+		history.Ports = map[string]entities.PortRecord{}
+	}
+	return history, nil
+}
+
+// Save replaces the stored history of history.Target. The file is written
+// next to the old one and renamed over it, so a crash never leaves it half
+// written.
+func (h *FilePortHistory) Save(history *entities.PortHistory) error {
+	if err := os.MkdirAll(h.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create port history directory %s: %v", h.dir, err)
+	}
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode port history: %v", err)
+	}
+	path := h.path(history.Target)
+	tmp, err := os.CreateTemp(h.dir, ".port-history-*")
+	if err != nil {
+		return fmt.Errorf("failed to write port history %s: %v", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write port history %s: %v", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write port history %s: %v", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write port history %s: %v", path, err)
+	}
+	return nil
+}
+
+func (h *FilePortHistory) path(target string) string {
+	return filepath.Join(h.dir, sanitizeTarget(target)+".json")
+}
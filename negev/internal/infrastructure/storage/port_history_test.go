@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
+)
+
+func TestFilePortHistorySaveAndLoad(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "ports")
+	h := NewFilePortHistory(dir)
+
+	empty, err := h.Load("10.0.0.1")
+	if err != nil {
+		t.Fatalf("Load() on empty store returned error: %v", err)
+	}
+	if empty.Target != "10.0.0.1" || len(empty.Ports) != 0 {
+		t.Fatalf("unexpected empty history: %+v", empty)
+	}
+
+	since := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	seen := entities.MacRecord{FirstSeen: since, LastSeen: since, Since: since, Observations: 1}
+	history := &entities.PortHistory{Target: "10.0.0.1", LastRun: since, Ports: map[string]entities.PortRecord{
+		"gi1/0/1": {Macs: map[string]entities.MacRecord{"aabbccddeeff": seen}},
+		"gi1/0/2": {NoMacSince: since},
+	}}
+	if err := h.Save(history); err != nil {
+		t.Fatalf("Save() returned error: %v", err)
+	}
+	got, err := h.Load("10.0.0.1")
+	if err != nil {
+		t.Fatalf("Load() returned error: %v", err)
+	}
+	if !got.Ports["gi1/0/2"].NoMacSince.Equal(since) || !got.LastRun.Equal(since) || got.Ports["gi1/0/1"].Macs["aabbccddeeff"] != seen {
+		t.Fatalf("unexpected history after reload: %+v", got)
+	}
+
+	files, _ := os.ReadDir(dir)
+	if len(files) != 1 || files[0].Name() != "10.0.0.1.json" {
+		t.Fatalf("expected a single history file, got %v", files)
+	}
+	if _, err := h.Load("10.0.0.2"); err != nil {
+		t.Fatalf("Load() of another switch returned error: %v", err)
+	}
+}
+
+func TestFilePortHistoryRejectsForeignFile(t *testing.T) {
+	dir := t.TempDir()
+	h := NewFilePortHistory(dir)
+	if err := os.WriteFile(filepath.Join(dir, "10.0.0.1.json"), []byte(`{"target":"10.0.0.9","ports":{}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Load("10.0.0.1"); err == nil {
+		t.Fatal("expected error for history recorded for another switch")
+	}
+}
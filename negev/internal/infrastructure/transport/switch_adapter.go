@@ -1,19 +1,36 @@
 package transport
 
 import (
+	"strings"
+
 	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
 	"github.com/carlosrabelo/negev/negev/internal/domain/ports"
 )
 
 type SwitchAdapter struct {
-	config entities.SwitchConfig
-	client Client
+	config     entities.SwitchConfig
+	client     Client
+	persistent bool
+	// sent is set once a command went out since Connect.
+	sent bool
 }
 
 func NewSwitchAdapter(cfg entities.SwitchConfig) *SwitchAdapter {
 	return &SwitchAdapter{config: cfg}
 }
 
+// NewPersistentSwitchAdapter builds an adapter whose Disconnect keeps the
+// cached client open so long-running modes reuse the session between runs.
+// Since the switch may have dropped the idle connection, the first command
+// after Connect and show commands are retried once on a fresh session when
+// they fail. Other commands are not: a configuration line sent again outside
+// its configure/interface context, or twice, would do the wrong thing. A
+// session whose command failed is closed instead of being kept.
+// Sessions are closed by CloseAll.
+func NewPersistentSwitchAdapter(cfg entities.SwitchConfig) *SwitchAdapter {
+	return &SwitchAdapter{config: cfg, persistent: true}
+}
+
 // NewSwitchAdapterWithClient builds an adapter that uses the provided Client
 // instead of resolving one via GetClient. Intended for tests.
 func NewSwitchAdapterWithClient(cfg entities.SwitchConfig, client Client) *SwitchAdapter {
@@ -21,6 +38,11 @@ func NewSwitchAdapterWithClient(cfg entities.SwitchConfig, client Client) *Switc
 }
 
 func (sa *SwitchAdapter) Connect() error {
+	sa.sent = false
+	return sa.connect()
+}
+
+func (sa *SwitchAdapter) connect() error {
 	if sa.client == nil {
 		sa.client = GetClient(sa.config)
 	}
@@ -28,16 +50,36 @@ func (sa *SwitchAdapter) Connect() error {
 }
 
 func (sa *SwitchAdapter) Disconnect() {
-	if sa.client != nil {
+	if sa.client != nil && !sa.persistent {
 		sa.client.Disconnect()
 	}
 }
 
 func (sa *SwitchAdapter) ExecuteCommand(cmd string) (string, error) {
-	if err := sa.Connect(); err != nil {
+	if err := sa.connect(); err != nil {
 		return "", err
 	}
-	return sa.client.ExecuteCommand(cmd)
+	first := !sa.sent
+	sa.sent = true
+	out, err := sa.client.ExecuteCommand(cmd)
+	if err != nil && sa.persistent && (first || isShowCommand(cmd)) {
+		sa.client.Disconnect()
+		if connErr := sa.client.Connect(); connErr != nil {
+			return "", err
+		}
+		out, err = sa.client.ExecuteCommand(cmd)
+	}
+	if err != nil && sa.persistent {
+		// The session may be left inside configure mode or half closed;
+		// drop it so the next run logs in again.
+		sa.client.Disconnect()
+	}
+	return out, err
+}
+
+func isShowCommand(cmd string) bool {
+	fields := strings.Fields(strings.ToLower(cmd))
+	return len(fields) > 0 && fields[0] == "show"
 }
 
 func (sa *SwitchAdapter) IsConnected() bool {
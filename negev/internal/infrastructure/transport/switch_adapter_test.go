@@ -14,6 +14,8 @@ type mockClient struct {
 	executed   []string
 	commandOut string
 	commandErr error
+	failNext   error
+	connects   int
 }
 
 func (m *mockClient) Connect() error {
@@ -21,6 +23,7 @@ func (m *mockClient) Connect() error {
 		return m.connectErr
 	}
 	m.connected = true
+	m.connects++
 	return nil
 }
 
@@ -30,6 +33,11 @@ func (m *mockClient) Disconnect() {
 
 func (m *mockClient) ExecuteCommand(cmd string) (string, error) {
 	m.executed = append(m.executed, cmd)
+	if m.failNext != nil {
+		err := m.failNext
+		m.failNext = nil
+		return "", err
+	}
 	return m.commandOut, m.commandErr
 }
 
@@ -122,3 +130,57 @@ func TestSwitchAdapterSetAuthSequenceCreatesClient(t *testing.T) {
 		t.Fatalf("expected auth sequence on SSH client, got %+v", sshCli.authSequence)
 	}
 }
+
+func TestPersistentSwitchAdapterKeepsAndRecoversSession(t *testing.T) {
+	mockCli := &mockClient{commandOut: "ok"}
+	adapter := NewPersistentSwitchAdapter(entities.SwitchConfig{Target: "10.0.0.1"})
+	adapter.client = mockCli
+
+	if _, err := adapter.ExecuteCommand("show version"); err != nil {
+		t.Fatalf("ExecuteCommand failed: %v", err)
+	}
+	adapter.Disconnect()
+	if !mockCli.connected {
+		t.Fatal("persistent adapter must keep the session open on Disconnect")
+	}
+
+	mockCli.failNext = errors.New("connection reset")
+	out, err := adapter.ExecuteCommand("show vlan brief")
+	if err != nil || out != "ok" {
+		t.Fatalf("expected retry on a fresh session, got %q, %v", out, err)
+	}
+	if mockCli.connects != 3 || len(mockCli.executed) != 3 {
+		t.Errorf("expected reconnect and one retry, got %d connects, executed %v", mockCli.connects, mockCli.executed)
+	}
+
+	mockCli.commandErr = errors.New("still down")
+	if _, err := adapter.ExecuteCommand("show vlan brief"); err == nil {
+		t.Fatal("expected error when the retry also fails")
+	}
+}
+
+func TestPersistentSwitchAdapterRetriesOnlySafeCommands(t *testing.T) {
+	mockCli := &mockClient{commandOut: "ok"}
+	adapter := NewPersistentSwitchAdapter(entities.SwitchConfig{Target: "10.0.0.1"})
+	adapter.client = mockCli
+
+	if err := adapter.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	mockCli.failNext = errors.New("connection reset")
+	if _, err := adapter.ExecuteCommand("configure terminal"); err != nil {
+		t.Fatalf("the first command after Connect must be retried, got %v", err)
+	}
+
+	mockCli.failNext = errors.New("connection reset")
+	mockCli.executed = nil
+	if _, err := adapter.ExecuteCommand("switchport access vlan 10"); err == nil {
+		t.Fatal("expected a configuration line to fail without retry")
+	}
+	if len(mockCli.executed) != 1 {
+		t.Errorf("configuration line must be sent once, executed %v", mockCli.executed)
+	}
+	if mockCli.connected {
+		t.Error("a session whose command failed must not be kept for the next run")
+	}
+}
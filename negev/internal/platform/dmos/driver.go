@@ -9,10 +9,12 @@ import (
 	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
 	"github.com/carlosrabelo/negev/negev/internal/domain/ports"
 	"github.com/carlosrabelo/negev/negev/internal/platform"
+	"github.com/carlosrabelo/negev/negev/internal/platform/parseutil"
 )
 
-var vlanTableRegex = regexp.MustCompile(`^VLAN\s+(\d+)\s*(?:\[.*?\])?:\s*`)
+var vlanTableRegex = regexp.MustCompile(`^VLAN\s+(\d+)\s*(?:\[(.*?)\])?:\s*`)
 var dmosPortRegex = regexp.MustCompile(`^Ethernet\d+/\d+$`)
+var normalizedPortRegex = regexp.MustCompile(`^ethernet\d+/\d+$`)
 var infoPortRegex = regexp.MustCompile(`^Information of Eth\s+(\d+/\d+)`)
 var macLineRegex = regexp.MustCompile(`^\s*\d+\s+\w*\s+(Eth\s+\d+/\d+)\s+([0-9A-F:]+)\s+(\d+)\s+.*Learned`)
 
@@ -44,7 +46,7 @@ func (d *Driver) GetAuthenticationSequence() []entities.AuthPrompt {
 	}
 }
 
-func (d *Driver) GetVLANList(repo ports.SwitchRepository) ([]string, error) {
+func (d *Driver) GetVLANList(repo ports.SwitchRepository) ([]entities.Vlan, error) {
 	out, err := repo.ExecuteCommand("show vlan table")
 	if err != nil || out == "" {
 		out, err = repo.ExecuteCommand("show vlan")
@@ -55,18 +57,18 @@ func (d *Driver) GetVLANList(repo ports.SwitchRepository) ([]string, error) {
 	return parseVLANs(out), nil
 }
 
-func parseVLANs(output string) []string {
+func parseVLANs(output string) []entities.Vlan {
 	lines := strings.Split(output, "\n")
 	seen := make(map[string]bool)
-	var vlans []string
+	var vlans []entities.Vlan
 	for _, line := range lines {
 		m := vlanTableRegex.FindStringSubmatch(line)
 		if m == nil {
 			continue
 		}
-		v := m[1]
-		if !seen[v] {
-			seen[v] = true
+		v := entities.Vlan{ID: m[1], Name: m[2]}
+		if !seen[v.ID] {
+			seen[v.ID] = true
 			vlans = append(vlans, v)
 		}
 	}
@@ -101,6 +103,43 @@ func parseDmOSTrunksFromSwitchport(output string) []string {
 	return trunks
 }
 
+func (d *Driver) GetTrunkVlans(repo ports.SwitchRepository) (map[string][]string, error) {
+	out, err := getSwitchportOutput(repo)
+	if err != nil {
+		return nil, err
+	}
+	return parseDmOSTrunkVlans(out), nil
+}
+
+// parseDmOSTrunkVlans reads the tagged entries of "Allowed VLANs:", such as
+// "1 (u), 10-20 (t)".
+func parseDmOSTrunkVlans(output string) map[string][]string {
+	result := make(map[string][]string)
+	var currentIface string
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(strings.ToLower(trimmed), "interface ethernet") {
+			parts := strings.Fields(trimmed)
+			if len(parts) >= 3 {
+				currentIface = normalizePort(parts[1] + parts[2])
+			}
+			continue
+		}
+		allowed, ok := strings.CutPrefix(trimmed, "Allowed VLANs:")
+		if !ok || currentIface == "" || !strings.Contains(allowed, "(t)") {
+			continue
+		}
+		var tagged []string
+		for _, item := range strings.Split(allowed, ",") {
+			if vlans, ok := strings.CutSuffix(strings.TrimSpace(item), "(t)"); ok {
+				tagged = append(tagged, strings.TrimSpace(vlans))
+			}
+		}
+		result[currentIface] = parseutil.ExpandVlanList(strings.Join(tagged, ","))
+	}
+	return result
+}
+
 func parseDmOSTrunks(output string) []string {
 	lines := strings.Split(output, "\n")
 	var trunks []string
@@ -177,6 +216,62 @@ func parseDmOSSwitchportVLANs(output string) map[string]string {
 	return result
 }
 
+func (d *Driver) GetVoiceVlans(repo ports.SwitchRepository) (map[string]string, error) {
+	out, err := getSwitchportOutput(repo)
+	if err != nil {
+		return nil, err
+	}
+	return parseDmOSVoiceVlans(out), nil
+}
+
+func parseDmOSVoiceVlans(output string) map[string]string {
+	result := make(map[string]string)
+	lines := strings.Split(output, "\n")
+	var currentIface string
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(strings.ToLower(trimmed), "interface ethernet") {
+			parts := strings.Fields(trimmed)
+			if len(parts) >= 3 {
+				currentIface = normalizePort(parts[1] + parts[2])
+			}
+		} else if strings.HasPrefix(trimmed, "Voice VLAN:") {
+			vlan := strings.TrimSpace(strings.TrimPrefix(trimmed, "Voice VLAN:"))
+			if _, err := strconv.Atoi(vlan); err == nil && currentIface != "" {
+				result[currentIface] = vlan
+			}
+		}
+	}
+	return result
+}
+
+func (d *Driver) GetPortDescriptions(repo ports.SwitchRepository) (map[string]string, error) {
+	out, err := getSwitchportOutput(repo)
+	if err != nil {
+		return nil, err
+	}
+	return parseDmOSDescriptions(out), nil
+}
+
+func parseDmOSDescriptions(output string) map[string]string {
+	result := make(map[string]string)
+	var currentIface string
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(strings.ToLower(trimmed), "interface ethernet") {
+			parts := strings.Fields(trimmed)
+			if len(parts) >= 3 {
+				currentIface = normalizePort(parts[1] + parts[2])
+			}
+		} else if description, ok := strings.CutPrefix(trimmed, "Description:"); ok && currentIface != "" {
+			if description = strings.TrimSpace(description); description != "" {
+				result[currentIface] = description
+			}
+		}
+	}
+	return result
+}
+
 func compareInterfaceNames(a, b string) bool {
 	extract := func(s string) (int, int) {
 		parts := strings.Split(s, "/")
@@ -218,6 +313,10 @@ func normalizeMAC(mac string) string {
 	return mac
 }
 
+func (d *Driver) ValidInterface(name string) bool {
+	return normalizedPortRegex.MatchString(normalizePort(strings.TrimSpace(name)))
+}
+
 func normalizePort(port string) string {
 	port = strings.ToLower(port)
 	if !strings.HasPrefix(port, "ethernet") {
@@ -273,10 +372,78 @@ func (d *Driver) ConfigureAccessCommands(port entities.Port, vlan string) []stri
 	}
 }
 
-func (d *Driver) CreateVLANCommands(vlan string) []string {
+func (d *Driver) ConfigureVoiceCommands(port entities.Port, vlan string) []string {
+	voice := "switchport voice-vlan " + vlan
+	if vlan == "" {
+		voice = "no switchport voice-vlan"
+	}
+	return []string{
+		"configure",
+		"interface " + port.Interface,
+		voice,
+		"exit",
+		"end",
+	}
+}
+
+func (d *Driver) DescribePortCommands(port entities.Port, description string) []string {
+	cmd := "description " + description
+	if description == "" {
+		cmd = "no description"
+	}
+	return []string{
+		"configure",
+		"interface " + port.Interface,
+		cmd,
+		"exit",
+		"end",
+	}
+}
+
+func (d *Driver) ShutdownPortCommands(port entities.Port, shutdown bool) []string {
+	cmd := "shutdown"
+	if !shutdown {
+		cmd = "no shutdown"
+	}
+	return []string{
+		"configure",
+		"interface " + port.Interface,
+		cmd,
+		"exit",
+		"end",
+	}
+}
+
+func (d *Driver) TrunkVlanCommands(port entities.Port, vlan string, allow bool) []string {
+	member := "set-member tagged " + port.Interface
+	if !allow {
+		member = "no set-member " + port.Interface
+	}
+	return []string{
+		"configure",
+		"interface vlan " + vlan,
+		member,
+		"exit",
+		"end",
+	}
+}
+
+func (d *Driver) CreateVLANCommands(vlan, name string) []string {
+	cmds := []string{
+		"configure",
+		"interface vlan " + vlan,
+	}
+	if name != "" {
+		cmds = append(cmds, "name "+name)
+	}
+	return append(cmds, "exit", "end")
+}
+
+func (d *Driver) RenameVLANCommands(vlan, name string) []string {
 	return []string{
 		"configure",
 		"interface vlan " + vlan,
+		"name " + name,
 		"exit",
 		"end",
 	}
@@ -297,8 +464,17 @@ func (d *Driver) SaveCommands() []string {
 	}
 }
 
-func (d *Driver) ClearCache() {
-	clearSwitchportCache()
+func (d *Driver) AbortCommands() []string {
+	return []string{"end"}
+}
+
+func (d *Driver) ClearCache(target string) {
+	clearSwitchportCache(target)
+}
+
+// IsReservedVLAN protects the default VLAN, which DmOS cannot delete.
+func (d *Driver) IsReservedVLAN(vlan string) bool {
+	return vlan == "1"
 }
 
 var dmosErrorPatterns = []string{
@@ -14,7 +14,7 @@ VLAN 10 [VLAN_10]:
 VLAN 20:
 `
 	got := parseVLANs(output)
-	expected := []string{"1", "10", "20"}
+	expected := []entities.Vlan{{ID: "1", Name: "DefaultVlan"}, {ID: "10", Name: "VLAN_10"}, {ID: "20"}}
 	if !reflect.DeepEqual(got, expected) {
 		t.Errorf("parseVLANs() = %v; expected %v", got, expected)
 	}
@@ -38,6 +38,25 @@ interface ethernet 1/3
 	}
 }
 
+func TestParseDmOSTrunkVlans(t *testing.T) {
+	output := `
+interface ethernet 1/1
+  Allowed VLANs: 1 (u), 10-12 (t), 20 (t)
+interface ethernet 1/2
+  Allowed VLANs: 10 (t)
+interface ethernet 1/3
+  Allowed VLANs: 20
+`
+	got := parseDmOSTrunkVlans(output)
+	expected := map[string][]string{
+		"ethernet1/1": {"10", "11", "12", "20"},
+		"ethernet1/2": {"10"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("parseDmOSTrunkVlans() = %v; expected %v", got, expected)
+	}
+}
+
 func TestParseDmOSSwitchportVLANs(t *testing.T) {
 	output := `
 interface ethernet 1/1
@@ -114,3 +133,80 @@ func TestParseMacTable(t *testing.T) {
 		t.Errorf("parseMacTable() = %+v; expected %+v", got, expected)
 	}
 }
+
+func TestValidInterface(t *testing.T) {
+	d := &Driver{}
+	for _, name := range []string{"ethernet1/1", "Ethernet 1/2", "eth 1/3", "eth1/4"} {
+		if !d.ValidInterface(name) {
+			t.Errorf("expected %q to be valid", name)
+		}
+	}
+	for _, name := range []string{"gi1/0/1", "ethernet1/0/1", "ethernet"} {
+		if d.ValidInterface(name) {
+			t.Errorf("expected %q to be invalid", name)
+		}
+	}
+}
+
+func TestIsReservedVLAN(t *testing.T) {
+	d := &Driver{}
+	if !d.IsReservedVLAN("1") || d.IsReservedVLAN("1002") {
+		t.Error("only the default VLAN must be reserved on DmOS")
+	}
+}
+
+func TestParseDmOSVoiceVlans(t *testing.T) {
+	output := `
+interface ethernet 1/1
+  Native VLAN: 10
+  Voice VLAN: 20
+interface ethernet 1/2
+  Native VLAN: 10
+  Voice VLAN: none
+`
+	got := parseDmOSVoiceVlans(output)
+	expected := map[string]string{"ethernet1/1": "20"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("parseDmOSVoiceVlans() = %v; expected %v", got, expected)
+	}
+}
+
+func TestParseDmOSDescriptions(t *testing.T) {
+	output := `
+interface ethernet 1/1
+  Description: Link to Core
+  Native VLAN: 10
+interface ethernet 1/2
+  Description: negev:aabbcc:default_vlan
+interface ethernet 1/3
+  Description:
+  Native VLAN: 20
+`
+	got := parseDmOSDescriptions(output)
+	expected := map[string]string{"ethernet1/1": "Link to Core", "ethernet1/2": "negev:aabbcc:default_vlan"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("parseDmOSDescriptions() = %v; expected %v", got, expected)
+	}
+}
+
+func TestShutdownPortCommands(t *testing.T) {
+	d := &Driver{}
+	port := entities.Port{Interface: "gigabit-ethernet-1/1/1"}
+	if got := d.ShutdownPortCommands(port, true); !reflect.DeepEqual(got, []string{"configure", "interface gigabit-ethernet-1/1/1", "shutdown", "exit", "end"}) {
+		t.Errorf("ShutdownPortCommands() = %v", got)
+	}
+	if got := d.ShutdownPortCommands(port, false); got[2] != "no shutdown" {
+		t.Errorf("ShutdownPortCommands() undo = %v", got)
+	}
+}
+
+func TestTrunkVlanCommands(t *testing.T) {
+	d := &Driver{}
+	port := entities.Port{Interface: "ethernet1/1"}
+	if got := d.TrunkVlanCommands(port, "30", true); !reflect.DeepEqual(got, []string{"configure", "interface vlan 30", "set-member tagged ethernet1/1", "exit", "end"}) {
+		t.Errorf("TrunkVlanCommands() = %v", got)
+	}
+	if got := d.TrunkVlanCommands(port, "30", false); got[2] != "no set-member ethernet1/1" {
+		t.Errorf("TrunkVlanCommands() removal = %v", got)
+	}
+}
@@ -15,15 +15,18 @@ var (
 	switchportCacheMu sync.Mutex
 )
 
+// getSwitchportOutput caches the output per switch for the length of a run.
+// A repository that cannot name its switch is never cached, so concurrent
+// runs cannot read each other's ports.
 func getSwitchportOutput(repo ports.SwitchRepository) (string, error) {
-	switchportCacheMu.Lock()
-	defer switchportCacheMu.Unlock()
-
-	target := "default"
-	if t, ok := repo.(targeter); ok {
-		target = t.GetTarget()
+	t, ok := repo.(targeter)
+	if !ok || t.GetTarget() == "" {
+		return repo.ExecuteCommand("show interfaces switchport")
 	}
+	target := t.GetTarget()
 
+	switchportCacheMu.Lock()
+	defer switchportCacheMu.Unlock()
 	if out, ok := switchportCache[target]; ok {
 		return out, nil
 	}
@@ -36,8 +39,8 @@ func getSwitchportOutput(repo ports.SwitchRepository) (string, error) {
 	return out, nil
 }
 
-func clearSwitchportCache() {
+func clearSwitchportCache(target string) {
 	switchportCacheMu.Lock()
 	defer switchportCacheMu.Unlock()
-	switchportCache = make(map[string]string)
+	delete(switchportCache, target)
 }
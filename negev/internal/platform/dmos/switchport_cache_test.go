@@ -27,7 +27,8 @@ func (m *mockCacheRepo) GetTarget() string {
 }
 
 func TestSwitchportCacheKeyedByTarget(t *testing.T) {
-	clearSwitchportCache()
+	clearSwitchportCache("192.168.1.10")
+	clearSwitchportCache("192.168.1.20")
 
 	repo1 := &mockCacheRepo{target: "192.168.1.10"}
 	repo2 := &mockCacheRepo{target: "192.168.1.20"}
@@ -68,8 +69,8 @@ func TestSwitchportCacheKeyedByTarget(t *testing.T) {
 		t.Errorf("expected 1 command execution, got %d", repo2.cmdCount)
 	}
 
-	// 4. Limpar cache: deve resetar a cache e forçar nova execução
-	clearSwitchportCache()
+	// 4. Limpar cache de um target: força nova execução só para ele
+	clearSwitchportCache("192.168.1.10")
 	out1AfterClear, err := getSwitchportOutput(repo1)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -80,4 +81,23 @@ func TestSwitchportCacheKeyedByTarget(t *testing.T) {
 	if repo1.cmdCount != 2 {
 		t.Errorf("expected 2 command executions after cache clear, got %d", repo1.cmdCount)
 	}
+	if _, err := getSwitchportOutput(repo2); err != nil || repo2.cmdCount != 1 {
+		t.Errorf("clearing one target must keep the others cached, got %d executions (%v)", repo2.cmdCount, err)
+	}
+}
+
+type anonymousRepo struct{ *mockCacheRepo }
+
+func (anonymousRepo) GetTarget() string { return "" }
+
+func TestSwitchportCacheSkipsUnnamedRepositories(t *testing.T) {
+	repo := anonymousRepo{&mockCacheRepo{target: "192.168.1.30"}}
+	for i := 0; i < 2; i++ {
+		if _, err := getSwitchportOutput(repo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if repo.cmdCount != 2 {
+		t.Errorf("a repository without a target must not be cached, got %d executions", repo.cmdCount)
+	}
 }
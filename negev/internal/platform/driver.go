@@ -11,18 +11,52 @@ type SwitchDriver interface {
 	Name() string
 	Detect(repo ports.SwitchRepository) (bool, error)
 	GetAuthenticationSequence() []entities.AuthPrompt
-	GetVLANList(repo ports.SwitchRepository) ([]string, error)
+	GetVLANList(repo ports.SwitchRepository) ([]entities.Vlan, error)
 	GetTrunkInterfaces(repo ports.SwitchRepository) ([]string, error)
+	// GetTrunkVlans maps each trunk to the VLAN IDs allowed on it.
+	GetTrunkVlans(repo ports.SwitchRepository) (map[string][]string, error)
 	GetActivePorts(repo ports.SwitchRepository) ([]entities.Port, error)
 	GetMacTable(repo ports.SwitchRepository) ([]entities.Device, error)
+	// GetVoiceVlans maps each interface with a voice VLAN to its ID.
+	GetVoiceVlans(repo ports.SwitchRepository) (map[string]string, error)
+	// GetPortDescriptions maps each interface with a description to it.
+	GetPortDescriptions(repo ports.SwitchRepository) (map[string]string, error)
 	ConfigureAccessCommands(port entities.Port, vlan string) []string
-	CreateVLANCommands(vlan string) []string
+	// ConfigureVoiceCommands sets the voice VLAN of port, or removes it when
+	// vlan is empty.
+	ConfigureVoiceCommands(port entities.Port, vlan string) []string
+	// DescribePortCommands sets the description of port, or removes it when
+	// description is empty.
+	DescribePortCommands(port entities.Port, description string) []string
+	// ShutdownPortCommands shuts port down, or brings it back up when
+	// shutdown is false.
+	ShutdownPortCommands(port entities.Port, shutdown bool) []string
+	// TrunkVlanCommands allows vlan on the trunk port, or removes it when
+	// allow is false, leaving the other VLANs of the trunk as they are.
+	TrunkVlanCommands(port entities.Port, vlan string, allow bool) []string
+	// CreateVLANCommands creates vlan, named name unless it is empty.
+	CreateVLANCommands(vlan, name string) []string
+	RenameVLANCommands(vlan, name string) []string
 	DeleteVLANCommands(vlan string) []string
 	SaveCommands() []string
-	ClearCache()
+	// AbortCommands leave configuration mode; they are sent when the switch
+	// rejects a command partway through a change.
+	AbortCommands() []string
+	// IsReservedVLAN reports whether vlan is built into the platform and must
+	// never be deleted, whatever the configuration says.
+	IsReservedVLAN(vlan string) bool
+	// ClearCache drops what the driver cached about target, so a new run
+	// reads the switch again.
+	ClearCache(target string)
 	IsCommandError(output string) bool
 }
 
+// InterfaceValidator is implemented by drivers that can tell whether a name
+// looks like one of their interfaces, so exclude_ports can be checked offline.
+type InterfaceValidator interface {
+	ValidInterface(name string) bool
+}
+
 var drivers []SwitchDriver
 
 func Register(d SwitchDriver) {
@@ -58,3 +92,19 @@ func Detect(repo ports.SwitchRepository) (SwitchDriver, error) {
 	}
 	return nil, fmt.Errorf("no matching driver found")
 }
+
+// ValidInterface reports whether name looks like an interface of the named
+// platform. For "auto" any driver may accept it; drivers that cannot tell
+// accept every name.
+func ValidInterface(platform, name string) bool {
+	for _, d := range drivers {
+		if platform != "auto" && d.Name() != platform {
+			continue
+		}
+		v, ok := d.(InterfaceValidator)
+		if !ok || v.ValidInterface(name) {
+			return true
+		}
+	}
+	return false
+}
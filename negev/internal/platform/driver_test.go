@@ -19,7 +19,7 @@ func (f *fakeDriver) Detect(repo ports.SwitchRepository) (bool, error) {
 	return f.match, f.detectE
 }
 func (f *fakeDriver) GetAuthenticationSequence() []entities.AuthPrompt { return nil }
-func (f *fakeDriver) GetVLANList(repo ports.SwitchRepository) ([]string, error) {
+func (f *fakeDriver) GetVLANList(repo ports.SwitchRepository) ([]entities.Vlan, error) {
 	return nil, nil
 }
 func (f *fakeDriver) GetTrunkInterfaces(repo ports.SwitchRepository) ([]string, error) {
@@ -31,14 +31,38 @@ func (f *fakeDriver) GetActivePorts(repo ports.SwitchRepository) ([]entities.Por
 func (f *fakeDriver) GetMacTable(repo ports.SwitchRepository) ([]entities.Device, error) {
 	return nil, nil
 }
+func (f *fakeDriver) GetVoiceVlans(repo ports.SwitchRepository) (map[string]string, error) {
+	return nil, nil
+}
+func (f *fakeDriver) GetPortDescriptions(repo ports.SwitchRepository) (map[string]string, error) {
+	return nil, nil
+}
 func (f *fakeDriver) ConfigureAccessCommands(port entities.Port, vlan string) []string {
 	return nil
 }
-func (f *fakeDriver) CreateVLANCommands(vlan string) []string { return nil }
-func (f *fakeDriver) DeleteVLANCommands(vlan string) []string { return nil }
-func (f *fakeDriver) SaveCommands() []string                  { return nil }
-func (f *fakeDriver) ClearCache()                             {}
-func (f *fakeDriver) IsCommandError(output string) bool       { return false }
+func (f *fakeDriver) ConfigureVoiceCommands(port entities.Port, vlan string) []string {
+	return nil
+}
+func (f *fakeDriver) DescribePortCommands(port entities.Port, description string) []string {
+	return nil
+}
+func (f *fakeDriver) ShutdownPortCommands(port entities.Port, shutdown bool) []string {
+	return nil
+}
+func (f *fakeDriver) TrunkVlanCommands(port entities.Port, vlan string, allow bool) []string {
+	return nil
+}
+func (f *fakeDriver) GetTrunkVlans(repo ports.SwitchRepository) (map[string][]string, error) {
+	return nil, nil
+}
+func (f *fakeDriver) CreateVLANCommands(vlan, name string) []string { return nil }
+func (f *fakeDriver) RenameVLANCommands(vlan, name string) []string { return nil }
+func (f *fakeDriver) DeleteVLANCommands(vlan string) []string       { return nil }
+func (f *fakeDriver) SaveCommands() []string                        { return nil }
+func (f *fakeDriver) AbortCommands() []string                       { return nil }
+func (f *fakeDriver) ClearCache(string)                             {}
+func (f *fakeDriver) IsCommandError(output string) bool             { return false }
+func (f *fakeDriver) IsReservedVLAN(vlan string) bool               { return false }
 
 func TestRegisterGetAvailableDetect(t *testing.T) {
 	prev := drivers
@@ -78,3 +102,38 @@ func TestRegisterGetAvailableDetect(t *testing.T) {
 		t.Fatal("expected no matching driver")
 	}
 }
+
+type namingDriver struct {
+	fakeDriver
+	prefix string
+}
+
+func (d *namingDriver) ValidInterface(name string) bool {
+	return len(name) > len(d.prefix) && name[:len(d.prefix)] == d.prefix
+}
+
+func TestValidInterface(t *testing.T) {
+	prev := drivers
+	t.Cleanup(func() { drivers = prev })
+	drivers = nil
+	Register(&namingDriver{fakeDriver: fakeDriver{name: "alpha"}, prefix: "gi"})
+	Register(&namingDriver{fakeDriver: fakeDriver{name: "beta"}, prefix: "eth"})
+	Register(&fakeDriver{name: "gamma"})
+
+	cases := []struct {
+		platform, name string
+		want           bool
+	}{
+		{"alpha", "gi1/0/1", true},
+		{"alpha", "eth1/1", false},
+		{"beta", "eth1/1", true},
+		{"auto", "eth1/1", true},
+		{"gamma", "anything", true},
+		{"missing", "gi1/0/1", false},
+	}
+	for _, c := range cases {
+		if got := ValidInterface(c.platform, c.name); got != c.want {
+			t.Errorf("ValidInterface(%q, %q) = %v; expected %v", c.platform, c.name, got, c.want)
+		}
+	}
+}
@@ -9,9 +9,10 @@ import (
 	"github.com/carlosrabelo/negev/negev/internal/domain/entities"
 	"github.com/carlosrabelo/negev/negev/internal/domain/ports"
 	"github.com/carlosrabelo/negev/negev/internal/platform"
+	"github.com/carlosrabelo/negev/negev/internal/platform/parseutil"
 )
 
-var vlanLineRegex = regexp.MustCompile(`^\s*(?:vlan\s+)?(\d{1,4})\b`)
+var vlanLineRegex = regexp.MustCompile(`^\s*(?:(vlan)\s+)?(\d{1,4})\b(?:\s+(\S+))?`)
 var interfaceRegex = regexp.MustCompile(`^[A-Za-z]+\d+(?:/\d+){0,2}$`)
 var macTableRegex = regexp.MustCompile(`^\s*(\d+)\s+([0-9A-Fa-f]{4}\.[0-9A-Fa-f]{4}\.[0-9A-Fa-f]{4})\s+DYNAMIC\s+(\S+)`)
 
@@ -44,7 +45,7 @@ func (d *Driver) GetAuthenticationSequence() []entities.AuthPrompt {
 	}
 }
 
-func (d *Driver) GetVLANList(repo ports.SwitchRepository) ([]string, error) {
+func (d *Driver) GetVLANList(repo ports.SwitchRepository) ([]entities.Vlan, error) {
 	out, err := repo.ExecuteCommand("show vlan brief")
 	if err != nil || out == "" {
 		out, err = repo.ExecuteCommand("show vlan")
@@ -55,18 +56,23 @@ func (d *Driver) GetVLANList(repo ports.SwitchRepository) ([]string, error) {
 	return parseVLANs(out), nil
 }
 
-func parseVLANs(output string) []string {
+// parseVLANs reads "show vlan brief", where the name follows the ID. The
+// first table wins, so the type table of "show vlan" does not rename them.
+func parseVLANs(output string) []entities.Vlan {
 	lines := strings.Split(output, "\n")
 	seen := make(map[string]bool)
-	var vlans []string
+	var vlans []entities.Vlan
 	for _, line := range lines {
 		m := vlanLineRegex.FindStringSubmatch(line)
 		if m == nil {
 			continue
 		}
-		v := m[1]
-		if !seen[v] {
-			seen[v] = true
+		v := entities.Vlan{ID: m[2]}
+		if m[1] == "" {
+			v.Name = m[3]
+		}
+		if !seen[v.ID] {
+			seen[v.ID] = true
 			vlans = append(vlans, v)
 		}
 	}
@@ -101,6 +107,53 @@ func parseTrunkInterfaces(output string) []string {
 	return ifaces
 }
 
+func (d *Driver) GetTrunkVlans(repo ports.SwitchRepository) (map[string][]string, error) {
+	out, err := repo.ExecuteCommand("show interfaces trunk")
+	if err != nil {
+		return nil, err
+	}
+	return parseTrunkVlans(out), nil
+}
+
+// parseTrunkVlans reads the "Vlans allowed on trunk" table of show interfaces
+// trunk, whose long lists wrap onto lines without a port.
+func parseTrunkVlans(output string) map[string][]string {
+	lists := make(map[string]string)
+	var order []string
+	inSection := false
+	current := ""
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			current = ""
+			continue
+		}
+		if fields[0] == "Port" {
+			inSection = strings.Contains(line, "Vlans allowed on trunk")
+			current = ""
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		switch {
+		case interfaceRegex.MatchString(fields[0]) && len(fields) >= 2:
+			current = fields[0]
+			if _, ok := lists[current]; !ok {
+				order = append(order, current)
+			}
+			lists[current] = fields[1]
+		case current != "" && len(fields) == 1:
+			lists[current] += fields[0]
+		}
+	}
+	result := make(map[string][]string, len(lists))
+	for _, iface := range order {
+		result[iface] = parseutil.ExpandVlanList(lists[iface])
+	}
+	return result
+}
+
 func (d *Driver) GetActivePorts(repo ports.SwitchRepository) ([]entities.Port, error) {
 	out, err := repo.ExecuteCommand("show interfaces status")
 	if err != nil {
@@ -217,6 +270,68 @@ func formatMac(mac string) string {
 	return mac[0:2] + ":" + mac[2:4] + ":" + mac[4:6] + ":" + mac[6:8] + ":" + mac[8:10] + ":" + mac[10:12]
 }
 
+func (d *Driver) GetVoiceVlans(repo ports.SwitchRepository) (map[string]string, error) {
+	out, err := repo.ExecuteCommand("show interfaces switchport")
+	if err != nil {
+		return nil, err
+	}
+	return parseVoiceVlans(out), nil
+}
+
+// parseVoiceVlans reads the "Name:" and "Voice VLAN:" lines of each interface
+// block; "none" means no voice VLAN.
+func parseVoiceVlans(output string) map[string]string {
+	result := make(map[string]string)
+	var currentIface string
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if name, ok := strings.CutPrefix(trimmed, "Name:"); ok {
+			currentIface = strings.TrimSpace(name)
+			continue
+		}
+		value, ok := strings.CutPrefix(trimmed, "Voice VLAN:")
+		if !ok || currentIface == "" {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) > 0 && isVlanOrMode(fields[0]) {
+			result[currentIface] = fields[0]
+		}
+	}
+	return result
+}
+
+func (d *Driver) GetPortDescriptions(repo ports.SwitchRepository) (map[string]string, error) {
+	out, err := repo.ExecuteCommand("show interfaces description")
+	if err != nil {
+		return nil, err
+	}
+	return parseDescriptions(out), nil
+}
+
+// parseDescriptions reads "show interfaces description". Status may be two
+// words ("admin down"), so the description is cut at its header column.
+func parseDescriptions(output string) map[string]string {
+	result := make(map[string]string)
+	column := -1
+	for _, line := range strings.Split(output, "\n") {
+		if column < 0 {
+			if i := strings.Index(line, "Description"); i >= 0 && strings.HasPrefix(strings.TrimSpace(line), "Interface") {
+				column = i
+			}
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !interfaceRegex.MatchString(fields[0]) || len(line) <= column {
+			continue
+		}
+		if description := strings.TrimSpace(line[column:]); description != "" {
+			result[fields[0]] = description
+		}
+	}
+	return result
+}
+
 func (d *Driver) ConfigureAccessCommands(port entities.Port, vlan string) []string {
 	return []string{
 		"configure terminal",
@@ -227,14 +342,80 @@ func (d *Driver) ConfigureAccessCommands(port entities.Port, vlan string) []stri
 	}
 }
 
-func (d *Driver) CreateVLANCommands(vlan string) []string {
+func (d *Driver) ConfigureVoiceCommands(port entities.Port, vlan string) []string {
+	voice := "switchport voice vlan " + vlan
+	if vlan == "" {
+		voice = "no switchport voice vlan"
+	}
+	return []string{
+		"configure terminal",
+		"interface " + port.Interface,
+		voice,
+		"end",
+	}
+}
+
+func (d *Driver) DescribePortCommands(port entities.Port, description string) []string {
+	cmd := "description " + description
+	if description == "" {
+		cmd = "no description"
+	}
+	return []string{
+		"configure terminal",
+		"interface " + port.Interface,
+		cmd,
+		"end",
+	}
+}
+
+func (d *Driver) ShutdownPortCommands(port entities.Port, shutdown bool) []string {
+	cmd := "shutdown"
+	if !shutdown {
+		cmd = "no shutdown"
+	}
+	return []string{
+		"configure terminal",
+		"interface " + port.Interface,
+		cmd,
+		"end",
+	}
+}
+
+func (d *Driver) TrunkVlanCommands(port entities.Port, vlan string, allow bool) []string {
+	op := "add"
+	if !allow {
+		op = "remove"
+	}
 	return []string{
+		"configure terminal",
+		"interface " + port.Interface,
+		"switchport trunk allowed vlan " + op + " " + vlan,
+		"end",
+	}
+}
+
+func (d *Driver) CreateVLANCommands(vlan, name string) []string {
+	cmds := []string{
 		"configure terminal",
 		"vlan " + vlan,
+	}
+	if name != "" {
+		cmds = append(cmds, "name "+name)
+	}
+	return append(cmds,
 		"exit",
-		"interface vlan " + vlan,
+		"interface vlan "+vlan,
 		"no shutdown",
 		"end",
+	)
+}
+
+func (d *Driver) RenameVLANCommands(vlan, name string) []string {
+	return []string{
+		"configure terminal",
+		"vlan " + vlan,
+		"name " + name,
+		"end",
 	}
 }
 
@@ -255,7 +436,23 @@ func (d *Driver) SaveCommands() []string {
 	return []string{"write memory"}
 }
 
-func (d *Driver) ClearCache() {}
+func (d *Driver) AbortCommands() []string {
+	return []string{"end"}
+}
+
+func (d *Driver) ClearCache(string) {}
+
+func (d *Driver) ValidInterface(name string) bool {
+	return interfaceRegex.MatchString(strings.TrimSpace(name))
+}
+
+// reservedVlans are the default VLAN and the FDDI and Token Ring defaults,
+// which IOS refuses to delete.
+var reservedVlans = []string{"1", "1002-1005"}
+
+func (d *Driver) IsReservedVLAN(vlan string) bool {
+	return entities.VlanInRanges(vlan, reservedVlans)
+}
 
 func (d *Driver) IsCommandError(output string) bool {
 	return isIOSCommandError(output)
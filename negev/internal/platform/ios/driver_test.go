@@ -16,10 +16,39 @@ VLAN Name                             Status    Ports
 20   VLAN_20                          active
 `
 	got := parseVLANs(output)
-	expected := []string{"1", "10", "20"}
+	expected := []entities.Vlan{{ID: "1", Name: "default"}, {ID: "10", Name: "VLAN_10"}, {ID: "20", Name: "VLAN_20"}}
 	if !reflect.DeepEqual(got, expected) {
 		t.Errorf("parseVLANs() = %v; expected %v", got, expected)
 	}
+
+	full := output + `
+VLAN Type  SAID       MTU   Parent RingNo BridgeNo Stp  BrdgMode Trans1 Trans2
+---- ----- ---------- ----- ------ ------ -------- ---- -------- ------ ------
+1    enet  100001     1500  -      -      -        -    -        0      0
+10   enet  100010     1500  -      -      -        -    -        0      0
+`
+	if got := parseVLANs(full); !reflect.DeepEqual(got, expected) {
+		t.Errorf("parseVLANs(show vlan) = %v; expected %v", got, expected)
+	}
+}
+
+func TestVLANCommands(t *testing.T) {
+	d := &Driver{}
+	got := d.CreateVLANCommands("30", "USERS")
+	expected := []string{"configure terminal", "vlan 30", "name USERS", "exit", "interface vlan 30", "no shutdown", "end"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("CreateVLANCommands() = %v; expected %v", got, expected)
+	}
+	got = d.CreateVLANCommands("30", "")
+	expected = []string{"configure terminal", "vlan 30", "exit", "interface vlan 30", "no shutdown", "end"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("CreateVLANCommands() without a name = %v; expected %v", got, expected)
+	}
+	got = d.RenameVLANCommands("30", "USERS")
+	expected = []string{"configure terminal", "vlan 30", "name USERS", "end"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("RenameVLANCommands() = %v; expected %v", got, expected)
+	}
 }
 
 func TestParseTrunkInterfaces(t *testing.T) {
@@ -37,6 +66,42 @@ Gi1/0/24    1-4094
 	}
 }
 
+func TestParseTrunkVlans(t *testing.T) {
+	output := `
+Port        Mode             Encapsulation  Status        Native vlan
+Gi1/0/23    on               802.1q         trunking      1
+Gi1/0/24    on               802.1q         trunking      1
+
+Port        Vlans allowed on trunk
+Gi1/0/23    1-3,10
+Gi1/0/24    1,20,30-31,
+            40
+
+Port        Vlans allowed and active in management domain
+Gi1/0/23    1,10
+Gi1/0/24    1,20
+`
+	got := parseTrunkVlans(output)
+	expected := map[string][]string{
+		"Gi1/0/23": {"1", "2", "3", "10"},
+		"Gi1/0/24": {"1", "20", "30", "31", "40"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("parseTrunkVlans() = %v; expected %v", got, expected)
+	}
+}
+
+func TestTrunkVlanCommands(t *testing.T) {
+	d := &Driver{}
+	port := entities.Port{Interface: "Gi1/0/24"}
+	if got := d.TrunkVlanCommands(port, "30", true); !reflect.DeepEqual(got, []string{"configure terminal", "interface Gi1/0/24", "switchport trunk allowed vlan add 30", "end"}) {
+		t.Errorf("TrunkVlanCommands() = %v", got)
+	}
+	if got := d.TrunkVlanCommands(port, "30", false); got[2] != "switchport trunk allowed vlan remove 30" {
+		t.Errorf("TrunkVlanCommands() removal = %v", got)
+	}
+}
+
 func TestParseActivePorts(t *testing.T) {
 	output := `
 Port      Name               Status       Vlan       Duplex  Speed Type
@@ -77,3 +142,99 @@ Vlan    Mac Address       Type        Ports
 		t.Errorf("parseMacTable() = %+v; expected %+v", got, expected)
 	}
 }
+
+func TestValidInterface(t *testing.T) {
+	d := &Driver{}
+	for _, name := range []string{"Gi1/0/1", "gi1/0/24", "Fa0/1", "GigabitEthernet1/0/1", "Po1"} {
+		if !d.ValidInterface(name) {
+			t.Errorf("expected %q to be valid", name)
+		}
+	}
+	for _, name := range []string{"eth 1/1", "1/0/1", "Gi1/0/1/2/3", ""} {
+		if d.ValidInterface(name) {
+			t.Errorf("expected %q to be invalid", name)
+		}
+	}
+}
+
+func TestIsReservedVLAN(t *testing.T) {
+	d := &Driver{}
+	for _, vlan := range []string{"1", "1002", "1005"} {
+		if !d.IsReservedVLAN(vlan) {
+			t.Errorf("expected VLAN %s to be reserved", vlan)
+		}
+	}
+	for _, vlan := range []string{"2", "1001", "1006", "4094"} {
+		if d.IsReservedVLAN(vlan) {
+			t.Errorf("expected VLAN %s not to be reserved", vlan)
+		}
+	}
+}
+
+func TestParseVoiceVlans(t *testing.T) {
+	output := `
+Name: Gi1/0/1
+Switchport: Enabled
+Administrative Mode: static access
+Access Mode VLAN: 10 (VLAN0010)
+Voice VLAN: 20 (VLAN0020)
+
+Name: Gi1/0/2
+Switchport: Enabled
+Access Mode VLAN: 10 (VLAN0010)
+Voice VLAN: none
+`
+	got := parseVoiceVlans(output)
+	expected := map[string]string{"Gi1/0/1": "20"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("parseVoiceVlans() = %v; expected %v", got, expected)
+	}
+}
+
+func TestParseDescriptions(t *testing.T) {
+	output := `
+Interface                      Status         Protocol Description
+Vl1                            up             up
+Gi1/0/1                        up             up       negev:aabbcc:mac_to_vlan_aabbcc
+Gi1/0/2                        admin down     down     Printer 2nd floor
+Gi1/0/3                        down           down
+`
+	got := parseDescriptions(output)
+	expected := map[string]string{"Gi1/0/1": "negev:aabbcc:mac_to_vlan_aabbcc", "Gi1/0/2": "Printer 2nd floor"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("parseDescriptions() = %v; expected %v", got, expected)
+	}
+}
+
+func TestDescribePortCommands(t *testing.T) {
+	d := &Driver{}
+	port := entities.Port{Interface: "Gi1/0/1"}
+	if got := d.DescribePortCommands(port, "negev:aabbcc"); got[2] != "description negev:aabbcc" {
+		t.Errorf("DescribePortCommands() = %v", got)
+	}
+	if got := d.DescribePortCommands(port, ""); got[2] != "no description" {
+		t.Errorf("DescribePortCommands() removal = %v", got)
+	}
+}
+
+func TestShutdownPortCommands(t *testing.T) {
+	d := &Driver{}
+	port := entities.Port{Interface: "Gi1/0/1"}
+	if got := d.ShutdownPortCommands(port, true); !reflect.DeepEqual(got, []string{"configure terminal", "interface Gi1/0/1", "shutdown", "end"}) {
+		t.Errorf("ShutdownPortCommands() = %v", got)
+	}
+	if got := d.ShutdownPortCommands(port, false); got[2] != "no shutdown" {
+		t.Errorf("ShutdownPortCommands() undo = %v", got)
+	}
+}
+
+func TestConfigureVoiceCommands(t *testing.T) {
+	d := &Driver{}
+	port := entities.Port{Interface: "Gi1/0/1"}
+	if got := d.ConfigureVoiceCommands(port, "20"); got[2] != "switchport voice vlan 20" {
+		t.Errorf("ConfigureVoiceCommands() = %v", got)
+	}
+	if got := d.ConfigureVoiceCommands(port, ""); got[2] != "no switchport voice vlan" {
+		t.Errorf("ConfigureVoiceCommands() removal = %v", got)
+	}
+}
@@ -1,6 +1,9 @@
 package parseutil
 
-import "strings"
+import (
+	"strconv"
+	"strings"
+)
 
 func FormatPlainMac(mac string) string {
 	if len(mac) != 12 {
@@ -31,3 +34,25 @@ func IsSeparatorLine(line string) bool {
 	}
 	return true
 }
+
+// ExpandVlanList turns a VLAN list such as "1,10-12" into its IDs in order.
+// Anything that is not an ID or a range between 1 and 4094, like "none", is
+// ignored.
+func ExpandVlanList(list string) []string {
+	var vlans []string
+	for _, item := range strings.Split(list, ",") {
+		lo, hi, isRange := strings.Cut(strings.TrimSpace(item), "-")
+		if !isRange {
+			hi = lo
+		}
+		first, err1 := strconv.Atoi(lo)
+		last, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil || first < 1 || last > 4094 || first > last {
+			continue
+		}
+		for v := first; v <= last; v++ {
+			vlans = append(vlans, strconv.Itoa(v))
+		}
+	}
+	return vlans
+}
@@ -1,6 +1,9 @@
 package parseutil
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func TestFormatPlainMac(t *testing.T) {
 	tests := []struct {
@@ -45,3 +48,24 @@ func TestIsSeparatorLine(t *testing.T) {
 		}
 	}
 }
+
+func TestExpandVlanList(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"1,10-12,20", []string{"1", "10", "11", "12", "20"}},
+		{" 30 , 40 ", []string{"30", "40"}},
+		{"none", nil},
+		{"", nil},
+		{"12-10,0,4095", nil},
+	}
+	for _, tc := range tests {
+		if got := ExpandVlanList(tc.input); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("ExpandVlanList(%q) = %v; expected %v", tc.input, got, tc.expected)
+		}
+	}
+	if got := ExpandVlanList("1-4094"); len(got) != 4094 {
+		t.Errorf("ExpandVlanList(1-4094) has %d VLANs", len(got))
+	}
+}